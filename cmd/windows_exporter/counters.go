@@ -0,0 +1,42 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh/discovery"
+)
+
+// runCountersList writes the performance counter objects of the local host, see "windows_exporter counters list".
+func runCountersList(w io.Writer, object, format string) int {
+	objects, err := discovery.List(discovery.NewEnumerator(), object)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to list performance counters: %v\n", err)
+
+		return 1
+	}
+
+	if err := discovery.Write(w, objects, format); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write performance counters: %v\n", err)
+
+		return 1
+	}
+
+	return 0
+}
@@ -39,12 +39,18 @@ import (
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus-community/windows_exporter/internal/collector/textfile"
 	"github.com/prometheus-community/windows_exporter/internal/config"
 	"github.com/prometheus-community/windows_exporter/internal/httphandler"
 	"github.com/prometheus-community/windows_exporter/internal/log"
 	"github.com/prometheus-community/windows_exporter/internal/log/flag"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/discovery"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/registry"
+	"github.com/prometheus-community/windows_exporter/internal/probe"
 	"github.com/prometheus-community/windows_exporter/internal/utils"
 	"github.com/prometheus-community/windows_exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/version"
 	"github.com/prometheus/exporter-toolkit/web"
 	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
@@ -87,7 +93,7 @@ func run() int {
 		).Default("/metrics").String()
 		disableExporterMetrics = app.Flag(
 			"web.disable-exporter-metrics",
-			"Exclude metrics about the exporter itself (promhttp_*, process_*, go_*).",
+			"Exclude metrics about the exporter itself (promhttp_*, process_*, go_*, windows_exporter_http_*, windows_exporter_scrape_requests_total).",
 		).Bool()
 		enabledCollectors = app.Flag(
 			"collectors.enabled",
@@ -97,6 +103,18 @@ func run() int {
 			"scrape.timeout-margin",
 			"Seconds to subtract from the timeout allowed by the client. Tune to allow for overhead or high loads.",
 		).Default("0.5").Float64()
+		minTimeout = app.Flag(
+			"scrape.timeout-min",
+			"Lower bound of the scrape timeout after subtracting the timeout margin.",
+		).Default(httphandler.DefaultMinScrapeTimeout.String()).Duration()
+		maxTimeout = app.Flag(
+			"scrape.timeout-max",
+			"Upper bound of the scrape timeout after subtracting the timeout margin. Longer timeouts requested by clients are capped.",
+		).Default(httphandler.DefaultMaxScrapeTimeout.String()).Duration()
+		maxStaleness = app.Flag(
+			"scrape.max-staleness",
+			"Duration the result of a collection is reused for subsequent scrapes. Concurrent scrapes with the same collectors and timeout always share a single collection.",
+		).Default("0s").Duration()
 		debugEnabled = app.Flag(
 			"debug.enabled",
 			"If true, windows_exporter will expose debug endpoints under /debug/pprof.",
@@ -109,6 +127,78 @@ func run() int {
 			"process.memory-limit",
 			"Limit memory usage in bytes. This is a soft-limit and not guaranteed. 0 means no limit. Read more at https://pkg.go.dev/runtime/debug#SetMemoryLimit .",
 		).Default("200000000").Int64()
+		compressions = app.Flag(
+			"web.compression",
+			"Comma-separated list of content encodings offered for metrics, in order of preference. One or more of zstd, gzip and identity.",
+		).Default("zstd,gzip,identity").String()
+		authTokenFiles = app.Flag(
+			"web.auth.token-files",
+			"Comma-separated list of files containing bearer tokens, one per line. If set, requests require one of the tokens. Files are re-read once they changed.",
+		).Default("").String()
+		authAdminTokenFiles = app.Flag(
+			"web.auth.admin-token-files",
+			"Comma-separated list of files containing bearer tokens for routes requiring the admin level, e.g. /debug/pprof.",
+		).Default("").String()
+		authPolicies = app.Flag(
+			"web.auth.policies",
+			"Comma-separated list of path prefix=level pairs overriding the required credential of routes. Level is one of none, token or admin.",
+		).Default("").String()
+		ipFilterAllow = app.Flag(
+			"web.ip-filter.allow",
+			"Comma-separated list of networks in CIDR notation clients are allowed from. If empty, all clients which are not denied are allowed.",
+		).Default("").String()
+		ipFilterDeny = app.Flag(
+			"web.ip-filter.deny",
+			"Comma-separated list of networks in CIDR notation clients are rejected from. Takes precedence over the allowed networks.",
+		).Default("").String()
+		ipFilterTrustedProxies = app.Flag(
+			"web.ip-filter.trusted-proxies",
+			"Comma-separated list of networks in CIDR notation of proxies, whose X-Forwarded-For header is trusted to determine the client address.",
+		).Default("").String()
+		textfilePushEnabled = app.Flag(
+			"web.textfile-push.enabled",
+			"If true, windows_exporter accepts metrics in the Prometheus text format via PUT/POST /textfile/{job}.",
+		).Default("false").Bool()
+		textfilePushTokenFile = app.Flag(
+			"web.textfile-push.token-file",
+			"File containing the bearer token required to push metrics. Required if pushing metrics is enabled.",
+		).String()
+		textfilePushTTL = app.Flag(
+			"web.textfile-push.ttl",
+			"Duration pushed metrics are exposed for after the last push. 0 means forever.",
+		).Default("1h").Duration()
+		textfilePushMaxSize = app.Flag(
+			"web.textfile-push.max-size",
+			"Maximum size of pushed metrics in bytes.",
+		).Default("16777216").Int64()
+		textfilePushPersistenceDirectory = app.Flag(
+			"web.textfile-push.persistence-directory",
+			"Directory to persist pushed metrics to, so they survive restarts. By default, pushed metrics are only kept in memory.",
+		).Default("").String()
+		probeConfigFile = app.Flag(
+			"web.probe.config-file",
+			"YAML file with the modules of the /probe endpoint, which collects remote targets via MI. The endpoint is disabled, if not set.",
+		).Default("").String()
+		probeMaxConcurrent = app.Flag(
+			"web.probe.max-concurrent",
+			"Maximum number of probes running at the same time. Further requests are rejected.",
+		).Default("5").Int()
+		perfCounterEnabled = app.Flag(
+			"web.perfcounter.enabled",
+			"If true, windows_exporter samples performance counters on request via GET /perfcounter?object=...&counter=...&instance=... . Requires --web.auth.admin-token-files.",
+		).Default("false").Bool()
+		perfCounterMaxConcurrent = app.Flag(
+			"web.perfcounter.max-concurrent",
+			"Maximum number of performance counter queries sampled at the same time. Further requests are rejected.",
+		).Default("2").Int()
+		perfCounterCacheSize = app.Flag(
+			"web.perfcounter.cache-size",
+			"Number of recently used performance counter queries kept open for subsequent requests.",
+		).Default("16").Int()
+		perfCounterCacheTTL = app.Flag(
+			"web.perfcounter.cache-ttl",
+			"Duration an unused performance counter query is kept open.",
+		).Default("5m").Duration()
 	)
 
 	logFile := &log.AllowedFile{}
@@ -127,9 +217,35 @@ func run() int {
 	// Initialize collectors before loading and parsing CLI arguments
 	collectors := collector.NewWithFlags(app)
 
+	// The exporter runs, if no other command is given.
+	app.Command("run", "Run the exporter.").Default().Hidden()
+
+	countersListCmd := app.Command("counters", "Discover performance counters.").
+		Command("list", "List performance counter objects with their counters, counter types and instances.")
+	countersListObject := countersListCmd.Flag(
+		"object",
+		"English or localized name of the performance counter object to list. By default, all objects are listed.",
+	).String()
+	countersListFormat := countersListCmd.Flag(
+		"format",
+		"Output format. text lists the counters and instances, yaml writes a configuration snippet of the performancecounter collector.",
+	).Default(discovery.FormatText).Enum(discovery.Formats...)
+
+	perflogConvertCmd := app.Command("perflog", "Work with performance logs of perfmon, logman or relog.").
+		Command("convert", "Convert a performance log (CSV, TSV or BLG) into OpenMetrics with timestamps, e.g. to backfill it by promtool.")
+	perflogConvertFile := perflogConvertCmd.Arg(
+		"file",
+		"Performance log to convert. BLG files are converted by relog.exe first.",
+	).Required().ExistingFile()
+	perflogConvertOutput := perflogConvertCmd.Flag(
+		"output",
+		"File to write the OpenMetrics to. By default, the output is written to stdout.",
+	).Short('o').String()
+
 	// Load values from configuration file(s). Executable flags must first be parsed, in order
 	// to load the specified file(s).
-	if _, err := app.Parse(os.Args[1:]); err != nil {
+	command, err := app.Parse(os.Args[1:])
+	if err != nil {
 		//nolint:sloglint // we do not have an logger yet
 		slog.Error("Failed to parse CLI args",
 			slog.Any("err", err),
@@ -138,6 +254,17 @@ func run() int {
 		return 1
 	}
 
+	// Accept localized object and counter names, e.g. in the configuration of the performancecounter collector.
+	pdh.SetNameTranslator(registry.LocalNames())
+
+	if command == countersListCmd.FullCommand() {
+		return runCountersList(os.Stdout, *countersListObject, *countersListFormat)
+	}
+
+	if command == perflogConvertCmd.FullCommand() {
+		return runPerflogConvert(ctx, *perflogConvertFile, *perflogConvertOutput)
+	}
+
 	debug.SetMemoryLimit(*memoryLimit)
 
 	logger, err := log.New(logConfig)
@@ -227,14 +354,151 @@ func run() int {
 
 	logger.InfoContext(ctx, "Enabled collectors: "+strings.Join(enabledCollectorList, ", "))
 
+	var additionalCollectors []prometheus.Collector
+
 	mux := http.NewServeMux()
+
+	var httpMetrics *httphandler.HTTPMetrics
+
+	if !*disableExporterMetrics {
+		httpMetrics = httphandler.NewHTTPMetrics(*metricsPath)
+		additionalCollectors = append(additionalCollectors, httpMetrics)
+	}
+
+	var ipFilter *httphandler.IPFilter
+
+	if *ipFilterAllow != "" || *ipFilterDeny != "" {
+		ipFilter, err = newIPFilter(logger, *ipFilterAllow, *ipFilterDeny, *ipFilterTrustedProxies)
+		if err != nil {
+			logger.Error("couldn't initialize IP filter",
+				slog.Any("err", err),
+			)
+
+			return 1
+		}
+
+		additionalCollectors = append(additionalCollectors, ipFilter)
+	}
+
+	if *textfilePushEnabled {
+		pushStore, err := newTextfilePushStore(logger, *textfilePushTokenFile, textfile.PushOptions{
+			TTL:                  *textfilePushTTL,
+			MaxSize:              *textfilePushMaxSize,
+			PersistenceDirectory: *textfilePushPersistenceDirectory,
+		})
+		if err != nil {
+			logger.Error("couldn't initialize textfile push endpoint",
+				slog.Any("err", err),
+			)
+
+			return 1
+		}
+
+		mux.Handle("PUT /textfile/{job}", pushStore)
+		mux.Handle("POST /textfile/{job}", pushStore)
+		mux.Handle("DELETE /textfile/{job}", pushStore)
+
+		additionalCollectors = append(additionalCollectors, pushStore)
+	}
+
 	mux.Handle("GET /health", httphandler.NewHealthHandler())
 	mux.Handle("GET /version", httphandler.NewVersionHandler())
+
+	offeredCompressions, err := httphandler.ParseCompressions(*compressions)
+	if err != nil {
+		logger.Error("couldn't parse --web.compression",
+			slog.Any("err", err),
+		)
+
+		return 1
+	}
+
 	mux.Handle("GET "+*metricsPath, httphandler.New(logger, collectors, &httphandler.Options{
 		DisableExporterMetrics: *disableExporterMetrics,
 		TimeoutMargin:          *timeoutMargin,
+		MinScrapeTimeout:       *minTimeout,
+		MaxScrapeTimeout:       *maxTimeout,
+		MaxStaleness:           *maxStaleness,
+		Compressions:           offeredCompressions,
+		AdditionalCollectors:   additionalCollectors,
 	}))
 
+	if *probeConfigFile != "" {
+		probeConfig, err := probe.LoadConfig(*probeConfigFile)
+		if err != nil {
+			logger.Error("couldn't load probe config file",
+				slog.Any("err", err),
+			)
+
+			return 1
+		}
+
+		// Probes send the credentials of the modules to the targets, so they should only be offered to trusted clients.
+		if *authTokenFiles == "" && *authAdminTokenFiles == "" && *ipFilterAllow == "" {
+			logger.Warn("the probe endpoint is enabled without --web.auth.token-files, --web.auth.admin-token-files or --web.ip-filter.allow, any client can probe the allowed targets")
+		}
+
+		probeSessions, err := probe.NewMISessionFactory()
+		if err != nil {
+			logger.Error("couldn't initialize probe endpoint",
+				slog.Any("err", err),
+			)
+
+			return 1
+		}
+
+		defer func() {
+			if err := probeSessions.Close(); err != nil {
+				logger.Warn("couldn't close probe sessions",
+					slog.Any("err", err),
+				)
+			}
+		}()
+
+		mux.Handle("GET /probe", httphandler.NewProbeHandler(logger, probeConfig, probeSessions, *probeMaxConcurrent, &httphandler.Options{
+			TimeoutMargin:    *timeoutMargin,
+			MinScrapeTimeout: *minTimeout,
+			MaxScrapeTimeout: *maxTimeout,
+			Compressions:     offeredCompressions,
+		}))
+	}
+
+	var authenticator *httphandler.Authenticator
+
+	if *authTokenFiles != "" || *authAdminTokenFiles != "" {
+		authenticator, err = newAuthenticator(logger, *authTokenFiles, *authAdminTokenFiles, *authPolicies)
+		if err != nil {
+			logger.Error("couldn't initialize authentication",
+				slog.Any("err", err),
+			)
+
+			return 1
+		}
+	}
+
+	if *perfCounterEnabled {
+		// The endpoint queries arbitrary performance counters, so it's only offered to authorized clients.
+		// By default, /perfcounter requires an admin token, see httphandler.DefaultAuthPolicies.
+		if authenticator == nil || !authenticator.Accessible("/perfcounter") {
+			logger.Error("couldn't initialize perfcounter endpoint",
+				slog.Any("err", errors.New("--web.perfcounter.enabled requires token files granting access to /perfcounter, i.e. --web.auth.admin-token-files unless --web.auth.policies lowers its level")),
+			)
+
+			return 1
+		}
+
+		perfCounterHandler := httphandler.NewPerfCounterHandler(logger, httphandler.PerfCounterOptions{
+			MaxConcurrent: *perfCounterMaxConcurrent,
+			CacheSize:     *perfCounterCacheSize,
+			CacheTTL:      *perfCounterCacheTTL,
+			Compressions:  offeredCompressions,
+		})
+
+		defer perfCounterHandler.Close()
+
+		mux.Handle("GET /perfcounter", perfCounterHandler)
+	}
+
 	if *debugEnabled {
 		mux.HandleFunc("GET /debug/pprof/", pprof.Index)
 		mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
@@ -252,12 +516,28 @@ func run() int {
 		slog.Int("maxprocs", runtime.GOMAXPROCS(0)),
 	)
 
+	handler := http.Handler(mux)
+
+	if authenticator != nil {
+		handler = authenticator.Middleware(handler)
+	}
+
+	// The IP filter rejects requests before any other processing.
+	if ipFilter != nil {
+		handler = ipFilter.Middleware(handler)
+	}
+
+	// Requests rejected by the IP filter or the authorization are instrumented as well.
+	if httpMetrics != nil {
+		handler = httpMetrics.Middleware(mux, handler)
+	}
+
 	server := &http.Server{
 		ReadHeaderTimeout: 5 * time.Second,
 		IdleTimeout:       60 * time.Second,
 		ReadTimeout:       5 * time.Second,
 		WriteTimeout:      5 * time.Minute,
-		Handler:           mux,
+		Handler:           handler,
 	}
 
 	errCh := make(chan error, 1)
@@ -356,6 +636,71 @@ func setPriorityWindows(logger *slog.Logger, pid int, priority string) error {
 	return nil
 }
 
+// newTextfilePushStore creates the store for the textfile push endpoint.
+func newTextfilePushStore(logger *slog.Logger, tokenFile string, options textfile.PushOptions) (*textfile.PushStore, error) {
+	if tokenFile == "" {
+		return nil, errors.New("--web.textfile-push.token-file is required")
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	options.Token = strings.TrimSpace(string(token))
+
+	return textfile.NewPushStore(logger, options)
+}
+
+// newAuthenticator creates the bearer token authentication middleware from the comma-separated flag values.
+func newAuthenticator(logger *slog.Logger, tokenFiles, adminTokenFiles, policies string) (*httphandler.Authenticator, error) {
+	parsedPolicies, err := httphandler.ParseAuthPolicies(policies)
+	if err != nil {
+		return nil, err
+	}
+
+	return httphandler.NewAuthenticator(logger, httphandler.AuthOptions{
+		TokenFiles:      splitList(tokenFiles),
+		AdminTokenFiles: splitList(adminTokenFiles),
+		Policies:        parsedPolicies,
+	})
+}
+
+// newIPFilter creates the IP filter middleware from the comma-separated flag values.
+func newIPFilter(logger *slog.Logger, allow, deny, trustedProxies string) (*httphandler.IPFilter, error) {
+	var (
+		options httphandler.IPFilterOptions
+		err     error
+	)
+
+	if options.Allow, err = httphandler.ParsePrefixes(allow); err != nil {
+		return nil, fmt.Errorf("--web.ip-filter.allow: %w", err)
+	}
+
+	if options.Deny, err = httphandler.ParsePrefixes(deny); err != nil {
+		return nil, fmt.Errorf("--web.ip-filter.deny: %w", err)
+	}
+
+	if options.TrustedProxies, err = httphandler.ParsePrefixes(trustedProxies); err != nil {
+		return nil, fmt.Errorf("--web.ip-filter.trusted-proxies: %w", err)
+	}
+
+	return httphandler.NewIPFilter(logger, options), nil
+}
+
+// splitList splits a comma-separated list and drops empty entries.
+func splitList(list string) []string {
+	var values []string
+
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
 func expandEnabledCollectors(enabled string) []string {
 	expanded := strings.ReplaceAll(enabled, "[defaults]", collector.DefaultCollectors)
 
@@ -0,0 +1,73 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perflog"
+)
+
+// runPerflogConvert converts a performance log into OpenMetrics, see "windows_exporter perflog convert".
+// The output is written to stdout, if output is empty.
+func runPerflogConvert(ctx context.Context, path, output string) int {
+	var w io.Writer = os.Stdout
+
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create output file: %v\n", err)
+
+			return 1
+		}
+
+		defer f.Close()
+
+		w = f
+	}
+
+	var err error
+
+	if strings.EqualFold(filepath.Ext(path), ".blg") {
+		err = perflog.ConvertBLG(ctx, path, w)
+	} else {
+		err = convertPerflogFile(path, w)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to convert performance log: %v\n", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func convertPerflogFile(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	return perflog.Convert(f, w)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perfdata"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus-community/windows_exporter/internal/utils"
 	"github.com/prometheus/client_golang/prometheus"
@@ -30,15 +31,19 @@ import (
 
 const Name = "cpu"
 
-type Config struct{}
+type Config struct {
+	Backend string `yaml:"backend"`
+}
 
 //nolint:gochecknoglobals
-var ConfigDefaults = Config{}
+var ConfigDefaults = Config{
+	Backend: perfdata.BackendAuto,
+}
 
 type Collector struct {
 	config Config
 
-	perfDataCollector *pdh.Collector
+	perfDataCollector perfdata.Collector
 	perfDataObject    []perfDataCounterValues
 
 	mu sync.Mutex
@@ -74,8 +79,17 @@ func New(config *Config) *Collector {
 	return c
 }
 
-func NewWithFlags(_ *kingpin.Application) *Collector {
-	return &Collector{}
+func NewWithFlags(app *kingpin.Application) *Collector {
+	c := &Collector{
+		config: ConfigDefaults,
+	}
+
+	app.Flag(
+		"collector.cpu.backend",
+		"Backend reading the Processor Information performance counters: auto, pdh or registry. auto falls back to the registry, if PDH does not know the object.",
+	).Default(ConfigDefaults.Backend).EnumVar(&c.config.Backend, perfdata.Backends...)
+
+	return c
 }
 
 func (c *Collector) GetName() string {
@@ -93,7 +107,7 @@ func (c *Collector) Build(_ *slog.Logger, _ *mi.Session) error {
 
 	c.mu = sync.Mutex{}
 
-	c.perfDataCollector, err = pdh.NewCollector[perfDataCounterValues]("Processor Information", pdh.InstancesAll)
+	c.perfDataCollector, err = perfdata.NewCollector[perfDataCounterValues](c.config.Backend, "Processor Information", pdh.InstancesAll)
 	if err != nil {
 		return fmt.Errorf("failed to create Processor Information collector: %w", err)
 	}
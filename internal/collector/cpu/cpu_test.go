@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/prometheus-community/windows_exporter/internal/collector/cpu"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
 	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
 )
 
@@ -29,3 +30,23 @@ func BenchmarkCollector(b *testing.B) {
 func TestCollector(t *testing.T) {
 	testutils.TestCollector(t, cpu.New, nil)
 }
+
+func TestBackendParity(t *testing.T) {
+	testutils.TestBackendParity[cpu.PerfDataCounterValues](t, "Processor Information", pdh.InstancesAll, map[string]uint32{
+		"% C1 Time":               pdh.PERF_100NSEC_TIMER,
+		"% C2 Time":               pdh.PERF_100NSEC_TIMER,
+		"% C3 Time":               pdh.PERF_100NSEC_TIMER,
+		"C1 Transitions/sec":      pdh.PERF_COUNTER_BULK_COUNT,
+		"Clock Interrupts/sec":    pdh.PERF_COUNTER_COUNTER,
+		"% DPC Time":              pdh.PERF_100NSEC_TIMER,
+		"% Idle Time":             pdh.PERF_100NSEC_TIMER,
+		"% Interrupt Time":        pdh.PERF_100NSEC_TIMER,
+		"% Priority Time":         pdh.PERF_100NSEC_TIMER_INV,
+		"% Privileged Time":       pdh.PERF_100NSEC_TIMER,
+		"% Privileged Utility":    pdh.PERF_AVERAGE_BULK,
+		"% Processor Performance": pdh.PERF_AVERAGE_BULK,
+		"% Processor Time":        pdh.PERF_100NSEC_TIMER_INV,
+		"% Processor Utility":     pdh.PERF_AVERAGE_BULK,
+		"% User Time":             pdh.PERF_100NSEC_TIMER,
+	})
+}
@@ -177,55 +177,59 @@ func (c *Collector) Build(_ *slog.Logger, _ *mi.Session) error {
 		subCollectorADAccessProcesses: {
 			build:   c.buildADAccessProcesses,
 			collect: c.collectADAccessProcesses,
-			close:   c.perfDataCollectorADAccessProcesses.Close,
+			close:   func() { c.perfDataCollectorADAccessProcesses.Close() },
 		},
 		subCollectorTransportQueues: {
 			build:   c.buildTransportQueues,
 			collect: c.collectTransportQueues,
-			close:   c.perfDataCollectorTransportQueues.Close,
+			close:   func() { c.perfDataCollectorTransportQueues.Close() },
 		},
 		subCollectorHttpProxy: {
 			build:   c.buildHTTPProxy,
 			collect: c.collectHTTPProxy,
-			close:   c.perfDataCollectorHTTPProxy.Close,
+			close:   func() { c.perfDataCollectorHTTPProxy.Close() },
 		},
 		subCollectorActiveSync: {
 			build:   c.buildActiveSync,
 			collect: c.collectActiveSync,
-			close:   c.perfDataCollectorActiveSync.Close,
+			close:   func() { c.perfDataCollectorActiveSync.Close() },
 		},
 		subCollectorAvailabilityService: {
 			build:   c.buildAvailabilityService,
 			collect: c.collectAvailabilityService,
-			close:   c.perfDataCollectorAvailabilityService.Close,
+			close:   func() { c.perfDataCollectorAvailabilityService.Close() },
 		},
 		subCollectorOutlookWebAccess: {
 			build:   c.buildOWA,
 			collect: c.collectOWA,
-			close:   c.perfDataCollectorOWA.Close,
+			close:   func() { c.perfDataCollectorOWA.Close() },
 		},
 		subCollectorAutoDiscover: {
 			build:   c.buildAutoDiscover,
 			collect: c.collectAutoDiscover,
-			close:   c.perfDataCollectorAutoDiscover.Close,
+			close:   func() { c.perfDataCollectorAutoDiscover.Close() },
 		},
 		subCollectorWorkloadManagement: {
 			build:   c.buildWorkloadManagementWorkloads,
 			collect: c.collectWorkloadManagementWorkloads,
-			close:   c.perfDataCollectorWorkloadManagementWorkloads.Close,
+			close:   func() { c.perfDataCollectorWorkloadManagementWorkloads.Close() },
 		},
 		subCollectorRpcClientAccess: {
 			build:   c.buildRpcClientAccess,
 			collect: c.collectRpcClientAccess,
-			close:   c.perfDataCollectorRpcClientAccess.Close,
+			close:   func() { c.perfDataCollectorRpcClientAccess.Close() },
 		},
 		subCollectorMapiHttpEmsmdb: {
 			build:   c.buildMapiHttpEmsmdb,
 			collect: c.collectMapiHttpEmsmdb,
-			close:   c.perfDataCollectorMapiHttpEmsmdb.Close,
+			close:   func() { c.perfDataCollectorMapiHttpEmsmdb.Close() },
 		},
 	}
 
+	// Build may be called again to rebuild the collector, once its performance counters became stale.
+	c.collectorFns = make([]func(ch chan<- prometheus.Metric) error, 0, len(c.config.CollectorsEnabled))
+	c.closeFns = make([]func(), 0, len(c.config.CollectorsEnabled))
+
 	errs := make([]error, 0, len(c.config.CollectorsEnabled))
 
 	for _, name := range c.config.CollectorsEnabled {
@@ -0,0 +1,344 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus-community/windows_exporter/internal/collector/textfile"
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/yaml.v3"
+)
+
+const Name = "exec"
+
+type Config struct {
+	Commands []Command `yaml:"commands"`
+}
+
+//nolint:gochecknoglobals
+var ConfigDefaults = Config{
+	Commands: make([]Command, 0),
+}
+
+// A Collector is a Prometheus Collector for metrics written to stdout by local commands.
+type Collector struct {
+	config Config
+	logger *slog.Logger
+
+	commands []*scheduledCommand
+
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	durationDesc *prometheus.Desc
+	exitCodeDesc *prometheus.Desc
+	successDesc  *prometheus.Desc
+}
+
+// scheduledCommand holds the last result of a command.
+// Commands with an interval are executed in the background, all others on every scrape.
+type scheduledCommand struct {
+	Command
+
+	mu       sync.Mutex
+	executed bool
+	result   result
+	families []*dto.MetricFamily
+}
+
+func New(config *Config) *Collector {
+	if config == nil {
+		config = &ConfigDefaults
+	}
+
+	if config.Commands == nil {
+		config.Commands = ConfigDefaults.Commands
+	}
+
+	c := &Collector{
+		config: *config,
+	}
+
+	return c
+}
+
+func NewWithFlags(app *kingpin.Application) *Collector {
+	c := &Collector{
+		config: ConfigDefaults,
+	}
+
+	var commands string
+
+	app.Flag(
+		"collector.exec.commands",
+		"Commands to execute. The value takes the form of a YAML array. See docs for more information on how to use this flag. By default, no commands are executed.",
+	).Default("").StringVar(&commands)
+
+	app.Action(func(*kingpin.ParseContext) error {
+		if commands == "" {
+			return nil
+		}
+
+		if err := yaml.Unmarshal([]byte(commands), &c.config.Commands); err != nil {
+			return fmt.Errorf("failed to parse commands %s: %w", commands, err)
+		}
+
+		return nil
+	})
+
+	return c
+}
+
+func (c *Collector) GetName() string {
+	return Name
+}
+
+func (c *Collector) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.wg.Wait()
+
+	return nil
+}
+
+func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
+	c.logger = logger.With(slog.String("collector", Name))
+
+	c.durationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "duration_seconds"),
+		"Duration of the last command execution.",
+		[]string{"command"},
+		nil,
+	)
+	c.exitCodeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "exit_code"),
+		"Exit code of the last command execution. -1 if the command could not be executed or timed out.",
+		[]string{"command"},
+		nil,
+	)
+	c.successDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "success"),
+		"Whether the last command execution was successful and its output could be parsed.",
+		[]string{"command"},
+		nil,
+	)
+
+	c.commands = make([]*scheduledCommand, 0, len(c.config.Commands))
+	names := make([]string, 0, len(c.config.Commands))
+
+	var errs []error
+
+	for _, command := range c.config.Commands {
+		if command.Name == "" {
+			errs = append(errs, errors.New("command name is required"))
+
+			continue
+		}
+
+		if command.Command == "" {
+			errs = append(errs, fmt.Errorf("command %s: command is required", command.Name))
+
+			continue
+		}
+
+		if slices.Contains(names, command.Name) {
+			errs = append(errs, fmt.Errorf("command %s: name is duplicated", command.Name))
+
+			continue
+		}
+
+		if command.Timeout <= 0 {
+			command.Timeout = defaultTimeout
+		}
+
+		if command.MaxOutputSize <= 0 {
+			command.MaxOutputSize = defaultMaxOutputSize
+		}
+
+		if command.Interval > 0 && command.Timeout > command.Interval {
+			command.Timeout = command.Interval
+		}
+
+		names = append(names, command.Name)
+		c.commands = append(c.commands, &scheduledCommand{Command: command})
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	for _, command := range c.commands {
+		if command.Interval <= 0 {
+			continue
+		}
+
+		c.wg.Add(1)
+
+		go c.schedule(command)
+	}
+
+	return nil
+}
+
+// schedule executes the command immediately and then on every interval until the collector is closed.
+func (c *Collector) schedule(command *scheduledCommand) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(command.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.execute(c.ctx, command)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// execute runs the command and stores its result and parsed metrics.
+func (c *Collector) execute(ctx context.Context, command *scheduledCommand) {
+	res := run(ctx, command.Command)
+
+	var families []*dto.MetricFamily
+
+	if res.err == nil {
+		var err error
+
+		families, err = textfile.ParseMetricFamilies(bytes.NewReader(res.output), "exec command "+command.Name)
+		if err != nil {
+			res.err = fmt.Errorf("failed to parse output: %w", err)
+		}
+	}
+
+	if res.err != nil {
+		c.logger.Warn("command failed",
+			slog.String("command", command.Name),
+			slog.Any("err", res.err),
+		)
+	}
+
+	command.mu.Lock()
+	defer command.mu.Unlock()
+
+	command.executed = true
+	command.result = res
+	command.families = families
+}
+
+// Collect sends the metric values for each metric
+// to the provided prometheus Metric channel.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) error {
+	return c.CollectContext(c.ctx, ch)
+}
+
+// CollectContext is like Collect, but commands executed on scrape are killed once ctx is done.
+func (c *Collector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	wg := sync.WaitGroup{}
+
+	for _, command := range c.commands {
+		if command.Interval > 0 {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(command *scheduledCommand) {
+			defer wg.Done()
+
+			c.execute(ctx, command)
+		}(command)
+	}
+
+	wg.Wait()
+
+	var (
+		errs           []error
+		metricFamilies []*dto.MetricFamily
+	)
+
+	for _, command := range c.commands {
+		command.mu.Lock()
+		executed, res, families := command.executed, command.result, command.families
+		command.mu.Unlock()
+
+		// Scheduled commands which did not finish their first execution yet.
+		if !executed {
+			continue
+		}
+
+		success := 1.0
+
+		if res.err != nil {
+			success = 0.0
+
+			errs = append(errs, fmt.Errorf("command %s: %w", command.Name, res.err))
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.durationDesc,
+			prometheus.GaugeValue,
+			res.duration.Seconds(),
+			command.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.exitCodeDesc,
+			prometheus.GaugeValue,
+			float64(res.exitCode),
+			command.Name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.successDesc,
+			prometheus.GaugeValue,
+			success,
+			command.Name,
+		)
+
+		metricFamilies = append(metricFamilies, families...)
+	}
+
+	// If duplicates are detected across *multiple* commands, skip all command metrics.
+	if textfile.DuplicateMetricEntry(metricFamilies) {
+		errs = append(errs, errors.New("duplicate metrics detected across multiple commands"))
+	} else {
+		for _, mf := range metricFamilies {
+			textfile.ConvertMetricFamily(c.logger, mf, ch)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	osexec "os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout       = 30 * time.Second
+	defaultMaxOutputSize = 10 << 20 // 10 MiB
+
+	// maxStderrSize limits the amount of stderr output kept for error messages.
+	maxStderrSize = 4096
+)
+
+var errOutputTooLarge = errors.New("output exceeds max_output_size")
+
+// Command is a command executed by the exec collector.
+// The standard output of the command is parsed as Prometheus text format.
+type Command struct {
+	Name          string        `yaml:"name"`
+	Command       string        `yaml:"command"`
+	Args          []string      `yaml:"args"`
+	WorkingDir    string        `yaml:"working_dir"`
+	Timeout       time.Duration `yaml:"timeout"`
+	Interval      time.Duration `yaml:"interval"`
+	MaxOutputSize int64         `yaml:"max_output_size"`
+}
+
+// result holds the outcome of a single command execution.
+type result struct {
+	output   []byte
+	duration time.Duration
+	exitCode int
+	err      error
+}
+
+// limitedBuffer is a bytes.Buffer that stops storing data after limit bytes.
+// Writes never fail, so the command is not blocked by a full pipe.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - int64(b.buf.Len())
+	if int64(len(p)) > remaining {
+		b.exceeded = true
+
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+// run executes the command and returns its output, duration and exit code.
+// A command exceeding its timeout is killed.
+func run(ctx context.Context, command Command) result {
+	ctx, cancel := context.WithTimeout(ctx, command.Timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: command.MaxOutputSize}
+	stderr := &limitedBuffer{limit: maxStderrSize}
+
+	cmd := osexec.CommandContext(ctx, command.Command, command.Args...)
+	cmd.Dir = command.WorkingDir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Child processes may inherit the output pipes and keep them open after the command was killed.
+	cmd.WaitDelay = time.Second
+
+	startTime := time.Now()
+	err := cmd.Run()
+
+	res := result{
+		output:   stdout.buf.Bytes(),
+		duration: time.Since(startTime),
+	}
+
+	var exitErr *osexec.ExitError
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res.exitCode = -1
+		res.err = fmt.Errorf("command timed out after %s", command.Timeout)
+	case errors.As(err, &exitErr):
+		res.exitCode = exitErr.ExitCode()
+		res.err = fmt.Errorf("command exited with code %d", res.exitCode)
+
+		if msg := strings.TrimSpace(stderr.buf.String()); msg != "" {
+			res.err = fmt.Errorf("%w: %s", res.err, msg)
+		}
+	case err != nil:
+		res.exitCode = -1
+		res.err = fmt.Errorf("failed to execute command: %w", err)
+	case stdout.exceeded:
+		res.err = fmt.Errorf("%w of %d bytes", errOutputTooLarge, command.MaxOutputSize)
+	}
+
+	return res
+}
@@ -0,0 +1,167 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain turns the test binary into a small helper command if requested.
+// This allows to test the command execution without depending on binaries of the host.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("WINDOWS_EXPORTER_EXEC_HELPER"); mode != "" {
+		os.Exit(helper(mode))
+	}
+
+	os.Exit(m.Run())
+}
+
+func helper(mode string) int {
+	switch mode {
+	case "metrics":
+		_, _ = os.Stdout.WriteString("# TYPE test_metric gauge\ntest_metric{source=\"helper\"} 42\n")
+
+		return 0
+	case "exit":
+		_, _ = os.Stderr.WriteString("something went wrong\n")
+
+		return 3
+	case "sleep":
+		time.Sleep(time.Minute)
+
+		return 0
+	case "large":
+		_, _ = os.Stdout.WriteString(strings.Repeat("#\n", 1024))
+
+		return 0
+	case "pwd":
+		wd, _ := os.Getwd()
+		_, _ = os.Stdout.WriteString(wd)
+
+		return 0
+	case "args":
+		_, _ = os.Stdout.WriteString(strings.Join(os.Args[1:], ","))
+
+		return 0
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "unknown helper mode %q\n", mode)
+
+		return 1
+	}
+}
+
+func helperCommand(t *testing.T, mode string) Command {
+	t.Helper()
+
+	t.Setenv("WINDOWS_EXPORTER_EXEC_HELPER", mode)
+
+	executable, err := os.Executable()
+	require.NoError(t, err)
+
+	return Command{
+		Name:          mode,
+		Command:       executable,
+		Timeout:       10 * time.Second,
+		MaxOutputSize: defaultMaxOutputSize,
+	}
+}
+
+//nolint:paralleltest
+func TestRunSuccess(t *testing.T) {
+	res := run(context.Background(), helperCommand(t, "metrics"))
+
+	require.NoError(t, res.err)
+	assert.Equal(t, 0, res.exitCode)
+	assert.Positive(t, res.duration)
+	assert.Contains(t, string(res.output), `test_metric{source="helper"} 42`)
+}
+
+//nolint:paralleltest
+func TestRunExitCode(t *testing.T) {
+	res := run(context.Background(), helperCommand(t, "exit"))
+
+	require.ErrorContains(t, res.err, "command exited with code 3: something went wrong")
+	assert.Equal(t, 3, res.exitCode)
+}
+
+//nolint:paralleltest
+func TestRunTimeout(t *testing.T) {
+	command := helperCommand(t, "sleep")
+	command.Timeout = 200 * time.Millisecond
+
+	res := run(context.Background(), command)
+
+	require.ErrorContains(t, res.err, "command timed out after 200ms")
+	assert.Equal(t, -1, res.exitCode)
+	assert.Less(t, res.duration, 10*time.Second)
+}
+
+//nolint:paralleltest
+func TestRunMaxOutputSize(t *testing.T) {
+	command := helperCommand(t, "large")
+	command.MaxOutputSize = 100
+
+	res := run(context.Background(), command)
+
+	require.ErrorIs(t, res.err, errOutputTooLarge)
+	assert.Equal(t, 0, res.exitCode)
+	assert.Len(t, res.output, 100)
+}
+
+//nolint:paralleltest
+func TestRunWorkingDirAndArgs(t *testing.T) {
+	dir := t.TempDir()
+
+	command := helperCommand(t, "pwd")
+	command.WorkingDir = dir
+
+	res := run(context.Background(), command)
+	require.NoError(t, res.err)
+
+	expected, err := os.Stat(dir)
+	require.NoError(t, err)
+
+	actual, err := os.Stat(string(res.output))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(expected, actual))
+
+	command = helperCommand(t, "args")
+	command.Args = []string{"a", "b", strconv.Itoa(1)}
+
+	res = run(context.Background(), command)
+	require.NoError(t, res.err)
+	assert.Equal(t, "a,b,1", string(res.output))
+}
+
+//nolint:paralleltest
+func TestRunNotFound(t *testing.T) {
+	res := run(context.Background(), Command{
+		Name:          "missing",
+		Command:       "windows_exporter_command_does_not_exist",
+		Timeout:       time.Second,
+		MaxOutputSize: defaultMaxOutputSize,
+	})
+
+	require.ErrorContains(t, res.err, "failed to execute command")
+	assert.Equal(t, -1, res.exitCode)
+}
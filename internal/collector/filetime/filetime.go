@@ -16,37 +16,98 @@
 package filetime
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
 )
 
 const Name = "filetime"
 
+const (
+	// metricsFile enables metrics per file.
+	metricsFile = "file"
+	// metricsPattern enables aggregated metrics per pattern.
+	metricsPattern = "pattern"
+	// metricsDirectory enables aggregated metrics per directory.
+	metricsDirectory = "directory"
+)
+
 type Config struct {
 	FilePatterns []string
+	Patterns     []Pattern `yaml:"patterns"`
+}
+
+// Pattern is a glob pattern with its own set of metrics.
+type Pattern struct {
+	Pattern string `yaml:"pattern"`
+	// Metrics is the list of enabled metric groups. One or more of file, pattern and directory. Defaults to file.
+	Metrics []string `yaml:"metrics"`
 }
 
 //nolint:gochecknoglobals
 var ConfigDefaults = Config{
 	FilePatterns: []string{},
+	Patterns:     []Pattern{},
 }
 
 // A Collector is a Prometheus Collector for collecting file times.
 type Collector struct {
 	config Config
 
-	logger    *slog.Logger
-	fileMTime *prometheus.Desc
+	logger   *slog.Logger
+	patterns []Pattern
+
+	fileMTime        *prometheus.Desc
+	fileCreationTime *prometheus.Desc
+	fileSize         *prometheus.Desc
+
+	patternFiles      *prometheus.Desc
+	patternSize       *prometheus.Desc
+	patternOldestTime *prometheus.Desc
+	patternNewestTime *prometheus.Desc
+
+	directoryFiles      *prometheus.Desc
+	directorySize       *prometheus.Desc
+	directoryOldestTime *prometheus.Desc
+	directoryNewestTime *prometheus.Desc
+}
+
+// fileStats aggregates the files matched by a pattern.
+type fileStats struct {
+	files  int
+	size   int64
+	oldest time.Time
+	newest time.Time
+}
+
+func (s *fileStats) add(fileInfo os.FileInfo) {
+	modTime := fileInfo.ModTime()
+
+	if s.files == 0 || modTime.Before(s.oldest) {
+		s.oldest = modTime
+	}
+
+	if s.files == 0 || modTime.After(s.newest) {
+		s.newest = modTime
+	}
+
+	s.files++
+	s.size += fileInfo.Size()
 }
 
 func New(config *Config) *Collector {
@@ -58,6 +119,10 @@ func New(config *Config) *Collector {
 		config.FilePatterns = ConfigDefaults.FilePatterns
 	}
 
+	if config.Patterns == nil {
+		config.Patterns = ConfigDefaults.Patterns
+	}
+
 	c := &Collector{
 		config: *config,
 	}
@@ -70,18 +135,32 @@ func NewWithFlags(app *kingpin.Application) *Collector {
 		config: ConfigDefaults,
 	}
 	c.config.FilePatterns = make([]string, 0)
+	c.config.Patterns = make([]Pattern, 0)
 
-	var filePatterns string
+	var filePatterns, patterns string
 
 	app.Flag(
 		"collector.filetime.file-patterns",
 		"Comma-separated list of file patterns. Each pattern is a glob pattern that can contain `*`, `?`, and `**` (recursive). See https://github.com/bmatcuk/doublestar#patterns",
 	).Default(strings.Join(ConfigDefaults.FilePatterns, ",")).StringVar(&filePatterns)
 
+	app.Flag(
+		"collector.filetime.patterns",
+		"File patterns with the list of enabled metric groups. The value takes the form of a YAML array. See docs for more information on how to use this flag.",
+	).Default("").StringVar(&patterns)
+
 	app.Action(func(*kingpin.ParseContext) error {
 		// doublestar.Glob() requires forward slashes
 		c.config.FilePatterns = strings.Split(filepath.ToSlash(filePatterns), ",")
 
+		if patterns == "" {
+			return nil
+		}
+
+		if err := yaml.Unmarshal([]byte(patterns), &c.config.Patterns); err != nil {
+			return fmt.Errorf("failed to parse patterns %s: %w", patterns, err)
+		}
+
 		return nil
 	})
 
@@ -107,17 +186,104 @@ func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 		[]string{"file"},
 		nil,
 	)
+	c.fileCreationTime = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "creation_timestamp_seconds"),
+		"File creation time",
+		[]string{"file"},
+		nil,
+	)
+	c.fileSize = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "size_bytes"),
+		"File size",
+		[]string{"file"},
+		nil,
+	)
+	c.patternFiles = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "pattern_files"),
+		"Number of files matching the pattern",
+		[]string{"pattern"},
+		nil,
+	)
+	c.patternSize = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "pattern_size_bytes"),
+		"Total size of files matching the pattern",
+		[]string{"pattern"},
+		nil,
+	)
+	c.patternOldestTime = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "pattern_oldest_mtime_timestamp_seconds"),
+		"Modification time of the oldest file matching the pattern",
+		[]string{"pattern"},
+		nil,
+	)
+	c.patternNewestTime = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "pattern_newest_mtime_timestamp_seconds"),
+		"Modification time of the newest file matching the pattern",
+		[]string{"pattern"},
+		nil,
+	)
+	c.directoryFiles = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "directory_files"),
+		"Number of files matching the pattern per directory",
+		[]string{"pattern", "directory"},
+		nil,
+	)
+	c.directorySize = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "directory_size_bytes"),
+		"Total size of files matching the pattern per directory",
+		[]string{"pattern", "directory"},
+		nil,
+	)
+	c.directoryOldestTime = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "directory_oldest_mtime_timestamp_seconds"),
+		"Modification time of the oldest file matching the pattern per directory",
+		[]string{"pattern", "directory"},
+		nil,
+	)
+	c.directoryNewestTime = prometheus.NewDesc(
+		prometheus.BuildFQName(types.Namespace, Name, "directory_newest_mtime_timestamp_seconds"),
+		"Modification time of the newest file matching the pattern per directory",
+		[]string{"pattern", "directory"},
+		nil,
+	)
+
+	c.patterns = make([]Pattern, 0, len(c.config.FilePatterns)+len(c.config.Patterns))
 
 	for _, filePattern := range c.config.FilePatterns {
-		basePath, pattern := doublestar.SplitPattern(filePattern)
+		if filePattern == "" {
+			continue
+		}
 
-		_, err := doublestar.Glob(os.DirFS(basePath), pattern, doublestar.WithFilesOnly())
+		c.patterns = append(c.patterns, Pattern{Pattern: filePattern})
+	}
+
+	c.patterns = append(c.patterns, c.config.Patterns...)
+
+	var errs []error
+
+	for i, pattern := range c.patterns {
+		// doublestar.Glob() requires forward slashes
+		c.patterns[i].Pattern = filepath.ToSlash(pattern.Pattern)
+
+		if len(pattern.Metrics) == 0 {
+			c.patterns[i].Metrics = []string{metricsFile}
+		}
+
+		for _, metrics := range c.patterns[i].Metrics {
+			if !slices.Contains([]string{metricsFile, metricsPattern, metricsDirectory}, metrics) {
+				errs = append(errs, fmt.Errorf("pattern %s: unknown metrics %q", pattern.Pattern, metrics))
+			}
+		}
+
+		basePath, globPattern := doublestar.SplitPattern(c.patterns[i].Pattern)
+
+		_, err := doublestar.Glob(os.DirFS(basePath), globPattern, doublestar.WithFilesOnly())
 		if err != nil {
-			return fmt.Errorf("invalid glob pattern: %w", err)
+			errs = append(errs, fmt.Errorf("invalid glob pattern: %w", err))
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // Collect sends the metric values for each metric
@@ -125,19 +291,19 @@ func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 func (c *Collector) Collect(ch chan<- prometheus.Metric) error {
 	wg := sync.WaitGroup{}
 
-	for _, filePattern := range c.config.FilePatterns {
+	for _, pattern := range c.patterns {
 		wg.Add(1)
 
-		go func(filePattern string) {
+		go func(pattern Pattern) {
 			defer wg.Done()
 
-			if err := c.collectGlobFilePath(ch, filePattern); err != nil {
+			if err := c.collectGlobFilePath(ch, pattern); err != nil {
 				c.logger.Error("failed collecting metrics for filepath",
-					slog.String("filepath", filePattern),
+					slog.String("filepath", pattern.Pattern),
 					slog.Any("err", err),
 				)
 			}
-		}(filePattern)
+		}(pattern)
 	}
 
 	wg.Wait()
@@ -145,15 +311,19 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
-func (c *Collector) collectGlobFilePath(ch chan<- prometheus.Metric, filePattern string) error {
-	basePath, pattern := doublestar.SplitPattern(filePattern)
+func (c *Collector) collectGlobFilePath(ch chan<- prometheus.Metric, pattern Pattern) error {
+	basePath, globPattern := doublestar.SplitPattern(pattern.Pattern)
 	basePathFS := os.DirFS(basePath)
 
-	matches, err := doublestar.Glob(basePathFS, pattern, doublestar.WithFilesOnly())
+	matches, err := doublestar.Glob(basePathFS, globPattern, doublestar.WithFilesOnly())
 	if err != nil {
 		return fmt.Errorf("failed to glob: %w", err)
 	}
 
+	fileMetrics := slices.Contains(pattern.Metrics, metricsFile)
+	patternStats := fileStats{}
+	directoryStats := map[string]*fileStats{}
+
 	for _, match := range matches {
 		filePath := filepath.Join(basePath, match)
 
@@ -167,13 +337,102 @@ func (c *Collector) collectGlobFilePath(ch chan<- prometheus.Metric, filePattern
 			continue
 		}
 
+		patternStats.add(fileInfo)
+
+		directory := filepath.Dir(filePath)
+		if _, ok := directoryStats[directory]; !ok {
+			directoryStats[directory] = &fileStats{}
+		}
+
+		directoryStats[directory].add(fileInfo)
+
+		if !fileMetrics {
+			continue
+		}
+
 		ch <- prometheus.MustNewConstMetric(
 			c.fileMTime,
 			prometheus.GaugeValue,
 			float64(fileInfo.ModTime().UTC().Unix()),
 			filePath,
 		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.fileSize,
+			prometheus.GaugeValue,
+			float64(fileInfo.Size()),
+			filePath,
+		)
+
+		if creationTime, ok := getCreationTime(fileInfo); ok {
+			ch <- prometheus.MustNewConstMetric(
+				c.fileCreationTime,
+				prometheus.GaugeValue,
+				float64(creationTime.UTC().Unix()),
+				filePath,
+			)
+		}
+	}
+
+	if slices.Contains(pattern.Metrics, metricsPattern) {
+		c.collectFileStats(ch, patternStats, c.patternFiles, c.patternSize, c.patternOldestTime, c.patternNewestTime, pattern.Pattern)
+	}
+
+	if slices.Contains(pattern.Metrics, metricsDirectory) {
+		for _, directory := range slices.Sorted(maps.Keys(directoryStats)) {
+			c.collectFileStats(ch, *directoryStats[directory], c.directoryFiles, c.directorySize, c.directoryOldestTime, c.directoryNewestTime, pattern.Pattern, directory)
+		}
 	}
 
 	return nil
 }
+
+// collectFileStats sends the aggregated metrics of stats. The timestamps are only sent if at least one file matched.
+func (c *Collector) collectFileStats(
+	ch chan<- prometheus.Metric,
+	stats fileStats,
+	filesDesc, sizeDesc, oldestDesc, newestDesc *prometheus.Desc,
+	labelValues ...string,
+) {
+	ch <- prometheus.MustNewConstMetric(
+		filesDesc,
+		prometheus.GaugeValue,
+		float64(stats.files),
+		labelValues...,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		sizeDesc,
+		prometheus.GaugeValue,
+		float64(stats.size),
+		labelValues...,
+	)
+
+	if stats.files == 0 {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(
+		oldestDesc,
+		prometheus.GaugeValue,
+		float64(stats.oldest.UTC().Unix()),
+		labelValues...,
+	)
+
+	ch <- prometheus.MustNewConstMetric(
+		newestDesc,
+		prometheus.GaugeValue,
+		float64(stats.newest.UTC().Unix()),
+		labelValues...,
+	)
+}
+
+// getCreationTime returns the creation time of the file, if the file system provides it.
+func getCreationTime(fileInfo os.FileInfo) (time.Time, bool) {
+	attributes, ok := fileInfo.Sys().(*syscall.Win32FileAttributeData) //nolint:forbidigo // os.Stat returns syscall types
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, attributes.CreationTime.Nanoseconds()), true
+}
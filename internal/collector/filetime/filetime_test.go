@@ -16,10 +16,21 @@
 package filetime_test
 
 import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus-community/windows_exporter/internal/collector/filetime"
 	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func BenchmarkCollector(b *testing.B) {
@@ -31,3 +42,137 @@ func TestCollector(t *testing.T) {
 		FilePatterns: []string{"*.*"},
 	})
 }
+
+type collectorAdapter struct {
+	*filetime.Collector
+}
+
+// Describe implements the prometheus.Collector interface.
+func (a collectorAdapter) Describe(_ chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface.
+func (a collectorAdapter) Collect(ch chan<- prometheus.Metric) {
+	if err := a.Collector.Collect(ch); err != nil {
+		panic(fmt.Sprintf("failed to update collector: %v", err))
+	}
+}
+
+func writeTestFile(t *testing.T, path string, size int, mTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o600))
+	require.NoError(t, os.Chtimes(path, mTime, mTime))
+}
+
+func newTestCollector(t *testing.T, config *filetime.Config) collectorAdapter {
+	t.Helper()
+
+	c := filetime.New(config)
+	require.NoError(t, c.Build(slog.New(slog.NewTextHandler(io.Discard, nil)), nil))
+
+	return collectorAdapter{c}
+}
+
+func TestFileMetrics(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.log")
+
+	writeTestFile(t, file, 10, time.Unix(1700000000, 0))
+
+	c := newTestCollector(t, &filetime.Config{
+		FilePatterns: []string{filepath.Join(dir, "*.log")},
+	})
+
+	expected := fmt.Sprintf(`
+# HELP windows_filetime_mtime_timestamp_seconds File modification time
+# TYPE windows_filetime_mtime_timestamp_seconds gauge
+windows_filetime_mtime_timestamp_seconds{file=%[1]q} 1.7e+09
+# HELP windows_filetime_size_bytes File size
+# TYPE windows_filetime_size_bytes gauge
+windows_filetime_size_bytes{file=%[1]q} 10
+`, file)
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected),
+		"windows_filetime_mtime_timestamp_seconds",
+		"windows_filetime_size_bytes",
+	))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(c, "windows_filetime_creation_timestamp_seconds"))
+	assert.Equal(t, 0, testutil.CollectAndCount(c, "windows_filetime_pattern_files"))
+}
+
+func TestAggregateMetrics(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	writeTestFile(t, filepath.Join(dir, "a", "1.log"), 10, time.Unix(1600000000, 0))
+	writeTestFile(t, filepath.Join(dir, "a", "2.log"), 20, time.Unix(1700000000, 0))
+	writeTestFile(t, filepath.Join(dir, "b", "3.log"), 30, time.Unix(1800000000, 0))
+	writeTestFile(t, filepath.Join(dir, "b", "4.txt"), 40, time.Unix(1900000000, 0))
+
+	pattern := filepath.ToSlash(filepath.Join(dir, "**", "*.log"))
+	emptyPattern := filepath.ToSlash(filepath.Join(dir, "*.log"))
+
+	c := newTestCollector(t, &filetime.Config{
+		Patterns: []filetime.Pattern{
+			{Pattern: pattern, Metrics: []string{"pattern", "directory"}},
+			{Pattern: emptyPattern, Metrics: []string{"pattern"}},
+		},
+	})
+
+	expected := fmt.Sprintf(`
+# HELP windows_filetime_directory_files Number of files matching the pattern per directory
+# TYPE windows_filetime_directory_files gauge
+windows_filetime_directory_files{directory=%[2]q,pattern=%[1]q} 2
+windows_filetime_directory_files{directory=%[3]q,pattern=%[1]q} 1
+# HELP windows_filetime_directory_newest_mtime_timestamp_seconds Modification time of the newest file matching the pattern per directory
+# TYPE windows_filetime_directory_newest_mtime_timestamp_seconds gauge
+windows_filetime_directory_newest_mtime_timestamp_seconds{directory=%[2]q,pattern=%[1]q} 1.7e+09
+windows_filetime_directory_newest_mtime_timestamp_seconds{directory=%[3]q,pattern=%[1]q} 1.8e+09
+# HELP windows_filetime_directory_oldest_mtime_timestamp_seconds Modification time of the oldest file matching the pattern per directory
+# TYPE windows_filetime_directory_oldest_mtime_timestamp_seconds gauge
+windows_filetime_directory_oldest_mtime_timestamp_seconds{directory=%[2]q,pattern=%[1]q} 1.6e+09
+windows_filetime_directory_oldest_mtime_timestamp_seconds{directory=%[3]q,pattern=%[1]q} 1.8e+09
+# HELP windows_filetime_directory_size_bytes Total size of files matching the pattern per directory
+# TYPE windows_filetime_directory_size_bytes gauge
+windows_filetime_directory_size_bytes{directory=%[2]q,pattern=%[1]q} 30
+windows_filetime_directory_size_bytes{directory=%[3]q,pattern=%[1]q} 30
+# HELP windows_filetime_pattern_files Number of files matching the pattern
+# TYPE windows_filetime_pattern_files gauge
+windows_filetime_pattern_files{pattern=%[1]q} 3
+windows_filetime_pattern_files{pattern=%[4]q} 0
+# HELP windows_filetime_pattern_newest_mtime_timestamp_seconds Modification time of the newest file matching the pattern
+# TYPE windows_filetime_pattern_newest_mtime_timestamp_seconds gauge
+windows_filetime_pattern_newest_mtime_timestamp_seconds{pattern=%[1]q} 1.8e+09
+# HELP windows_filetime_pattern_oldest_mtime_timestamp_seconds Modification time of the oldest file matching the pattern
+# TYPE windows_filetime_pattern_oldest_mtime_timestamp_seconds gauge
+windows_filetime_pattern_oldest_mtime_timestamp_seconds{pattern=%[1]q} 1.6e+09
+# HELP windows_filetime_pattern_size_bytes Total size of files matching the pattern
+# TYPE windows_filetime_pattern_size_bytes gauge
+windows_filetime_pattern_size_bytes{pattern=%[1]q} 60
+windows_filetime_pattern_size_bytes{pattern=%[4]q} 0
+`, pattern, filepath.Join(dir, "a"), filepath.Join(dir, "b"), emptyPattern)
+
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		pattern filetime.Pattern
+		err     string
+	}{
+		{"invalid glob", filetime.Pattern{Pattern: "C:/[*.log"}, "invalid glob pattern"},
+		{"unknown metrics", filetime.Pattern{Pattern: "C:/*.log", Metrics: []string{"files"}}, `unknown metrics "files"`},
+	} {
+		c := filetime.New(&filetime.Config{Patterns: []filetime.Pattern{tc.pattern}})
+		err := c.Build(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+		require.ErrorContains(t, err, tc.err, tc.name)
+	}
+}
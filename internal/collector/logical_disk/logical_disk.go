@@ -28,6 +28,7 @@ import (
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perfdata"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sys/windows"
@@ -38,12 +39,14 @@ const Name = "logical_disk"
 type Config struct {
 	VolumeInclude *regexp.Regexp `yaml:"volume_include"`
 	VolumeExclude *regexp.Regexp `yaml:"volume_exclude"`
+	Backend       string         `yaml:"backend"`
 }
 
 //nolint:gochecknoglobals
 var ConfigDefaults = Config{
 	VolumeInclude: types.RegExpAny,
 	VolumeExclude: types.RegExpEmpty,
+	Backend:       perfdata.BackendAuto,
 }
 
 // A Collector is a Prometheus Collector for perflib logicalDisk metrics.
@@ -51,7 +54,7 @@ type Collector struct {
 	config Config
 	logger *slog.Logger
 
-	perfDataCollector *pdh.Collector
+	perfDataCollector perfdata.Collector
 	perfDataObject    []perfDataCounterValues
 
 	avgReadQueue     *prometheus.Desc
@@ -120,6 +123,11 @@ func NewWithFlags(app *kingpin.Application) *Collector {
 		"Regexp of volumes to include. Volume name must both match include and not match exclude to be included.",
 	).Default(".+").StringVar(&volumeInclude)
 
+	app.Flag(
+		"collector.logical_disk.backend",
+		"Backend reading the LogicalDisk performance counters: auto, pdh or registry. auto falls back to the registry, if PDH does not know the object.",
+	).Default(ConfigDefaults.Backend).EnumVar(&c.config.Backend, perfdata.Backends...)
+
 	app.Action(func(*kingpin.ParseContext) error {
 		var err error
 
@@ -152,7 +160,7 @@ func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 
 	var err error
 
-	c.perfDataCollector, err = pdh.NewCollector[perfDataCounterValues]("LogicalDisk", pdh.InstancesAll)
+	c.perfDataCollector, err = perfdata.NewCollector[perfDataCounterValues](c.config.Backend, "LogicalDisk", pdh.InstancesAll)
 	if err != nil {
 		return fmt.Errorf("failed to create LogicalDisk collector: %w", err)
 	}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus-community/windows_exporter/internal/collector/logical_disk"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
 )
@@ -38,3 +39,18 @@ func TestCollector(t *testing.T) {
 		VolumeInclude: types.RegExpAny,
 	})
 }
+
+func TestBackendParity(t *testing.T) {
+	testutils.TestBackendParity[logical_disk.PerfDataCounterValues](t, "LogicalDisk", pdh.InstancesAll, map[string]uint32{
+		"Avg. Disk Read Queue Length": pdh.PERF_COUNTER_100NS_QUEUELEN_TYPE,
+		"Avg. Disk sec/Read":          pdh.PERF_AVERAGE_TIMER,
+		"Avg. Disk sec/Transfer":      pdh.PERF_AVERAGE_TIMER,
+		"Avg. Disk sec/Write":         pdh.PERF_AVERAGE_TIMER,
+		"Disk Read Bytes/sec":         pdh.PERF_COUNTER_BULK_COUNT,
+		"Disk Reads/sec":              pdh.PERF_COUNTER_COUNTER,
+		"% Disk Read Time":            pdh.PERF_PRECISION_100NS_TIMER,
+		"% Free Space":                pdh.PERF_RAW_FRACTION,
+		"% Idle Time":                 pdh.PERF_PRECISION_100NS_TIMER,
+		"Free Megabytes":              pdh.PERF_COUNTER_RAWCOUNT,
+	})
+}
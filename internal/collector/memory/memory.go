@@ -27,22 +27,27 @@ import (
 	"github.com/prometheus-community/windows_exporter/internal/headers/sysinfoapi"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perfdata"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 const Name = "memory"
 
-type Config struct{}
+type Config struct {
+	Backend string `yaml:"backend"`
+}
 
 //nolint:gochecknoglobals
-var ConfigDefaults = Config{}
+var ConfigDefaults = Config{
+	Backend: perfdata.BackendAuto,
+}
 
 // A Collector is a Prometheus Collector for perflib Memory metrics.
 type Collector struct {
 	config Config
 
-	perfDataCollector *pdh.Collector
+	perfDataCollector perfdata.Collector
 	perfDataObject    []perfDataCounterValues
 
 	// Performance metrics
@@ -97,8 +102,17 @@ func New(config *Config) *Collector {
 	return c
 }
 
-func NewWithFlags(_ *kingpin.Application) *Collector {
-	return &Collector{}
+func NewWithFlags(app *kingpin.Application) *Collector {
+	c := &Collector{
+		config: ConfigDefaults,
+	}
+
+	app.Flag(
+		"collector.memory.backend",
+		"Backend reading the Memory performance counters: auto, pdh or registry. auto falls back to the registry, if PDH does not know the object.",
+	).Default(ConfigDefaults.Backend).EnumVar(&c.config.Backend, perfdata.Backends...)
+
+	return c
 }
 
 func (c *Collector) GetName() string {
@@ -112,7 +126,7 @@ func (c *Collector) Close() error {
 func (c *Collector) Build(_ *slog.Logger, _ *mi.Session) error {
 	var err error
 
-	c.perfDataCollector, err = pdh.NewCollector[perfDataCounterValues]("Memory", pdh.InstancesAll)
+	c.perfDataCollector, err = perfdata.NewCollector[perfDataCounterValues](c.config.Backend, "Memory", pdh.InstancesAll)
 	if err != nil {
 		return fmt.Errorf("failed to create Memory collector: %w", err)
 	}
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/prometheus-community/windows_exporter/internal/collector/memory"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
 	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
 )
 
@@ -29,3 +30,13 @@ func BenchmarkCollector(b *testing.B) {
 func TestCollector(t *testing.T) {
 	testutils.TestCollector(t, memory.New, nil)
 }
+
+func TestBackendParity(t *testing.T) {
+	testutils.TestBackendParity[memory.PerfDataCounterValues](t, "Memory", nil, map[string]uint32{
+		"Cache Faults/sec":       pdh.PERF_COUNTER_COUNTER,
+		"Demand Zero Faults/sec": pdh.PERF_COUNTER_COUNTER,
+		"Page Faults/sec":        pdh.PERF_COUNTER_COUNTER,
+		"Pages/sec":              pdh.PERF_COUNTER_COUNTER,
+		"Available KBytes":       pdh.PERF_COUNTER_RAWCOUNT,
+	})
+}
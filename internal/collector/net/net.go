@@ -28,6 +28,7 @@ import (
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perfdata"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/sys/windows"
@@ -39,6 +40,7 @@ type Config struct {
 	NicExclude        *regexp.Regexp `yaml:"nic_exclude"`
 	NicInclude        *regexp.Regexp `yaml:"nic_include"`
 	CollectorsEnabled []string       `yaml:"collectors_enabled"`
+	Backend           string         `yaml:"backend"`
 }
 
 //nolint:gochecknoglobals
@@ -49,13 +51,14 @@ var ConfigDefaults = Config{
 		"metrics",
 		"nic_addresses",
 	},
+	Backend: perfdata.BackendAuto,
 }
 
 // A Collector is a Prometheus Collector for Perflib Network Interface metrics.
 type Collector struct {
 	config Config
 
-	perfDataCollector *pdh.Collector
+	perfDataCollector perfdata.Collector
 	perfDataObject    []perfDataCounterValues
 
 	bytesReceivedTotal       *prometheus.Desc
@@ -125,6 +128,11 @@ func NewWithFlags(app *kingpin.Application) *Collector {
 		"Comma-separated list of collectors to use. Defaults to all, if not specified.",
 	).Default(strings.Join(ConfigDefaults.CollectorsEnabled, ",")).StringVar(&collectorsEnabled)
 
+	app.Flag(
+		"collector.net.backend",
+		"Backend reading the Network Interface performance counters: auto, pdh or registry. auto falls back to the registry, if PDH does not know the object.",
+	).Default(ConfigDefaults.Backend).EnumVar(&c.config.Backend, perfdata.Backends...)
+
 	app.Action(func(*kingpin.ParseContext) error {
 		c.config.CollectorsEnabled = strings.Split(collectorsEnabled, ",")
 
@@ -159,7 +167,7 @@ func (c *Collector) Close() error {
 func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 	var err error
 
-	c.perfDataCollector, err = pdh.NewCollector[perfDataCounterValues]("Network Interface", pdh.InstancesAll)
+	c.perfDataCollector, err = perfdata.NewCollector[perfDataCounterValues](c.config.Backend, "Network Interface", pdh.InstancesAll)
 	if err != nil {
 		return fmt.Errorf("failed to create Network Interface collector: %w", err)
 	}
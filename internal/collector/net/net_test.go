@@ -19,9 +19,20 @@ import (
 	"testing"
 
 	"github.com/prometheus-community/windows_exporter/internal/collector/net"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
 	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
 )
 
 func TestCollector(t *testing.T) {
 	testutils.TestCollector(t, net.New, nil)
 }
+
+func TestBackendParity(t *testing.T) {
+	testutils.TestBackendParity[net.PerfDataCounterValues](t, "Network Interface", pdh.InstancesAll, map[string]uint32{
+		"Bytes Received/sec":   pdh.PERF_COUNTER_BULK_COUNT,
+		"Bytes Total/sec":      pdh.PERF_COUNTER_BULK_COUNT,
+		"Packets/sec":          pdh.PERF_COUNTER_BULK_COUNT,
+		"Packets Received/sec": pdh.PERF_COUNTER_BULK_COUNT,
+		"Output Queue Length":  pdh.PERF_COUNTER_RAWCOUNT,
+	})
+}
@@ -51,8 +51,6 @@ type Collector struct {
 
 	objects []Object
 
-	metricNameReplacer *strings.Replacer
-
 	// meta
 	subCollectorScrapeDurationDesc *prometheus.Desc
 	subCollectorScrapeSuccessDesc  *prometheus.Desc
@@ -116,14 +114,6 @@ func (c *Collector) Close() error {
 func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 	c.logger = logger.With(slog.String("collector", Name))
 
-	c.metricNameReplacer = strings.NewReplacer(
-		".", "",
-		"%", "",
-		"/", "_",
-		" ", "_",
-		"-", "_",
-	)
-
 	c.objects = make([]Object, 0, len(c.config.Objects))
 	names := make([]string, 0, len(c.config.Objects))
 
@@ -148,13 +138,14 @@ func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 
 		names = append(names, object.Name)
 		counters := make([]string, 0, len(object.Counters))
-		fields := make([]reflect.StructField, 0, len(object.Counters)+2)
+		fields := make([]reflect.StructField, 0, 2*len(object.Counters)+1)
 
 		for j, counter := range object.Counters {
 			if counter.Metric == "" {
-				c.config.Objects[i].Counters[j].Metric = c.sanitizeMetricName(
+				c.config.Objects[i].Counters[j].Metric = pdh.SanitizeMetricName(
 					fmt.Sprintf("%s_%s_%s_%s", types.Namespace, Name, object.Object, counter.Name),
 				)
+				c.config.Objects[i].Counters[j].totalSuffix = true
 			}
 
 			if counter.Name == "" {
@@ -172,9 +163,13 @@ func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 
 			counters = append(counters, counter.Name)
 			fields = append(fields, reflect.StructField{
-				Name: strings.ToUpper(c.sanitizeMetricName(counter.Name)),
+				Name: strings.ToUpper(pdh.SanitizeMetricName(counter.Name)),
 				Type: reflect.TypeOf(float64(0)),
 				Tag:  reflect.StructTag(fmt.Sprintf(`perfdata:"%s"`, counter.Name)),
+			}, reflect.StructField{
+				Name: strings.ToUpper(pdh.SanitizeMetricName(counter.Name)) + "_METRICTYPE",
+				Type: reflect.TypeOf(prometheus.ValueType(0)),
+				Tag:  reflect.StructTag(fmt.Sprintf(`perfdata:"%s,metrictype"`, counter.Name)),
 			})
 		}
 
@@ -185,10 +180,11 @@ func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 			})
 		}
 
-		fields = append(fields, reflect.StructField{
-			Name: "MetricType",
-			Type: reflect.TypeOf(prometheus.ValueType(0)),
-		})
+		if err := object.compileInstanceRegexps(); err != nil {
+			errs = append(errs, fmt.Errorf("object %s: %w", object.Name, err))
+
+			continue
+		}
 
 		valueType := reflect.StructOf(fields)
 
@@ -273,10 +269,36 @@ func (c *Collector) collectObject(ch chan<- prometheus.Metric, perfDataObject Ob
 
 	sliceValue := reflect.ValueOf(perfDataObject.perfDataObject).Elem().Interface()
 	for i := range reflect.ValueOf(sliceValue).Len() {
-		for _, counter := range perfDataObject.Counters {
-			val := reflect.ValueOf(sliceValue).Index(i)
+		val := reflect.ValueOf(sliceValue).Index(i)
+
+		var instanceLabels prometheus.Labels
+
+		if perfDataObject.Instances != nil {
+			field := val.FieldByName("Name")
+			if !field.IsValid() {
+				errs = append(errs, errors.New("field Name not found in collected data"))
+
+				continue
+			}
+
+			if field.Kind() != reflect.String {
+				errs = append(errs, errors.New("failed to cast Name to string"))
+
+				continue
+			}
 
-			field := val.FieldByName(strings.ToUpper(c.sanitizeMetricName(counter.Name)))
+			collectedInstance := field.String()
+			if !perfDataObject.isInstanceIncluded(collectedInstance) {
+				continue
+			}
+
+			if collectedInstance != pdh.InstanceEmpty {
+				instanceLabels = perfDataObject.instanceLabels(collectedInstance)
+			}
+		}
+
+		for _, counter := range perfDataObject.Counters {
+			field := val.FieldByName(strings.ToUpper(pdh.SanitizeMetricName(counter.Name)))
 			if !field.IsValid() {
 				errs = append(errs, fmt.Errorf("%s not found in collected data", counter.Name))
 
@@ -291,9 +313,9 @@ func (c *Collector) collectObject(ch chan<- prometheus.Metric, perfDataObject Ob
 
 			collectedCounterValue := field.Float()
 
-			field = val.FieldByName("MetricType")
+			field = val.FieldByName(strings.ToUpper(pdh.SanitizeMetricName(counter.Name)) + "_METRICTYPE")
 			if !field.IsValid() {
-				errs = append(errs, errors.New("field MetricType not found in collected data"))
+				errs = append(errs, fmt.Errorf("metric type of %s not found in collected data", counter.Name))
 
 				continue
 			}
@@ -306,26 +328,15 @@ func (c *Collector) collectObject(ch chan<- prometheus.Metric, perfDataObject Ob
 
 			metricType, _ := field.Interface().(prometheus.ValueType)
 
-			labels := make(prometheus.Labels, len(counter.Labels)+1)
-
-			if perfDataObject.Instances != nil {
-				field := val.FieldByName("Name")
-				if !field.IsValid() {
-					errs = append(errs, errors.New("field Name not found in collected data"))
-
-					continue
-				}
-
-				if field.Kind() != reflect.String {
-					errs = append(errs, fmt.Errorf("failed to cast Name for %s to string", counter.Name))
+			// The metric type is only set, if the counter has a value for the instance.
+			if metricType == 0 {
+				continue
+			}
 
-					continue
-				}
+			labels := make(prometheus.Labels, len(counter.Labels)+len(instanceLabels))
 
-				collectedInstance := field.String()
-				if collectedInstance != pdh.InstanceEmpty {
-					labels[perfDataObject.InstanceLabel] = collectedInstance
-				}
+			for key, value := range instanceLabels {
+				labels[key] = value
 			}
 
 			for key, value := range counter.Labels {
@@ -339,10 +350,14 @@ func (c *Collector) collectObject(ch chan<- prometheus.Metric, perfDataObject Ob
 				metricType = prometheus.GaugeValue
 			}
 
+			if counter.Scale != 0 {
+				collectedCounterValue *= counter.Scale
+			}
+
 			ch <- prometheus.MustNewConstMetric(
 				prometheus.NewDesc(
-					counter.Metric,
-					"windows_exporter: custom Performance Counter metric",
+					counter.metricName(metricType),
+					counter.help(),
 					nil,
 					labels,
 				),
@@ -354,7 +369,3 @@ func (c *Collector) collectObject(ch chan<- prometheus.Metric, perfDataObject Ob
 
 	return errors.Join(errs...)
 }
-
-func (c *Collector) sanitizeMetricName(name string) string {
-	return strings.Trim(c.metricNameReplacer.Replace(strings.ToLower(name)), "_")
-}
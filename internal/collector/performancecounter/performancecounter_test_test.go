@@ -22,13 +22,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/prometheus-community/windows_exporter/internal/collector/performancecounter"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 type collectorAdapter struct {
@@ -49,11 +53,15 @@ func TestCollector(t *testing.T) {
 	t.Parallel()
 
 	for _, tc := range []struct {
-		name            string
-		object          string
-		instances       []string
-		instanceLabel   string
-		buildErr        string
+		name          string
+		object        string
+		instances     []string
+		instanceLabel string
+		buildErr      string
+
+		instanceInclude    string
+		instanceLabelRegex string
+
 		counters        []performancecounter.Counter
 		expectedMetrics *regexp.Regexp
 	}{
@@ -85,9 +93,9 @@ windows_performancecounter_collector_duration_seconds\{collector="process"} [0-9
 # HELP windows_performancecounter_collector_success windows_exporter: Whether a performancecounter child collector was successful.
 # TYPE windows_performancecounter_collector_success gauge
 windows_performancecounter_collector_success\{collector="process"} 1
-# HELP windows_performancecounter_process_thread_count windows_exporter: custom Performance Counter metric
-# TYPE windows_performancecounter_process_thread_count counter
-windows_performancecounter_process_thread_count\{instance=".+"} [0-9.e+-]+
+# HELP windows_performancecounter_process_thread_count_total windows_exporter: custom Performance Counter metric
+# TYPE windows_performancecounter_process_thread_count_total counter
+windows_performancecounter_process_thread_count_total\{instance=".+"} [0-9.e+-]+
 .*`),
 		},
 		{
@@ -126,6 +134,24 @@ windows_performancecounter_processor_information_processor_time\{core="0,0",stat
 			counters:        []performancecounter.Counter{{Name: "Available Bytes", Type: "gauge"}, {Name: "Available Bytes", Type: "gauge"}},
 			expectedMetrics: nil,
 		},
+		{
+			name:               "instance_label_regex_without_group",
+			object:             "Process",
+			instances:          []string{"*"},
+			instanceLabelRegex: `^([^#]+)`,
+			buildErr:           "at least one named capture group is required",
+			counters:           []performancecounter.Counter{{Name: "Thread Count"}},
+			expectedMetrics:    nil,
+		},
+		{
+			name:            "instance_include_without_instances",
+			object:          "Memory",
+			instances:       nil,
+			instanceInclude: "C:",
+			buildErr:        "require instances",
+			counters:        []performancecounter.Counter{{Name: "Available Bytes"}},
+			expectedMetrics: nil,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
@@ -138,6 +164,9 @@ windows_performancecounter_processor_information_processor_time\{core="0,0",stat
 						Instances:     tc.instances,
 						InstanceLabel: tc.instanceLabel,
 						Counters:      tc.counters,
+
+						InstanceInclude:    tc.instanceInclude,
+						InstanceLabelRegex: tc.instanceLabelRegex,
 					},
 				},
 			})
@@ -166,3 +195,70 @@ windows_performancecounter_processor_information_processor_time\{core="0,0",stat
 		})
 	}
 }
+
+func TestCollectorReplay(t *testing.T) {
+	recording := &pdh.Recording{
+		Object: "Process",
+		Snapshots: [][]pdh.Sample{{
+			{Counter: "IO Read Operations/sec", Instance: "w3wp", Type: pdh.PERF_COUNTER_BULK_COUNT, FirstValue: 1200},
+			{Counter: "IO Read Operations/sec", Instance: "w3wp#1", Type: pdh.PERF_COUNTER_BULK_COUNT, FirstValue: 300},
+			{Counter: "IO Read Operations/sec", Instance: "Idle", Type: pdh.PERF_COUNTER_BULK_COUNT, FirstValue: 0},
+			{Counter: "Thread Count", Instance: "w3wp", Type: pdh.PERF_COUNTER_RAWCOUNT, FirstValue: 42},
+			{Counter: "Thread Count", Instance: "w3wp#1", Type: pdh.PERF_COUNTER_RAWCOUNT, FirstValue: 17},
+			{Counter: "Thread Count", Instance: "Idle", Type: pdh.PERF_COUNTER_RAWCOUNT, FirstValue: 8},
+			{Counter: "Working Set - Private", Instance: "w3wp", Type: pdh.PERF_COUNTER_LARGE_RAWCOUNT, FirstValue: 4096},
+		}},
+	}
+
+	previous := pdh.SetSourceFactory(func(object string, counters []string, _ []string) (pdh.Source, error) {
+		assert.Equal(t, "Process", object)
+
+		return pdh.NewReplaySource(recording, counters), nil
+	})
+
+	defer pdh.SetSourceFactory(previous)
+
+	var config performancecounter.Config
+
+	require.NoError(t, yaml.Unmarshal([]byte(`
+objects:
+  - name: w3wp
+    object: Process
+    instances: ["*"]
+    instance_exclude: Idle
+    instance_label_regex: '^(?P<process>[^#]+)(?:#(?P<process_index>\d+))?$'
+    counters:
+      - name: IO Read Operations/sec
+      - name: Thread Count
+        help: Number of active threads of the process.
+      - name: Working Set - Private
+        metric: windows_performancecounter_process_working_set_private_kilobytes
+        scale: 0.0009765625
+`), &config))
+
+	collector := performancecounter.New(&config)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	require.NoError(t, collector.Build(logger, nil))
+
+	defer collector.Close()
+
+	expected := `# HELP windows_performancecounter_process_io_read_operations_sec_total windows_exporter: custom Performance Counter metric
+# TYPE windows_performancecounter_process_io_read_operations_sec_total counter
+windows_performancecounter_process_io_read_operations_sec_total{process="w3wp",process_index=""} 1200
+windows_performancecounter_process_io_read_operations_sec_total{process="w3wp",process_index="1"} 300
+# HELP windows_performancecounter_process_thread_count Number of active threads of the process.
+# TYPE windows_performancecounter_process_thread_count gauge
+windows_performancecounter_process_thread_count{process="w3wp",process_index=""} 42
+windows_performancecounter_process_thread_count{process="w3wp",process_index="1"} 17
+# HELP windows_performancecounter_process_working_set_private_kilobytes windows_exporter: custom Performance Counter metric
+# TYPE windows_performancecounter_process_working_set_private_kilobytes gauge
+windows_performancecounter_process_working_set_private_kilobytes{process="w3wp",process_index=""} 4
+`
+
+	require.NoError(t, testutil.CollectAndCompare(collectorAdapter{*collector}, strings.NewReader(expected),
+		"windows_performancecounter_process_io_read_operations_sec_total",
+		"windows_performancecounter_process_thread_count",
+		"windows_performancecounter_process_working_set_private_kilobytes",
+	))
+}
@@ -16,7 +16,14 @@
 package performancecounter
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 )
 
 type Object struct {
@@ -25,16 +32,125 @@ type Object struct {
 	Instances     []string  `json:"instances"      yaml:"instances"`
 	Counters      []Counter `json:"counters"       yaml:"counters"`
 	InstanceLabel string    `json:"instance_label" yaml:"instance_label"` //nolint:tagliatelle
+	// InstanceInclude and InstanceExclude are regular expressions matched against the full instance name.
+	InstanceInclude string `json:"instance_include" yaml:"instance_include"` //nolint:tagliatelle
+	InstanceExclude string `json:"instance_exclude" yaml:"instance_exclude"` //nolint:tagliatelle
+	// InstanceLabelRegex splits the instance name into one label per named capture group.
+	InstanceLabelRegex string `json:"instance_label_regex" yaml:"instance_label_regex"` //nolint:tagliatelle
 
-	collector      *pdh.Collector
-	perfDataObject any
+	collector          *pdh.Collector
+	perfDataObject     any
+	instanceInclude    *regexp.Regexp
+	instanceExclude    *regexp.Regexp
+	instanceLabelRegex *regexp.Regexp
 }
 
 type Counter struct {
 	Name   string            `json:"name"   yaml:"name"`
 	Type   string            `json:"type"   yaml:"type"`
 	Metric string            `json:"metric" yaml:"metric"`
+	Help   string            `json:"help"   yaml:"help"`
+	Scale  float64           `json:"scale"  yaml:"scale"`
 	Labels map[string]string `json:"labels" yaml:"labels"`
+
+	// totalSuffix is set for generated metric names, which get a _total suffix for counter types.
+	totalSuffix bool
+}
+
+// compileInstanceRegexps compiles the instance filters and the instance label regex of the object.
+func (o *Object) compileInstanceRegexps() error {
+	if o.Instances == nil && (o.InstanceInclude != "" || o.InstanceExclude != "" || o.InstanceLabelRegex != "") {
+		return errors.New("instance_include, instance_exclude and instance_label_regex require instances")
+	}
+
+	var err error
+
+	if o.InstanceInclude != "" {
+		if o.instanceInclude, err = regexp.Compile(fmt.Sprintf("^(?:%s)$", o.InstanceInclude)); err != nil {
+			return fmt.Errorf("instance_include: %w", err)
+		}
+	}
+
+	if o.InstanceExclude != "" {
+		if o.instanceExclude, err = regexp.Compile(fmt.Sprintf("^(?:%s)$", o.InstanceExclude)); err != nil {
+			return fmt.Errorf("instance_exclude: %w", err)
+		}
+	}
+
+	if o.InstanceLabelRegex == "" {
+		return nil
+	}
+
+	if o.instanceLabelRegex, err = regexp.Compile(o.InstanceLabelRegex); err != nil {
+		return fmt.Errorf("instance_label_regex: %w", err)
+	}
+
+	var groups int
+
+	for _, name := range o.instanceLabelRegex.SubexpNames() {
+		if name == "" {
+			continue
+		}
+
+		if !model.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("instance_label_regex: invalid label name %q", name)
+		}
+
+		groups++
+	}
+
+	if groups == 0 {
+		return errors.New("instance_label_regex: at least one named capture group is required")
+	}
+
+	return nil
+}
+
+// isInstanceIncluded applies instance_include and instance_exclude. Exclusion takes precedence.
+func (o *Object) isInstanceIncluded(instance string) bool {
+	if o.instanceExclude != nil && o.instanceExclude.MatchString(instance) {
+		return false
+	}
+
+	return o.instanceInclude == nil || o.instanceInclude.MatchString(instance)
+}
+
+// instanceLabels returns the labels of an instance. The named capture groups of instance_label_regex
+// become labels, e.g. w3wp#2 is split into process="w3wp" and process_index="2".
+// Instances not matching the regex are exposed by the instance label.
+func (o *Object) instanceLabels(instance string) prometheus.Labels {
+	if o.instanceLabelRegex != nil {
+		if match := o.instanceLabelRegex.FindStringSubmatch(instance); match != nil {
+			labels := make(prometheus.Labels, len(match))
+
+			for i, name := range o.instanceLabelRegex.SubexpNames() {
+				if name != "" {
+					labels[name] = match[i]
+				}
+			}
+
+			return labels
+		}
+	}
+
+	return prometheus.Labels{o.InstanceLabel: instance}
+}
+
+// metricName returns the metric name of the counter. Generated names of counters get a _total suffix.
+func (c Counter) metricName(metricType prometheus.ValueType) string {
+	if c.totalSuffix && metricType == prometheus.CounterValue && !strings.HasSuffix(c.Metric, "_total") {
+		return c.Metric + "_total"
+	}
+
+	return c.Metric
+}
+
+func (c Counter) help() string {
+	if c.Help != "" {
+		return c.Help
+	}
+
+	return "windows_exporter: custom Performance Counter metric"
 }
 
 // https://github.com/open-telemetry/opentelemetry-collector-contrib/blob/54691ebe11bb9ec32b4e35cd31fcb94a352de134/receiver/windowsperfcountersreceiver/README.md?plain=1#L150
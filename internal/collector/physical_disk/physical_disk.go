@@ -24,6 +24,7 @@ import (
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perfdata"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -33,19 +34,21 @@ const Name = "physical_disk"
 type Config struct {
 	DiskInclude *regexp.Regexp `yaml:"disk_include"`
 	DiskExclude *regexp.Regexp `yaml:"disk_exclude"`
+	Backend     string         `yaml:"backend"`
 }
 
 //nolint:gochecknoglobals
 var ConfigDefaults = Config{
 	DiskInclude: types.RegExpAny,
 	DiskExclude: types.RegExpEmpty,
+	Backend:     perfdata.BackendAuto,
 }
 
 // A Collector is a Prometheus Collector for perflib PhysicalDisk metrics.
 type Collector struct {
 	config Config
 
-	perfDataCollector *pdh.Collector
+	perfDataCollector perfdata.Collector
 	perfDataObject    []perfDataCounterValues
 
 	idleTime         *prometheus.Desc
@@ -99,6 +102,11 @@ func NewWithFlags(app *kingpin.Application) *Collector {
 		"Regexp of disks to include. Disk number must both match include and not match exclude to be included.",
 	).Default(".+").StringVar(&diskInclude)
 
+	app.Flag(
+		"collector.physical_disk.backend",
+		"Backend reading the PhysicalDisk performance counters: auto, pdh or registry. auto falls back to the registry, if PDH does not know the object.",
+	).Default(ConfigDefaults.Backend).EnumVar(&c.config.Backend, perfdata.Backends...)
+
 	app.Action(func(*kingpin.ParseContext) error {
 		var err error
 
@@ -129,7 +137,7 @@ func (c *Collector) Close() error {
 func (c *Collector) Build(_ *slog.Logger, _ *mi.Session) error {
 	var err error
 
-	c.perfDataCollector, err = pdh.NewCollector[perfDataCounterValues]("PhysicalDisk", pdh.InstancesAll)
+	c.perfDataCollector, err = perfdata.NewCollector[perfDataCounterValues](c.config.Backend, "PhysicalDisk", pdh.InstancesAll)
 	if err != nil {
 		return fmt.Errorf("failed to create PhysicalDisk collector: %w", err)
 	}
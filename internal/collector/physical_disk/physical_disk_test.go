@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/prometheus-community/windows_exporter/internal/collector/physical_disk"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
 )
@@ -32,3 +33,15 @@ func TestCollector(t *testing.T) {
 		DiskInclude: types.RegExpAny,
 	})
 }
+
+func TestBackendParity(t *testing.T) {
+	testutils.TestBackendParity[physical_disk.PerfDataCounterValues](t, "PhysicalDisk", pdh.InstancesAll, map[string]uint32{
+		"Current Disk Queue Length": pdh.PERF_COUNTER_RAWCOUNT,
+		"Disk Read Bytes/sec":       pdh.PERF_COUNTER_BULK_COUNT,
+		"Disk Writes/sec":           pdh.PERF_COUNTER_COUNTER,
+		"% Disk Read Time":          pdh.PERF_PRECISION_100NS_TIMER,
+		"% Idle Time":               pdh.PERF_PRECISION_100NS_TIMER,
+		"Avg. Disk sec/Read":        pdh.PERF_AVERAGE_TIMER,
+		"Split IO/Sec":              pdh.PERF_COUNTER_COUNTER,
+	})
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package system_test
+
+import (
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/collector/system"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
+)
+
+func BenchmarkCollector(b *testing.B) {
+	testutils.FuncBenchmarkCollector(b, system.Name, system.NewWithFlags)
+}
+
+func TestBackendParity(t *testing.T) {
+	testutils.TestBackendParity[system.PerfDataCounterValues](t, "System", nil, map[string]uint32{
+		"Context Switches/sec":   pdh.PERF_COUNTER_COUNTER,
+		"Processor Queue Length": pdh.PERF_COUNTER_RAWCOUNT,
+		"System Calls/sec":       pdh.PERF_COUNTER_COUNTER,
+		"System Up Time":         pdh.PERF_ELAPSED_TIME,
+		"Processes":              pdh.PERF_COUNTER_RAWCOUNT,
+	})
+}
@@ -0,0 +1,339 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package textfile
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Interface guards.
+var (
+	_ http.Handler         = (*PushStore)(nil)
+	_ prometheus.Collector = (*PushStore)(nil)
+)
+
+// jobNameRegex restricts job names to names which are safe to use as file names.
+var jobNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,128}$`)
+
+type PushOptions struct {
+	// Token is the bearer token required to push metrics.
+	Token string
+	// TTL is the duration pushed metrics are exposed for. 0 means forever.
+	TTL time.Duration
+	// MaxSize is the maximum size of a request body in bytes.
+	MaxSize int64
+	// PersistenceDirectory is the directory pushed metrics are persisted to. Empty disables persistence.
+	PersistenceDirectory string
+}
+
+// PushStore accepts metrics in the Prometheus text format via HTTP and exposes them until they expire.
+// It is an alternative to writing text files for scripts.
+type PushStore struct {
+	logger  *slog.Logger
+	options PushOptions
+
+	mu   sync.Mutex
+	jobs map[string]pushedJob
+
+	// Only set for testing to get predictable output.
+	now func() time.Time
+
+	pushTimeDesc *prometheus.Desc
+}
+
+type pushedJob struct {
+	families []*dto.MetricFamily
+	pushTime time.Time
+}
+
+// NewPushStore creates a new PushStore. Persisted metrics are loaded from the persistence directory.
+func NewPushStore(logger *slog.Logger, options PushOptions) (*PushStore, error) {
+	if options.Token == "" {
+		return nil, errors.New("token is required")
+	}
+
+	if options.MaxSize <= 0 {
+		options.MaxSize = ConfigDefaults.MaxFileSize
+	}
+
+	s := &PushStore{
+		logger:  logger.With(slog.String("component", "textfile_push")),
+		options: options,
+		jobs:    make(map[string]pushedJob),
+		now:     time.Now,
+		pushTimeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, Name, "push_timestamp_seconds"),
+			"Unixtime of the last push of a job.",
+			[]string{"job"},
+			nil,
+		),
+	}
+
+	if options.PersistenceDirectory != "" {
+		if err := os.MkdirAll(options.PersistenceDirectory, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create persistence directory: %w", err)
+		}
+
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// load reads persisted jobs from the persistence directory.
+// Invalid files are logged and skipped.
+func (s *PushStore) load() error {
+	entries, err := os.ReadDir(s.options.PersistenceDirectory)
+	if err != nil {
+		return fmt.Errorf("failed to read persistence directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		job, ok := strings.CutSuffix(entry.Name(), ".prom")
+		if entry.IsDir() || !ok || !jobNameRegex.MatchString(job) {
+			continue
+		}
+
+		path := filepath.Join(s.options.PersistenceDirectory, entry.Name())
+
+		families, err := scrapeFile(path, s.logger, s.options.MaxSize)
+		if err != nil {
+			s.logger.Warn("failed to load persisted job "+job,
+				slog.Any("err", err),
+			)
+
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			s.logger.Warn("failed to load persisted job "+job,
+				slog.Any("err", err),
+			)
+
+			continue
+		}
+
+		s.jobs[job] = pushedJob{
+			families: families,
+			pushTime: info.ModTime(),
+		}
+	}
+
+	return nil
+}
+
+func (s *PushStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="windows_exporter"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+		return
+	}
+
+	job := r.PathValue("job")
+	if !jobNameRegex.MatchString(job) {
+		http.Error(w, fmt.Sprintf("invalid job name %q", job), http.StatusBadRequest)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		s.push(w, r, job)
+	case http.MethodDelete:
+		s.delete(w, job)
+	default:
+		w.Header().Set("Allow", "PUT, POST, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *PushStore) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) == 1
+}
+
+func (s *PushStore) push(w http.ResponseWriter, r *http.Request, job string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.options.MaxSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("request body exceeds limit of %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+
+			return
+		}
+
+		http.Error(w, fmt.Sprintf("failed to read request body: %s", err), http.StatusBadRequest)
+
+		return
+	}
+
+	// Apply the same rules as for text files.
+	families, err := ParseMetricFamilies(bytes.NewReader(body), "push job "+job)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid metrics: %s", err), http.StatusBadRequest)
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.options.PersistenceDirectory != "" {
+		if err = s.persist(job, body); err != nil {
+			s.logger.Error("failed to persist job "+job,
+				slog.Any("err", err),
+			)
+
+			http.Error(w, "failed to persist metrics", http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	s.jobs[job] = pushedJob{
+		families: families,
+		pushTime: s.now(),
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *PushStore) delete(w http.ResponseWriter, job string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, job)
+
+	if s.options.PersistenceDirectory != "" {
+		err := os.Remove(s.path(job))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Error("failed to delete persisted job "+job,
+				slog.Any("err", err),
+			)
+
+			http.Error(w, "failed to delete persisted metrics", http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// persist writes the body atomically to the persistence directory.
+func (s *PushStore) persist(job string, body []byte) error {
+	file, err := os.CreateTemp(s.options.PersistenceDirectory, job+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), s.path(job))
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *PushStore) path(job string) string {
+	return filepath.Join(s.options.PersistenceDirectory, job+".prom")
+}
+
+func (s *PushStore) Describe(_ chan<- *prometheus.Desc) {}
+
+// Collect exposes the metrics of all jobs which are not expired.
+// Expired jobs are removed.
+func (s *PushStore) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	var metricFamilies []*dto.MetricFamily
+
+	// Sorting is needed for predictable output comparison in tests.
+	for _, job := range slices.Sorted(maps.Keys(s.jobs)) {
+		pushed := s.jobs[job]
+
+		if s.options.TTL > 0 && now.Sub(pushed.pushTime) > s.options.TTL {
+			s.logger.Debug("metrics of job " + job + " expired")
+
+			delete(s.jobs, job)
+
+			if s.options.PersistenceDirectory != "" {
+				_ = os.Remove(s.path(job))
+			}
+
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			s.pushTimeDesc,
+			prometheus.GaugeValue,
+			float64(pushed.pushTime.Unix()),
+			job,
+		)
+
+		metricFamilies = append(metricFamilies, pushed.families...)
+	}
+
+	// If duplicates are detected across *multiple* jobs, skip all pushed metrics.
+	if DuplicateMetricEntry(metricFamilies) {
+		s.logger.Warn("duplicate metrics detected across multiple pushed jobs")
+
+		return
+	}
+
+	for _, mf := range metricFamilies {
+		ConvertMetricFamily(s.logger, mf, ch)
+	}
+}
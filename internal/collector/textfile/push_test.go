@@ -0,0 +1,198 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package textfile
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+func newTestPushStore(t *testing.T, options PushOptions) (*PushStore, *http.ServeMux) {
+	t.Helper()
+
+	options.Token = testToken
+
+	store, err := NewPushStore(slog.New(slog.NewTextHandler(io.Discard, nil)), options)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("PUT /textfile/{job}", store)
+	mux.Handle("POST /textfile/{job}", store)
+	mux.Handle("DELETE /textfile/{job}", store)
+
+	return store, mux
+}
+
+func pushRequest(t *testing.T, mux http.Handler, method, job, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/textfile/"+job, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func gatherPushStore(t *testing.T, store *PushStore) string {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(store))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	var names []string
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestPushStoreAuthorization(t *testing.T) {
+	t.Parallel()
+
+	_, mux := newTestPushStore(t, PushOptions{})
+
+	rec := pushRequest(t, mux, http.MethodPut, "job", "", "test_metric 1\n")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = pushRequest(t, mux, http.MethodPut, "job", "wrong", "test_metric 1\n")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = pushRequest(t, mux, http.MethodPut, "job", testToken, "test_metric 1\n")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	_, err := NewPushStore(slog.New(slog.NewTextHandler(io.Discard, nil)), PushOptions{})
+	require.ErrorContains(t, err, "token is required")
+}
+
+func TestPushStoreValidation(t *testing.T) {
+	t.Parallel()
+
+	store, mux := newTestPushStore(t, PushOptions{MaxSize: 64})
+
+	for _, tc := range []struct {
+		name string
+		job  string
+		body string
+		code int
+	}{
+		{"invalid job name", "job%20name", "test_metric 1\n", http.StatusBadRequest},
+		{"invalid metrics", "job", "test_metric{ 1\n", http.StatusBadRequest},
+		{"timestamps", "job", "test_metric 1 1700000000000\n", http.StatusBadRequest},
+		{"duplicates", "job", "test_metric 1\ntest_metric 2\n", http.StatusBadRequest},
+		{"utf16 bom", "job", "\xff\xfetest_metric 1\n", http.StatusBadRequest},
+		{"too large", "job", strings.Repeat("# comment\n", 10), http.StatusRequestEntityTooLarge},
+	} {
+		rec := pushRequest(t, mux, http.MethodPost, tc.job, testToken, tc.body)
+		assert.Equal(t, tc.code, rec.Code, tc.name)
+	}
+
+	assert.Empty(t, gatherPushStore(t, store))
+}
+
+func TestPushStoreCollect(t *testing.T) {
+	t.Parallel()
+
+	store, mux := newTestPushStore(t, PushOptions{TTL: time.Hour})
+
+	now := time.Unix(1700000000, 0)
+	store.now = func() time.Time { return now }
+
+	rec := pushRequest(t, mux, http.MethodPut, "backup", testToken, "# TYPE backup_size_bytes gauge\nbackup_size_bytes{disk=\"C\"} 1024\n")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = pushRequest(t, mux, http.MethodPost, "jobs", testToken, "jobs_total 3\n")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	expected := `
+# HELP backup_size_bytes Metric read from push job backup
+# TYPE backup_size_bytes gauge
+backup_size_bytes{disk="C"} 1024
+# HELP windows_textfile_push_timestamp_seconds Unixtime of the last push of a job.
+# TYPE windows_textfile_push_timestamp_seconds gauge
+windows_textfile_push_timestamp_seconds{job="backup"} 1.7e+09
+windows_textfile_push_timestamp_seconds{job="jobs"} 1.7e+09
+`
+	require.NoError(t, testutil.CollectAndCompare(store, strings.NewReader(expected), "backup_size_bytes", "windows_textfile_push_timestamp_seconds"))
+	assert.Equal(t, "backup_size_bytes,jobs_total,windows_textfile_push_timestamp_seconds", gatherPushStore(t, store))
+
+	// Metrics are replaced by subsequent pushes and can be deleted.
+	rec = pushRequest(t, mux, http.MethodPut, "backup", testToken, "backup_success 1\n")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = pushRequest(t, mux, http.MethodDelete, "jobs", testToken, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	assert.Equal(t, "backup_success,windows_textfile_push_timestamp_seconds", gatherPushStore(t, store))
+
+	// Metrics expire after the TTL.
+	now = now.Add(2 * time.Hour)
+
+	assert.Empty(t, gatherPushStore(t, store))
+}
+
+func TestPushStoreDuplicatesAcrossJobs(t *testing.T) {
+	t.Parallel()
+
+	store, mux := newTestPushStore(t, PushOptions{})
+
+	require.Equal(t, http.StatusNoContent, pushRequest(t, mux, http.MethodPut, "a", testToken, "test_metric 1\n").Code)
+	require.Equal(t, http.StatusNoContent, pushRequest(t, mux, http.MethodPut, "b", testToken, "test_metric 2\n").Code)
+
+	assert.Equal(t, "windows_textfile_push_timestamp_seconds", gatherPushStore(t, store))
+}
+
+func TestPushStorePersistence(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	_, mux := newTestPushStore(t, PushOptions{PersistenceDirectory: dir})
+
+	require.Equal(t, http.StatusNoContent, pushRequest(t, mux, http.MethodPut, "a", testToken, "a_metric 1\n").Code)
+	require.Equal(t, http.StatusNoContent, pushRequest(t, mux, http.MethodPut, "b", testToken, "b_metric 1\n").Code)
+	require.Equal(t, http.StatusNoContent, pushRequest(t, mux, http.MethodDelete, "b", testToken, "").Code)
+
+	require.FileExists(t, filepath.Join(dir, "a.prom"))
+	require.NoFileExists(t, filepath.Join(dir, "b.prom"))
+
+	// Invalid files are skipped.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.prom"), []byte("c_metric{ 1\n"), 0o600))
+
+	store, _ := newTestPushStore(t, PushOptions{PersistenceDirectory: dir})
+	assert.Equal(t, "a_metric,windows_textfile_push_timestamp_seconds", gatherPushStore(t, store))
+}
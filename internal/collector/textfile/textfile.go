@@ -20,37 +20,73 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/kingpin/v2"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/dimchansky/utfbom"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
 )
 
-const Name = "textfile"
+const (
+	Name = "textfile"
+
+	defaultPattern = "*.prom"
+)
 
 type Config struct {
-	TextFileDirectories []string `yaml:"text_file_directories"`
+	TextFileDirectories []string    `yaml:"text_file_directories"`
+	DirectoryConfigs    []Directory `yaml:"directory_configs"`
+	// MaxDepth is the maximum number of directory levels read, counting the directory itself. 0 means no limit.
+	MaxDepth int `yaml:"max_depth"`
+	// MaxFileSize is the maximum size of a file in bytes. Larger files are rejected.
+	MaxFileSize int64 `yaml:"max_file_size"`
+	// Exclude are glob patterns of paths relative to the directory which are skipped.
+	Exclude        []string `yaml:"exclude"`
+	FollowSymlinks bool     `yaml:"follow_symlinks"`
+}
+
+// Directory is a directory with per-directory settings read by the textfile collector.
+type Directory struct {
+	Path string `yaml:"path"`
+	// Patterns are the glob patterns of the file names to read. Defaults to *.prom.
+	Patterns []string `yaml:"patterns"`
+	// Prefix is prepended to the names of all metrics read from the directory.
+	Prefix string `yaml:"prefix"`
+	// Labels are added to all metrics read from the directory.
+	Labels map[string]string `yaml:"labels"`
 }
 
 //nolint:gochecknoglobals
 var ConfigDefaults = Config{
 	TextFileDirectories: []string{getDefaultPath()},
+	DirectoryConfigs:    []Directory{},
+	MaxDepth:            0,
+	MaxFileSize:         16 << 20, // 16 MiB
+	Exclude:             []string{},
+	FollowSymlinks:      false,
 }
 
 type Collector struct {
 	config Config
 	logger *slog.Logger
 
+	directories []Directory
+
 	// Only set for testing to get predictable output.
 	mTime *float64
 
@@ -66,6 +102,18 @@ func New(config *Config) *Collector {
 		config.TextFileDirectories = ConfigDefaults.TextFileDirectories
 	}
 
+	if config.DirectoryConfigs == nil {
+		config.DirectoryConfigs = ConfigDefaults.DirectoryConfigs
+	}
+
+	if config.MaxFileSize == 0 {
+		config.MaxFileSize = ConfigDefaults.MaxFileSize
+	}
+
+	if config.Exclude == nil {
+		config.Exclude = ConfigDefaults.Exclude
+	}
+
 	c := &Collector{
 		config: *config,
 	}
@@ -78,16 +126,54 @@ func NewWithFlags(app *kingpin.Application) *Collector {
 		config: ConfigDefaults,
 	}
 
-	var textFileDirectories string
+	var textFileDirectories, directories, exclude string
 
 	app.Flag(
 		"collector.textfile.directories",
 		"Directory or Directories to read text files with metrics from.",
 	).Default(strings.Join(ConfigDefaults.TextFileDirectories, ",")).StringVar(&textFileDirectories)
 
+	app.Flag(
+		"collector.textfile.directory-configs",
+		"Directories with per-directory file patterns, metric name prefix and static labels. The value takes the form of a YAML array. See docs for more information on how to use this flag.",
+	).Default("").StringVar(&directories)
+
+	app.Flag(
+		"collector.textfile.max-depth",
+		"Maximum number of directory levels to read, counting the directory itself. 0 means no limit.",
+	).Default(strconv.Itoa(ConfigDefaults.MaxDepth)).IntVar(&c.config.MaxDepth)
+
+	app.Flag(
+		"collector.textfile.max-file-size",
+		"Maximum size of a text file in bytes. Larger files are rejected.",
+	).Default(strconv.FormatInt(ConfigDefaults.MaxFileSize, 10)).Int64Var(&c.config.MaxFileSize)
+
+	app.Flag(
+		"collector.textfile.exclude",
+		"Comma-separated list of glob patterns of paths relative to the directory which are skipped. See https://github.com/bmatcuk/doublestar#patterns",
+	).Default(strings.Join(ConfigDefaults.Exclude, ",")).StringVar(&exclude)
+
+	app.Flag(
+		"collector.textfile.follow-symlinks",
+		"Follow symbolic links and junctions. Directories reached more than once are read only once.",
+	).Default(strconv.FormatBool(ConfigDefaults.FollowSymlinks)).BoolVar(&c.config.FollowSymlinks)
+
 	app.Action(func(*kingpin.ParseContext) error {
 		c.config.TextFileDirectories = strings.Split(textFileDirectories, ",")
 
+		c.config.Exclude = make([]string, 0)
+		if exclude != "" {
+			c.config.Exclude = strings.Split(exclude, ",")
+		}
+
+		if directories == "" {
+			return nil
+		}
+
+		if err := yaml.Unmarshal([]byte(directories), &c.config.DirectoryConfigs); err != nil {
+			return fmt.Errorf("failed to parse directory configs %s: %w", directories, err)
+		}
+
 		return nil
 	})
 
@@ -105,7 +191,55 @@ func (c *Collector) Close() error {
 func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 	c.logger = logger.With(slog.String("collector", Name))
 
-	c.logger.Info("textfile directories: " + strings.Join(c.config.TextFileDirectories, ","))
+	c.directories = make([]Directory, 0, len(c.config.TextFileDirectories)+len(c.config.DirectoryConfigs))
+
+	for _, directory := range c.config.TextFileDirectories {
+		c.directories = append(c.directories, Directory{Path: directory})
+	}
+
+	var errs []error
+
+	for _, pattern := range c.config.Exclude {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, fmt.Errorf("invalid exclude pattern %q", pattern))
+		}
+	}
+
+	if c.config.MaxFileSize <= 0 {
+		errs = append(errs, errors.New("max file size must be greater than 0"))
+	}
+
+	for _, directory := range c.config.DirectoryConfigs {
+		if directory.Path == "" {
+			errs = append(errs, errors.New("directory path is required"))
+
+			continue
+		}
+
+		if err := directory.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("directory %q: %w", directory.Path, err))
+
+			continue
+		}
+
+		c.directories = append(c.directories, directory)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(c.directories))
+
+	for i, directory := range c.directories {
+		if len(directory.Patterns) == 0 {
+			c.directories[i].Patterns = []string{defaultPattern}
+		}
+
+		paths = append(paths, directory.Path)
+	}
+
+	c.logger.Info("textfile directories: " + strings.Join(paths, ","))
 
 	c.modTimeDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(types.Namespace, "textfile", "mtime_seconds"),
@@ -117,9 +251,9 @@ func (c *Collector) Build(logger *slog.Logger, _ *mi.Session) error {
 	return nil
 }
 
-// Given a slice of metric families, determine if any two entries are duplicates.
+// DuplicateMetricEntry determines if any two entries of a slice of metric families are duplicates.
 // Duplicates will be detected where the metric name, labels and label values are identical.
-func duplicateMetricEntry(metricFamilies []*dto.MetricFamily) bool {
+func DuplicateMetricEntry(metricFamilies []*dto.MetricFamily) bool {
 	uniqueMetrics := make(map[string]map[string]string)
 
 	for _, metricFamily := range metricFamilies {
@@ -147,7 +281,8 @@ func duplicateMetricEntry(metricFamilies []*dto.MetricFamily) bool {
 	return false
 }
 
-func (c *Collector) convertMetricFamily(logger *slog.Logger, metricFamily *dto.MetricFamily, ch chan<- prometheus.Metric) {
+// ConvertMetricFamily converts a parsed metric family into constant metrics and sends them to ch.
+func ConvertMetricFamily(logger *slog.Logger, metricFamily *dto.MetricFamily, ch chan<- prometheus.Metric) {
 	var valType prometheus.ValueType
 
 	var val float64
@@ -281,6 +416,26 @@ func (c *Collector) exportMTimes(modTimes map[string]time.Time, ch chan<- promet
 	}
 }
 
+var errFileTooLarge = errors.New("file too large")
+
+// limitedReader reads from r and fails once more than n bytes have been read.
+// Unlike [io.LimitedReader], it does not silently truncate the data.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, fmt.Errorf("%w: file grew while reading", errFileTooLarge)
+	}
+
+	return n, err
+}
+
 type carriageReturnFilteringReader struct {
 	r io.Reader
 }
@@ -318,76 +473,88 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) error {
 	errs := make([]error, 0)
 
 	// Iterate over files and accumulate their metrics.
-	for _, directory := range c.config.TextFileDirectories {
-		err := filepath.WalkDir(directory, func(path string, dirEntry os.DirEntry, err error) error {
-			if err != nil {
-				return fmt.Errorf("error reading directory: %w", err)
-			}
-
-			if !dirEntry.IsDir() && strings.HasSuffix(dirEntry.Name(), ".prom") {
-				c.logger.Debug("Processing file: " + path)
+	for _, directory := range c.directories {
+		w := &walker{
+			root:           directory.Path,
+			maxDepth:       c.config.MaxDepth,
+			exclude:        c.config.Exclude,
+			followSymlinks: c.config.FollowSymlinks,
+			match:          directory.matches,
+		}
 
-				families_array, err := scrapeFile(path, c.logger)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("error scraping file %q: %w", path, err))
+		err := w.walk(func(path string, fileInfo os.FileInfo) {
+			c.logger.Debug("Processing file: " + path)
 
-					return nil
-				}
+			// Files are identified by their path relative to the directory,
+			// so files at the top level keep their file name as label.
+			file, err := filepath.Rel(directory.Path, path)
+			if err != nil {
+				file = path
+			}
 
-				fileInfo, err := os.Stat(path)
-				if err != nil {
-					errs = append(errs, fmt.Errorf("error reading file info %q: %w", path, err))
+			if _, hasFile := mTimes[file]; hasFile {
+				errs = append(errs, fmt.Errorf("duplicate file detected: %q", path))
 
-					return nil
-				}
+				return
+			}
 
-				if _, hasName := mTimes[fileInfo.Name()]; hasName {
-					errs = append(errs, fmt.Errorf("duplicate filename detected: %q", path))
+			families_array, err := scrapeFile(path, c.logger, c.config.MaxFileSize)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error scraping file %q: %w", path, err))
 
-					return nil
-				}
+				return
+			}
 
-				mTimes[fileInfo.Name()] = fileInfo.ModTime()
+			if err = directory.apply(families_array); err != nil {
+				errs = append(errs, fmt.Errorf("error scraping file %q: %w", path, err))
 
-				metricFamilies = append(metricFamilies, families_array...)
+				return
 			}
 
-			return nil
+			mTimes[file] = fileInfo.ModTime()
+
+			metricFamilies = append(metricFamilies, families_array...)
 		})
 
-		if err != nil && directory != "" {
-			errs = append(errs, fmt.Errorf("error reading textfile directory %q: %w", directory, err))
+		errs = append(errs, w.errs...)
+
+		if err != nil && directory.Path != "" {
+			errs = append(errs, fmt.Errorf("error reading textfile directory %q: %w", directory.Path, err))
 		}
 	}
 
 	c.exportMTimes(mTimes, ch)
 
 	// If duplicates are detected across *multiple* files, return error.
-	if duplicateMetricEntry(metricFamilies) {
+	if DuplicateMetricEntry(metricFamilies) {
 		c.logger.Warn("duplicate metrics detected across multiple files")
 	} else {
 		for _, mf := range metricFamilies {
-			c.convertMetricFamily(c.logger, mf, ch)
+			ConvertMetricFamily(c.logger, mf, ch)
 		}
 	}
 
 	return errors.Join(errs...)
 }
 
-func scrapeFile(path string, logger *slog.Logger) ([]*dto.MetricFamily, error) {
+func scrapeFile(path string, logger *slog.Logger, maxFileSize int64) ([]*dto.MetricFamily, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var parser expfmt.TextParser
+	// The file may grow after the directory was read, so check the size of the opened file.
+	// Reading is limited as well, since the file may still grow while it is parsed.
+	var families_array []*dto.MetricFamily
 
-	r, encoding := utfbom.Skip(carriageReturnFilteringReader{r: file})
-	if err = checkBOM(encoding); err != nil {
-		return nil, err
+	fileInfo, err := file.Stat()
+	if err == nil && fileInfo.Size() > maxFileSize {
+		err = fmt.Errorf("%w: %d bytes exceeds limit of %d bytes", errFileTooLarge, fileInfo.Size(), maxFileSize)
 	}
 
-	parsedFamilies, err := parser.TextToMetricFamilies(r)
+	if err == nil {
+		families_array, err = ParseMetricFamilies(&limitedReader{r: file, n: maxFileSize}, path)
+	}
 
 	closeErr := file.Close()
 	if closeErr != nil {
@@ -400,6 +567,25 @@ func scrapeFile(path string, logger *slog.Logger) ([]*dto.MetricFamily, error) {
 		return nil, err
 	}
 
+	return families_array, nil
+}
+
+// ParseMetricFamilies parses metrics in the Prometheus text format from r.
+// BOMs other than UTF-8, client-side timestamps and duplicate metrics are rejected.
+// Metric families without help text get a help text referencing source.
+func ParseMetricFamilies(r io.Reader, source string) ([]*dto.MetricFamily, error) {
+	var parser expfmt.TextParser
+
+	r, encoding := utfbom.Skip(carriageReturnFilteringReader{r: r})
+	if err := checkBOM(encoding); err != nil {
+		return nil, err
+	}
+
+	parsedFamilies, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+
 	// Use temporary array to check for duplicates
 	families_array := make([]*dto.MetricFamily, 0, len(parsedFamilies))
 
@@ -413,19 +599,92 @@ func scrapeFile(path string, logger *slog.Logger) ([]*dto.MetricFamily, error) {
 		}
 
 		if mf.Help == nil {
-			help := "Metric read from " + path
+			help := "Metric read from " + source
 			mf.Help = &help
 		}
 	}
 
 	// If duplicate metrics are detected in a *single* file, skip processing of file metrics
-	if duplicateMetricEntry(families_array) {
+	if DuplicateMetricEntry(families_array) {
 		return nil, errors.New("duplicate metrics detected")
 	}
 
 	return families_array, nil
 }
 
+// validate checks the patterns, prefix and labels of the directory.
+func (d Directory) validate() error {
+	for _, pattern := range d.Patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	if d.Prefix != "" && !model.IsValidLegacyMetricName(d.Prefix) {
+		return fmt.Errorf("invalid prefix %q", d.Prefix)
+	}
+
+	for name := range d.Labels {
+		if !model.LabelName(name).IsValidLegacy() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the file name matches any pattern of the directory.
+func (d Directory) matches(name string) bool {
+	for _, pattern := range d.Patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apply adds the prefix and the static labels of the directory to the metric families.
+// Metrics with labels clashing with the static labels are rejected.
+func (d Directory) apply(metricFamilies []*dto.MetricFamily) error {
+	if d.Prefix == "" && len(d.Labels) == 0 {
+		return nil
+	}
+
+	for _, mf := range metricFamilies {
+		for _, m := range mf.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if _, ok := d.Labels[label.GetName()]; ok {
+					return fmt.Errorf("label %q of metric %q clashes with a static label of the directory", label.GetName(), mf.GetName())
+				}
+			}
+		}
+	}
+
+	// Sorting is needed for predictable label order.
+	labelNames := slices.Sorted(maps.Keys(d.Labels))
+
+	for _, mf := range metricFamilies {
+		if d.Prefix != "" {
+			name := d.Prefix + mf.GetName()
+			mf.Name = &name
+		}
+
+		for _, m := range mf.GetMetric() {
+			for _, name := range labelNames {
+				value := d.Labels[name]
+
+				m.Label = append(m.Label, &dto.LabelPair{
+					Name:  &name,
+					Value: &value,
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
 func checkBOM(encoding utfbom.Encoding) error {
 	if encoding == utfbom.Unknown || encoding == utfbom.UTF8 {
 		return nil
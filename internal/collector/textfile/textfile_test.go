@@ -17,11 +17,16 @@ package textfile
 
 import (
 	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/dimchansky/utfbom"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCRFilter(t *testing.T) {
@@ -120,7 +125,7 @@ func TestDuplicateMetricEntry(t *testing.T) {
 	duplicateFamily = append(duplicateFamily, &duplicate)
 
 	// Ensure detection for duplicate metrics
-	if !duplicateMetricEntry(duplicateFamily) {
+	if !DuplicateMetricEntry(duplicateFamily) {
 		t.Errorf("Duplicate not found in duplicateFamily")
 	}
 
@@ -147,7 +152,7 @@ func TestDuplicateMetricEntry(t *testing.T) {
 	duplicateFamily = append(duplicateFamily, &differentLabels)
 
 	// Additional label on second metric should not be cause for duplicate detection
-	if duplicateMetricEntry(duplicateFamily) {
+	if DuplicateMetricEntry(duplicateFamily) {
 		t.Errorf("Unexpected duplicate found in differentLabels")
 	}
 
@@ -172,7 +177,102 @@ func TestDuplicateMetricEntry(t *testing.T) {
 	duplicateFamily = append(duplicateFamily, &differentValues)
 
 	// Additional label with different values metric should not be cause for duplicate detection
-	if duplicateMetricEntry(duplicateFamily) {
+	if DuplicateMetricEntry(duplicateFamily) {
 		t.Errorf("Unexpected duplicate found in differentValues")
 	}
 }
+
+func TestDirectoryValidate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name      string
+		directory Directory
+		err       string
+	}{
+		{"valid", Directory{Patterns: []string{"*.prom", "metrics_?.txt"}, Prefix: "payments_", Labels: map[string]string{"team": "payments"}}, ""},
+		{"invalid pattern", Directory{Patterns: []string{"[*.prom"}}, "invalid pattern"},
+		{"invalid prefix", Directory{Prefix: "payments-"}, "invalid prefix"},
+		{"invalid label name", Directory{Labels: map[string]string{"team-name": "payments"}}, "invalid label name"},
+		{"reserved label name", Directory{Labels: map[string]string{"__name__": "payments"}}, "invalid label name"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.directory.validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestDirectoryMatches(t *testing.T) {
+	t.Parallel()
+
+	directory := Directory{Patterns: []string{"*.prom", "metrics_?.txt"}}
+
+	assert.True(t, directory.matches("file.prom"))
+	assert.True(t, directory.matches("metrics_1.txt"))
+	assert.False(t, directory.matches("metrics_10.txt"))
+	assert.False(t, directory.matches("file.prom.tmp"))
+}
+
+func TestDirectoryApply(t *testing.T) {
+	t.Parallel()
+
+	directory := Directory{
+		Prefix: "payments_",
+		Labels: map[string]string{"team": "payments", "env": "prod"},
+	}
+
+	families, err := ParseMetricFamilies(strings.NewReader("test_metric{source=\"file\"} 1\n"), "test")
+	require.NoError(t, err)
+	require.NoError(t, directory.apply(families))
+
+	require.Len(t, families, 1)
+	assert.Equal(t, "payments_test_metric", families[0].GetName())
+
+	labels := map[string]string{}
+	for _, label := range families[0].GetMetric()[0].GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+
+	assert.Equal(t, map[string]string{"source": "file", "team": "payments", "env": "prod"}, labels)
+
+	families, err = ParseMetricFamilies(strings.NewReader("test_metric{team=\"other\"} 1\n"), "test")
+	require.NoError(t, err)
+	require.ErrorContains(t, directory.apply(families), `label "team" of metric "test_metric" clashes with a static label`)
+}
+
+func TestScrapeFileMaxFileSize(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "file.prom")
+	content := "test_metric 1\n"
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	families, err := scrapeFile(path, logger, int64(len(content)))
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+
+	_, err = scrapeFile(path, logger, int64(len(content)-1))
+	require.ErrorIs(t, err, errFileTooLarge)
+}
+
+func TestLimitedReader(t *testing.T) {
+	t.Parallel()
+
+	b, err := io.ReadAll(&limitedReader{r: strings.NewReader("12345"), n: 5})
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(b))
+
+	_, err = io.ReadAll(&limitedReader{r: strings.NewReader("123456"), n: 5})
+	require.ErrorIs(t, err, errFileTooLarge)
+}
@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -73,7 +75,7 @@ func TestMultipleDirectories(t *testing.T) {
 }
 
 //nolint:paralleltest
-func TestDuplicateFileName(t *testing.T) {
+func TestSameFileNameInSubdirectories(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	testDir := baseDir + "/duplicate-filename"
 	textFileCollector := textfile.New(&textfile.Config{
@@ -85,6 +87,7 @@ func TestDuplicateFileName(t *testing.T) {
 
 	metrics := make(chan prometheus.Metric)
 	got := ""
+	files := make([]string, 0)
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -100,10 +103,96 @@ func TestDuplicateFileName(t *testing.T) {
 		require.NoError(t, err)
 
 		got += metric.String()
+
+		for _, label := range metric.GetLabel() {
+			if label.GetName() == "file" {
+				files = append(files, label.GetValue())
+			}
+		}
+	}
+
+	require.NoError(t, <-errCh)
+
+	assert.Contains(t, got, "sub_file")
+	assert.Contains(t, files, "file.prom")
+	assert.Contains(t, files, filepath.Join("sub", "file.prom"))
+}
+
+//nolint:paralleltest
+func TestDuplicateDirectory(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testDir := baseDir + "/duplicate-filename"
+	textFileCollector := textfile.New(&textfile.Config{
+		TextFileDirectories: []string{testDir, testDir},
+	})
+
+	collectors := collector.New(map[string]collector.Collector{textfile.Name: textFileCollector})
+	require.NoError(t, collectors.Build(logger))
+
+	metrics := make(chan prometheus.Metric)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- textFileCollector.Collect(metrics)
+
+		close(metrics)
+	}()
+
+	//nolint:revive
+	for range metrics {
 	}
 
-	require.ErrorContains(t, <-errCh, "duplicate filename detected")
+	require.ErrorContains(t, <-errCh, "duplicate file detected")
+}
+
+//nolint:paralleltest
+func TestDirectoryConfigs(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	dirPayments := t.TempDir()
+	dirBilling := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dirPayments, "jobs.prom"), []byte("jobs_total 1\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dirBilling, "jobs.txt"), []byte("jobs_total 2\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dirBilling, "ignored.prom"), []byte("ignored_total 3\n"), 0o600))
+
+	textFileCollector := textfile.New(&textfile.Config{
+		TextFileDirectories: []string{},
+		DirectoryConfigs: []textfile.Directory{
+			{Path: dirPayments, Labels: map[string]string{"team": "payments"}},
+			{Path: dirBilling, Patterns: []string{"*.txt"}, Prefix: "billing_", Labels: map[string]string{"team": "billing"}},
+		},
+	})
+
+	collectors := collector.New(map[string]collector.Collector{textfile.Name: textFileCollector})
+	require.NoError(t, collectors.Build(logger))
+
+	metrics := make(chan prometheus.Metric)
+	got := ""
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- textFileCollector.Collect(metrics)
+
+		close(metrics)
+	}()
+
+	for val := range metrics {
+		got += val.Desc().String()
+
+		var metric dto.Metric
+
+		err := val.Write(&metric)
+		require.NoError(t, err)
+
+		got += metric.String()
+	}
+
+	require.NoError(t, <-errCh)
 
-	assert.Contains(t, got, "file")
-	assert.NotContains(t, got, "sub_file")
+	assert.Contains(t, got, `fqName: "jobs_total"`)
+	assert.Contains(t, got, `fqName: "billing_jobs_total"`)
+	assert.Contains(t, got, "payments")
+	assert.Contains(t, got, "billing")
+	assert.NotContains(t, got, "ignored_total")
 }
@@ -0,0 +1,187 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package textfile
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// walker walks a directory tree with a depth limit and exclusion patterns.
+// Symbolic links and junctions are skipped, unless followSymlinks is set.
+// Directories reached more than once, e.g. through a link loop, are walked only once.
+// Likewise, files reached through several links are walked only once.
+type walker struct {
+	root           string
+	maxDepth       int
+	exclude        []string
+	followSymlinks bool
+	// match filters the files by name. All regular files are walked, if match is nil.
+	match func(name string) bool
+
+	visited []os.FileInfo
+	// files holds the resolved paths of the walked files. It's keyed by path instead of compared
+	// with os.SameFile like visited, since there are far more files than directories.
+	files map[string]struct{}
+	errs  []error
+}
+
+// walk calls fn for each regular file below the root directory.
+// The returned error is only non-nil if the root directory can't be read.
+// Errors of subdirectories are collected in w.errs.
+func (w *walker) walk(fn func(path string, info os.FileInfo)) error {
+	info, err := os.Stat(w.root)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", w.root)
+	}
+
+	w.visited = append(w.visited, info)
+
+	return w.walkDir(w.root, 1, fn)
+}
+
+func (w *walker) walkDir(dir string, depth int, fn func(path string, info os.FileInfo)) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		if w.excluded(path) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			w.errs = append(w.errs, fmt.Errorf("error reading file info %q: %w", path, err))
+
+			continue
+		}
+
+		// Junctions are reported as irregular files.
+		if info.Mode()&(fs.ModeSymlink|fs.ModeIrregular) != 0 {
+			if !w.followSymlinks {
+				continue
+			}
+
+			info, err = os.Stat(path)
+			if err != nil {
+				w.errs = append(w.errs, fmt.Errorf("error resolving link %q: %w", path, err))
+
+				continue
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			if w.maxDepth > 0 && depth >= w.maxDepth {
+				continue
+			}
+
+			// Use os.Stat to get a file info which can be compared by os.SameFile.
+			info, err = os.Stat(path)
+			if err != nil {
+				w.errs = append(w.errs, fmt.Errorf("error reading file info %q: %w", path, err))
+
+				continue
+			}
+
+			if w.isVisited(info) {
+				continue
+			}
+
+			w.visited = append(w.visited, info)
+
+			if err = w.walkDir(path, depth+1, fn); err != nil {
+				w.errs = append(w.errs, fmt.Errorf("error reading directory %q: %w", path, err))
+			}
+		case info.Mode().IsRegular():
+			// Files are matched before checking for duplicates, so a link with a name,
+			// which doesn't match, doesn't hide its target.
+			if w.match != nil && !w.match(info.Name()) {
+				continue
+			}
+
+			if w.followSymlinks && w.isWalked(path) {
+				continue
+			}
+
+			fn(path, info)
+		}
+	}
+
+	return nil
+}
+
+// excluded reports whether the path relative to the root matches any exclusion pattern.
+func (w *walker) excluded(path string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return false
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range w.exclude {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWalked reports whether the file was walked before, e.g. through a link, and marks it as walked.
+func (w *walker) isWalked(path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		w.errs = append(w.errs, fmt.Errorf("error resolving link %q: %w", path, err))
+
+		return true
+	}
+
+	if _, ok := w.files[resolved]; ok {
+		return true
+	}
+
+	if w.files == nil {
+		w.files = make(map[string]struct{})
+	}
+
+	w.files[resolved] = struct{}{}
+
+	return false
+}
+
+func (w *walker) isVisited(info os.FileInfo) bool {
+	for _, visited := range w.visited {
+		if os.SameFile(visited, info) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,171 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package textfile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTree creates the files below root. Parent directories are created as needed.
+func createTree(t *testing.T, root string, files ...string) {
+	t.Helper()
+
+	for _, file := range files {
+		path := filepath.Join(root, filepath.FromSlash(file))
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte("test_metric 1\n"), 0o600))
+	}
+}
+
+// walkTree returns the walked files relative to the root, using forward slashes.
+func walkTree(t *testing.T, w *walker) []string {
+	t.Helper()
+
+	var files []string
+
+	require.NoError(t, w.walk(func(path string, _ os.FileInfo) {
+		rel, err := filepath.Rel(w.root, path)
+		require.NoError(t, err)
+
+		files = append(files, filepath.ToSlash(rel))
+	}))
+
+	slices.Sort(files)
+
+	return files
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("creating symbolic links is not supported: %v", err)
+	}
+}
+
+func TestWalkMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	createTree(t, root, "a.prom", "sub/b.prom", "sub/sub/c.prom")
+
+	for _, tc := range []struct {
+		maxDepth int
+		expected []string
+	}{
+		{0, []string{"a.prom", "sub/b.prom", "sub/sub/c.prom"}},
+		{1, []string{"a.prom"}},
+		{2, []string{"a.prom", "sub/b.prom"}},
+		{3, []string{"a.prom", "sub/b.prom", "sub/sub/c.prom"}},
+	} {
+		assert.Equal(t, tc.expected, walkTree(t, &walker{root: root, maxDepth: tc.maxDepth}), "max depth %d", tc.maxDepth)
+	}
+}
+
+func TestWalkExclude(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	createTree(t, root, "a.prom", "a.prom.tmp", "archive/b.prom", "sub/archive/c.prom", "sub/d.prom")
+
+	files := walkTree(t, &walker{root: root, exclude: []string{"archive", "**/*.tmp"}})
+	assert.Equal(t, []string{"a.prom", "sub/archive/c.prom", "sub/d.prom"}, files)
+
+	files = walkTree(t, &walker{root: root, exclude: []string{"**/archive"}})
+	assert.Equal(t, []string{"a.prom", "a.prom.tmp", "sub/d.prom"}, files)
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	external := t.TempDir()
+
+	createTree(t, root, "a.prom", "sub/b.prom")
+	createTree(t, external, "external.prom")
+
+	symlink(t, external, filepath.Join(root, "link"))
+	symlink(t, filepath.Join(root, "a.prom"), filepath.Join(root, "file_link.prom"))
+
+	files := walkTree(t, &walker{root: root})
+	assert.Equal(t, []string{"a.prom", "sub/b.prom"}, files)
+
+	// file_link.prom is skipped, since its target a.prom was walked before.
+	files = walkTree(t, &walker{root: root, followSymlinks: true})
+	assert.Equal(t, []string{"a.prom", "link/external.prom", "sub/b.prom"}, files)
+}
+
+func TestWalkSymlinkedFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	createTree(t, root, "a.prom", "sub/b.prom")
+
+	symlink(t, filepath.Join(root, "sub", "b.prom"), filepath.Join(root, "b_link.prom"))
+	symlink(t, filepath.Join(root, "sub"), filepath.Join(root, "sub_link"))
+	symlink(t, filepath.Join(root, "a.prom"), filepath.Join(root, "0_a.txt"))
+
+	// Each file is walked once, however many links lead to it. The link 0_a.txt is walked first,
+	// but doesn't match, so it doesn't hide its target.
+	w := &walker{
+		root:           root,
+		followSymlinks: true,
+		match: func(name string) bool {
+			return filepath.Ext(name) == ".prom"
+		},
+	}
+
+	files := walkTree(t, w)
+	assert.Equal(t, []string{"a.prom", "b_link.prom"}, files)
+	assert.Empty(t, w.errs)
+}
+
+func TestWalkSymlinkLoop(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	createTree(t, root, "a.prom", "sub/b.prom")
+
+	symlink(t, root, filepath.Join(root, "sub", "loop"))
+	symlink(t, filepath.Join(root, "sub"), filepath.Join(root, "sub_link"))
+
+	w := &walker{root: root, followSymlinks: true}
+
+	files := walkTree(t, w)
+	assert.Len(t, files, 2)
+	assert.Contains(t, files, "a.prom")
+	assert.Empty(t, w.errs)
+}
+
+func TestWalkRootErrors(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	createTree(t, root, "a.prom")
+
+	w := &walker{root: filepath.Join(root, "missing")}
+	require.Error(t, w.walk(func(string, os.FileInfo) {}))
+
+	w = &walker{root: filepath.Join(root, "a.prom")}
+	require.ErrorContains(t, w.walk(func(string, os.FileInfo) {}), "is not a directory")
+}
@@ -11,8 +11,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build windows
-
 package udp_test
 
 import (
@@ -22,10 +20,6 @@ import (
 	"github.com/prometheus-community/windows_exporter/internal/utils/testutils"
 )
 
-func BenchmarkCollector(b *testing.B) {
-	testutils.FuncBenchmarkCollector(b, udp.Name, udp.NewWithFlags)
-}
-
 func TestCollector(t *testing.T) {
 	testutils.TestCollector(t, udp.New, nil)
 }
@@ -18,6 +18,9 @@ package config
 import (
 	"fmt"
 	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // flatten flattens the nested struct.
@@ -25,6 +28,9 @@ import (
 // All keys will be joined by dot
 // e.g. {"a": {"b":"c"}} => {"a.b":"c"}
 // or {"a": {"b":[1,2]}} => {"a.b.0":1, "a.b.1": 2}.
+//
+// Lists of objects or lists, like the objects of the performancecounter collector, are kept
+// as YAML document, since the flags of these values expect a YAML string.
 func flatten(data map[string]interface{}) map[string]string {
 	ret := make(map[string]string)
 
@@ -39,6 +45,12 @@ func flatten(data map[string]interface{}) map[string]string {
 				ret[fmt.Sprintf("%s.%s", k, fk)] = fv
 			}
 		case []interface{}:
+			if isStructuredSlice(typed) {
+				ret[k] = marshalSlice(typed)
+
+				continue
+			}
+
 			for fk, fv := range flattenSlice(typed) {
 				ret[fmt.Sprintf("%s.%s", k, fk)] = fv
 			}
@@ -50,6 +62,28 @@ func flatten(data map[string]interface{}) map[string]string {
 	return ret
 }
 
+// isStructuredSlice reports whether the slice contains objects or lists.
+func isStructuredSlice(data []interface{}) bool {
+	for _, v := range data {
+		switch v.(type) {
+		case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+			return true
+		}
+	}
+
+	return false
+}
+
+func marshalSlice(data []interface{}) string {
+	out, err := yaml.Marshal(data)
+	if err != nil {
+		// Values decoded by yaml.Unmarshal can always be encoded again.
+		return fmt.Sprint(data)
+	}
+
+	return strings.TrimSuffix(string(out), "\n")
+}
+
 func flattenSlice(data []interface{}) map[string]string {
 	ret := make(map[string]string)
 
@@ -51,3 +51,66 @@ func TestConfigFlattening(t *testing.T) {
 		t.Errorf("Flattened values do not match!\nExpected result: %s\nActual result: %s", expectedResult, flattenedValues)
 	}
 }
+
+// Lists of objects are passed as YAML document to the flag.
+func TestConfigFlatteningStructuredList(t *testing.T) {
+	t.Parallel()
+
+	yamlConfig := []byte(`---
+collector:
+  performancecounter:
+    objects:
+      - name: memory
+        object: Memory
+        counters:
+          - name: Cache Faults/sec
+            type: counter
+  service:
+    include: ["a", "b"]
+`)
+
+	var data map[string]interface{}
+
+	err := yaml.Unmarshal(yamlConfig, &data)
+	if err != nil {
+		t.Error(err)
+	}
+
+	flattenedValues := flatten(data)
+
+	expectedScalars := map[string]string{
+		"collector.service.include.0": "a",
+		"collector.service.include.1": "b",
+	}
+
+	for k, v := range expectedScalars {
+		if flattenedValues[k] != v {
+			t.Errorf("Flattened value %s does not match!\nExpected result: %s\nActual result: %s", k, v, flattenedValues[k])
+		}
+	}
+
+	objects, ok := flattenedValues["collector.performancecounter.objects"]
+	if !ok {
+		t.Fatalf("collector.performancecounter.objects not flattened: %v", flattenedValues)
+	}
+
+	var parsed []map[string]interface{}
+
+	if err := yaml.Unmarshal([]byte(objects), &parsed); err != nil {
+		t.Fatalf("failed to parse flattened objects %q: %v", objects, err)
+	}
+
+	expectedObjects := []map[string]interface{}{
+		{
+			"name":   "memory",
+			"object": "Memory",
+			"counters": []interface{}{
+				map[string]interface{}{"name": "Cache Faults/sec", "type": "counter"},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(expectedObjects, parsed) {
+		t.Errorf("Flattened objects do not match!\nExpected result: %v\nActual result: %v", expectedObjects, parsed)
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthLevel is the credential required to access a route.
+type AuthLevel int
+
+const (
+	// AuthNone allows access without a token.
+	AuthNone AuthLevel = iota
+	// AuthToken requires a token or an admin token.
+	AuthToken
+	// AuthAdmin requires an admin token.
+	AuthAdmin
+)
+
+func (l AuthLevel) String() string {
+	switch l {
+	case AuthNone:
+		return "none"
+	case AuthToken:
+		return "token"
+	case AuthAdmin:
+		return "admin"
+	default:
+		return fmt.Sprintf("AuthLevel(%d)", int(l))
+	}
+}
+
+// ParseAuthLevel parses the name of an AuthLevel.
+func ParseAuthLevel(s string) (AuthLevel, error) {
+	for _, level := range []AuthLevel{AuthNone, AuthToken, AuthAdmin} {
+		if level.String() == s {
+			return level, nil
+		}
+	}
+
+	return AuthNone, fmt.Errorf("unknown auth level %q, must be one of none, token, admin", s)
+}
+
+// DefaultAuthPolicies are the policies applied if not overridden.
+// The textfile push endpoint authenticates requests itself.
+// The perfcounter endpoint queries arbitrary performance counters and the probe endpoint sends the credentials
+// of its modules to remote targets, so both require an admin token.
+//
+//nolint:gochecknoglobals
+var DefaultAuthPolicies = map[string]AuthLevel{
+	"/debug/":      AuthAdmin,
+	"/health":      AuthNone,
+	"/perfcounter": AuthAdmin,
+	"/probe":       AuthAdmin,
+	"/textfile/":   AuthNone,
+}
+
+// ParseAuthPolicies parses a comma-separated list of prefix=level pairs, e.g. "/debug/=admin,/health=none".
+func ParseAuthPolicies(s string) (map[string]AuthLevel, error) {
+	policies := make(map[string]AuthLevel)
+
+	for _, policy := range strings.Split(s, ",") {
+		policy = strings.TrimSpace(policy)
+		if policy == "" {
+			continue
+		}
+
+		prefix, levelName, ok := strings.Cut(policy, "=")
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid auth policy %q, must be of the form /path=level", policy)
+		}
+
+		level, err := ParseAuthLevel(levelName)
+		if err != nil {
+			return nil, err
+		}
+
+		policies[prefix] = level
+	}
+
+	return policies, nil
+}
+
+type AuthOptions struct {
+	// TokenFiles contain the tokens granting AuthToken access.
+	TokenFiles []string
+	// AdminTokenFiles contain the tokens granting AuthAdmin access.
+	AdminTokenFiles []string
+	// Policies maps path prefixes to the required credential and are merged into DefaultAuthPolicies.
+	// A prefix matches the path itself and the paths below it, e.g. "/health" matches "/health" and "/health/x",
+	// but not "/healthz". Prefixes ending with "/" match all paths below them.
+	// The longest matching prefix wins. Paths without a matching prefix require AuthToken.
+	Policies map[string]AuthLevel
+}
+
+// Authenticator is a middleware, which requires a bearer token from a token file depending on the path of a request.
+// Token files contain one token per line. Empty lines and lines starting with # are ignored.
+// Token files are re-read once they changed, so tokens can be rotated without a restart. If a token file can't be
+// read or doesn't contain any token anymore, none of its tokens are accepted until it's fixed.
+type Authenticator struct {
+	logger      *slog.Logger
+	tokens      []*tokenFile
+	adminTokens []*tokenFile
+	policies    map[string]AuthLevel
+}
+
+// NewAuthenticator creates a new Authenticator. All token files must be readable and contain at least one token.
+func NewAuthenticator(logger *slog.Logger, options AuthOptions) (*Authenticator, error) {
+	a := &Authenticator{
+		logger:   logger.With(slog.String("component", "auth")),
+		policies: maps.Clone(DefaultAuthPolicies),
+	}
+
+	maps.Copy(a.policies, options.Policies)
+
+	var errs []error
+
+	for _, path := range options.TokenFiles {
+		file, err := newTokenFile(a.logger, path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		a.tokens = append(a.tokens, file)
+	}
+
+	for _, path := range options.AdminTokenFiles {
+		file, err := newTokenFile(a.logger, path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		a.adminTokens = append(a.adminTokens, file)
+	}
+
+	if len(a.tokens) == 0 && len(a.adminTokens) == 0 {
+		errs = append(errs, errors.New("at least one token file is required"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// policy returns the level required for the path.
+func (a *Authenticator) policy(path string) AuthLevel {
+	level := AuthToken
+	matched := ""
+
+	for prefix, prefixLevel := range a.policies {
+		if matchPrefix(path, prefix) && len(prefix) > len(matched) {
+			level = prefixLevel
+			matched = prefix
+		}
+	}
+
+	return level
+}
+
+// Accessible reports whether requests to the path can be authorized, i.e. whether token files
+// of the level required by the path are configured.
+func (a *Authenticator) Accessible(path string) bool {
+	switch a.policy(path) {
+	case AuthNone:
+		return true
+	case AuthToken:
+		return len(a.tokens) > 0 || len(a.adminTokens) > 0
+	default:
+		return len(a.adminTokens) > 0
+	}
+}
+
+// matchPrefix reports whether path is prefix or below it.
+func matchPrefix(path, prefix string) bool {
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// authenticate returns the level granted by the token of the request.
+func (a *Authenticator) authenticate(r *http.Request) AuthLevel {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return AuthNone
+	}
+
+	hash := sha256.Sum256([]byte(token))
+
+	if matchTokenFiles(a.adminTokens, hash) {
+		return AuthAdmin
+	}
+
+	if matchTokenFiles(a.tokens, hash) {
+		return AuthToken
+	}
+
+	return AuthNone
+}
+
+// Middleware wraps the handler and rejects requests without sufficient credentials.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := a.policy(r.URL.Path)
+		if required == AuthNone {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		granted := a.authenticate(r)
+
+		switch {
+		case granted == AuthNone:
+			a.logger.Debug("rejected request without valid token",
+				slog.String("remote", r.RemoteAddr),
+				slog.String("path", r.URL.Path),
+			)
+
+			w.Header().Set("WWW-Authenticate", `Bearer realm="windows_exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		case granted < required:
+			a.logger.Warn("rejected request with insufficient token",
+				slog.String("remote", r.RemoteAddr),
+				slog.String("path", r.URL.Path),
+				slog.String("required", required.String()),
+			)
+
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func matchTokenFiles(files []*tokenFile, hash [sha256.Size]byte) bool {
+	var match int
+
+	// Compare against all tokens to not leak which token matched by timing.
+	for _, file := range files {
+		for _, token := range file.get() {
+			match |= subtle.ConstantTimeCompare(token[:], hash[:])
+		}
+	}
+
+	return match == 1
+}
+
+// tokenFileCheckInterval is the minimum interval between two checks of a token file for changes.
+const tokenFileCheckInterval = time.Second
+
+// tokenFile holds the hashed tokens of a file and reloads them once the file changed.
+type tokenFile struct {
+	logger        *slog.Logger
+	path          string
+	checkInterval time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	modTime   time.Time
+	size      int64
+	tokens    [][sha256.Size]byte
+}
+
+func newTokenFile(logger *slog.Logger, path string) (*tokenFile, error) {
+	f := &tokenFile{
+		logger:        logger,
+		path:          path,
+		checkInterval: tokenFileCheckInterval,
+		lastCheck:     time.Now(),
+	}
+
+	if err := f.reload(); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// reload reads the file, if it changed since the last read. It must be called with mu held or before the file is shared.
+func (f *tokenFile) reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token file %s: %w", f.path, err)
+	}
+
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size && f.tokens != nil {
+		return nil
+	}
+
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token file %s: %w", f.path, err)
+	}
+
+	var tokens [][sha256.Size]byte
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens = append(tokens, sha256.Sum256([]byte(line)))
+	}
+
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read token file %s: %w", f.path, err)
+	}
+
+	if len(tokens) == 0 {
+		return fmt.Errorf("token file %s does not contain any token", f.path)
+	}
+
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.tokens = tokens
+
+	return nil
+}
+
+// get returns the current tokens. The file is checked for changes at most once per checkInterval.
+// If the file can't be reloaded, no tokens are returned until it's fixed.
+func (f *tokenFile) get() [][sha256.Size]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.lastCheck) < f.checkInterval {
+		return f.tokens
+	}
+
+	f.lastCheck = time.Now()
+
+	if err := f.reload(); err != nil {
+		f.logger.Warn("failed to reload token file, rejecting its tokens",
+			slog.Any("err", err),
+		)
+
+		f.modTime = time.Time{}
+		f.size = 0
+		f.tokens = nil
+	}
+
+	return f.tokens
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTokenFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func newTestAuthenticator(t *testing.T, policies map[string]AuthLevel) (*Authenticator, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "tokens")
+	adminTokenFile := filepath.Join(dir, "admin-tokens")
+
+	writeTokenFile(t, tokenFile, "# scrapers\nprometheus-a\n\nprometheus-b\n", time.Unix(1700000000, 0))
+	writeTokenFile(t, adminTokenFile, "admin\n", time.Unix(1700000000, 0))
+
+	authenticator, err := NewAuthenticator(slog.New(slog.NewTextHandler(io.Discard, nil)), AuthOptions{
+		TokenFiles:      []string{tokenFile},
+		AdminTokenFiles: []string{adminTokenFile},
+		Policies:        policies,
+	})
+	require.NoError(t, err)
+
+	return authenticator, tokenFile, adminTokenFile
+}
+
+func authRequest(handler http.Handler, path, token string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+func TestAuthenticatorPolicies(t *testing.T) {
+	t.Parallel()
+
+	authenticator, _, _ := newTestAuthenticator(t, map[string]AuthLevel{
+		"/version":       AuthNone,
+		"/debug/pprof/x": AuthToken,
+	})
+
+	handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/metrics", "", http.StatusUnauthorized},
+		{"/metrics", "wrong", http.StatusUnauthorized},
+		{"/metrics", "prometheus-a", http.StatusOK},
+		{"/metrics", "prometheus-b", http.StatusOK},
+		{"/metrics", "admin", http.StatusOK},
+		{"/metrics", "# scrapers", http.StatusUnauthorized},
+		{"/health", "", http.StatusOK},
+		{"/version", "", http.StatusOK},
+		// Prefixes only match whole path segments.
+		{"/healthz", "", http.StatusUnauthorized},
+		{"/versions", "", http.StatusUnauthorized},
+		{"/version/x", "", http.StatusOK},
+		{"/probex", "prometheus-a", http.StatusOK},
+		{"/textfile/job", "", http.StatusOK},
+		{"/debug/pprof/", "", http.StatusUnauthorized},
+		{"/debug/pprof/", "prometheus-a", http.StatusForbidden},
+		{"/debug/pprof/", "admin", http.StatusOK},
+		{"/probe", "prometheus-a", http.StatusForbidden},
+		{"/probe", "admin", http.StatusOK},
+		// The longest prefix wins.
+		{"/debug/pprof/x", "prometheus-a", http.StatusOK},
+	} {
+		assert.Equal(t, tc.code, authRequest(handler, tc.path, tc.token), "%s with token %q", tc.path, tc.token)
+	}
+}
+
+func TestAuthenticatorTokenRotation(t *testing.T) {
+	t.Parallel()
+
+	authenticator, tokenFile, _ := newTestAuthenticator(t, nil)
+	setTokenFileCheckInterval(authenticator, 0)
+
+	handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	require.Equal(t, http.StatusOK, authRequest(handler, "/metrics", "prometheus-a"))
+
+	writeTokenFile(t, tokenFile, "prometheus-c\n", time.Unix(1700000060, 0))
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(handler, "/metrics", "prometheus-a"))
+	assert.Equal(t, http.StatusOK, authRequest(handler, "/metrics", "prometheus-c"))
+
+	// Removed token files revoke their tokens.
+	require.NoError(t, os.Remove(tokenFile))
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(handler, "/metrics", "prometheus-c"))
+	assert.Equal(t, http.StatusOK, authRequest(handler, "/metrics", "admin"))
+
+	// The tokens are accepted again, once the file is restored.
+	writeTokenFile(t, tokenFile, "prometheus-c\n", time.Unix(1700000120, 0))
+
+	assert.Equal(t, http.StatusOK, authRequest(handler, "/metrics", "prometheus-c"))
+
+	// Emptied token files revoke their tokens as well.
+	writeTokenFile(t, tokenFile, "\n", time.Unix(1700000180, 0))
+
+	assert.Equal(t, http.StatusUnauthorized, authRequest(handler, "/metrics", "prometheus-c"))
+}
+
+func TestAuthenticatorTokenFileCheckInterval(t *testing.T) {
+	t.Parallel()
+
+	authenticator, tokenFile, _ := newTestAuthenticator(t, nil)
+	setTokenFileCheckInterval(authenticator, time.Hour)
+
+	handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	writeTokenFile(t, tokenFile, "prometheus-c\n", time.Unix(1700000060, 0))
+
+	// The file isn't checked again before the interval passed.
+	assert.Equal(t, http.StatusOK, authRequest(handler, "/metrics", "prometheus-a"))
+	assert.Equal(t, http.StatusUnauthorized, authRequest(handler, "/metrics", "prometheus-c"))
+}
+
+func setTokenFileCheckInterval(authenticator *Authenticator, interval time.Duration) {
+	for _, file := range slices.Concat(authenticator.tokens, authenticator.adminTokens) {
+		file.checkInterval = interval
+	}
+}
+
+func TestAuthenticatorAccessible(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	authenticator, _, _ := newTestAuthenticator(t, nil)
+	assert.True(t, authenticator.Accessible("/perfcounter"))
+
+	tokenFile := filepath.Join(t.TempDir(), "tokens")
+	writeTokenFile(t, tokenFile, "prometheus\n", time.Unix(1700000000, 0))
+
+	// Admin routes can't be accessed without admin token files.
+	authenticator, err := NewAuthenticator(logger, AuthOptions{TokenFiles: []string{tokenFile}})
+	require.NoError(t, err)
+
+	assert.True(t, authenticator.Accessible("/metrics"))
+	assert.True(t, authenticator.Accessible("/health"))
+	assert.False(t, authenticator.Accessible("/perfcounter"))
+
+	authenticator, err = NewAuthenticator(logger, AuthOptions{
+		TokenFiles: []string{tokenFile},
+		Policies:   map[string]AuthLevel{"/perfcounter": AuthToken},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, authenticator.Accessible("/perfcounter"))
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewAuthenticator(logger, AuthOptions{})
+	require.ErrorContains(t, err, "at least one token file is required")
+
+	_, err = NewAuthenticator(logger, AuthOptions{TokenFiles: []string{filepath.Join(t.TempDir(), "missing")}})
+	require.ErrorContains(t, err, "failed to read token file")
+
+	emptyFile := filepath.Join(t.TempDir(), "empty")
+	writeTokenFile(t, emptyFile, "# no tokens\n", time.Unix(1700000000, 0))
+
+	_, err = NewAuthenticator(logger, AuthOptions{TokenFiles: []string{emptyFile}})
+	require.ErrorContains(t, err, "does not contain any token")
+}
+
+func TestParseAuthPolicies(t *testing.T) {
+	t.Parallel()
+
+	policies, err := ParseAuthPolicies("/debug/=token, /metrics=none,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]AuthLevel{"/debug/": AuthToken, "/metrics": AuthNone}, policies)
+
+	_, err = ParseAuthPolicies("/metrics=root")
+	require.ErrorContains(t, err, `unknown auth level "root"`)
+
+	_, err = ParseAuthPolicies("metrics=none")
+	require.ErrorContains(t, err, "must be of the form /path=level")
+}
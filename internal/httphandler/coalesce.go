@@ -0,0 +1,162 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gatherCall is an in-flight or completed collection.
+type gatherCall struct {
+	done     chan struct{}
+	families []*dto.MetricFamily
+	err      error
+	finished time.Time
+}
+
+// coalescer coalesces concurrent scrapes with the same key, i.e. the same set of collectors and timeout,
+// onto a single collection.
+// The result of a collection is fanned out to all waiting requests. Additionally, the result is
+// reused by subsequent requests for up to maxStaleness. Since clients choose the key, results are
+// removed once they are finished or, with maxStaleness, older than maxStaleness.
+type coalescer struct {
+	maxStaleness time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*gatherCall
+
+	// Only set for testing to get predictable output.
+	now func() time.Time
+
+	collectionsTotal      prometheus.Counter
+	coalescedRequestTotal *prometheus.CounterVec
+}
+
+func newCoalescer(maxStaleness time.Duration) *coalescer {
+	return &coalescer{
+		maxStaleness: maxStaleness,
+		calls:        make(map[string]*gatherCall),
+		now:          time.Now,
+		collectionsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "scrape_collections_total",
+			Help:      "windows_exporter: Total number of collections triggered by scrape requests.",
+		}),
+		coalescedRequestTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "scrape_coalesced_requests_total",
+			Help:      "windows_exporter: Total number of scrape requests served by the result of another collection. reason is in_flight if the request waited for a running collection and max_staleness if a recent result was reused.",
+		}, []string{"reason"}),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *coalescer) Describe(ch chan<- *prometheus.Desc) {
+	c.collectionsTotal.Describe(ch)
+	c.coalescedRequestTotal.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *coalescer) Collect(ch chan<- prometheus.Metric) {
+	c.collectionsTotal.Collect(ch)
+	c.coalescedRequestTotal.Collect(ch)
+}
+
+// Gather returns the result of gather for the given key. If a collection for the key is in flight,
+// it waits for its result instead of starting a new one. The returned metric families are shared
+// between requests and must not be modified.
+func (c *coalescer) Gather(ctx context.Context, key string, gather func() ([]*dto.MetricFamily, error)) ([]*dto.MetricFamily, error) {
+	c.mu.Lock()
+
+	c.evictStale()
+
+	call, ok := c.calls[key]
+	if ok {
+		select {
+		case <-call.done:
+			if c.now().Sub(call.finished) > c.maxStaleness {
+				ok = false
+			} else {
+				c.coalescedRequestTotal.WithLabelValues("max_staleness").Inc()
+			}
+		default:
+			c.coalescedRequestTotal.WithLabelValues("in_flight").Inc()
+		}
+	}
+
+	if !ok {
+		call = &gatherCall{done: make(chan struct{})}
+		c.calls[key] = call
+		c.collectionsTotal.Inc()
+
+		c.mu.Unlock()
+
+		defer func() {
+			c.mu.Lock()
+			call.finished = c.now()
+
+			if c.maxStaleness <= 0 && c.calls[key] == call {
+				delete(c.calls, key)
+			}
+
+			c.mu.Unlock()
+
+			close(call.done)
+		}()
+
+		// The collection is not bound to the context of the request,
+		// since other requests may wait for the result.
+		call.families, call.err = gather()
+
+		return call.families, call.err
+	}
+
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.families, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// evictStale removes finished calls older than maxStaleness. c.mu must be held.
+func (c *coalescer) evictStale() {
+	now := c.now()
+
+	for key, call := range c.calls {
+		// finished is set while holding c.mu, before done is closed.
+		if !call.finished.IsZero() && now.Sub(call.finished) > c.maxStaleness {
+			delete(c.calls, key)
+		}
+	}
+}
+
+// gathererFunc turns a function into a prometheus.Gatherer.
+type gathererFunc func() ([]*dto.MetricFamily, error)
+
+func (f gathererFunc) Gather() ([]*dto.MetricFamily, error) {
+	return f()
+}
@@ -0,0 +1,302 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus-community/windows_exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowCollector is a fake collector which blocks until release is closed.
+type slowCollector struct {
+	collections atomic.Int64
+	started     chan struct{}
+	release     chan struct{}
+	desc        *prometheus.Desc
+}
+
+func newSlowCollector() *slowCollector {
+	return &slowCollector{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+		desc:    prometheus.NewDesc("windows_slow_collections", "Number of collections.", nil, nil),
+	}
+}
+
+func (c *slowCollector) GetName() string { return "slow" }
+
+func (c *slowCollector) Build(*slog.Logger, *mi.Session) error { return nil }
+
+func (c *slowCollector) Close() error { return nil }
+
+func (c *slowCollector) Collect(ch chan<- prometheus.Metric) error {
+	n := c.collections.Add(1)
+	c.started <- struct{}{}
+
+	<-c.release
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, float64(n))
+
+	return nil
+}
+
+func TestConcurrentScrapesAreCoalesced(t *testing.T) {
+	t.Parallel()
+
+	slow := newSlowCollector()
+	handler := New(slog.New(slog.NewTextHandler(io.Discard, nil)), collector.New(collector.Map{"slow": slow}), &Options{
+		TimeoutMargin: 0.5,
+	})
+
+	var (
+		wg     sync.WaitGroup
+		bodies [2]string
+	)
+
+	for i := range bodies {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			bodies[i] = rec.Body.String()
+		}()
+
+		// Wait for the first request to start the collection.
+		if i == 0 {
+			<-slow.started
+		}
+	}
+
+	// Wait until the second request waits for the in-flight collection.
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(handler.coalescer.coalescedRequestTotal.WithLabelValues("in_flight")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(slow.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), slow.collections.Load())
+	assert.Equal(t, 1.0, testutil.ToFloat64(handler.coalescer.collectionsTotal))
+
+	for _, body := range bodies {
+		assert.Contains(t, body, "windows_slow_collections 1\n")
+	}
+
+	// Subsequent scrapes trigger a new collection.
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Contains(t, rec.Body.String(), "windows_slow_collections 2\n")
+	assert.Equal(t, int64(2), slow.collections.Load())
+}
+
+func TestCoalescerMaxStaleness(t *testing.T) {
+	t.Parallel()
+
+	c := newCoalescer(time.Minute)
+
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	var collections int
+
+	gather := func() ([]*dto.MetricFamily, error) {
+		collections++
+
+		return nil, nil
+	}
+
+	for range 3 {
+		_, err := c.Gather(context.Background(), "", gather)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, collections)
+
+	// Different sets of collectors are collected independently.
+	_, err := c.Gather(context.Background(), "cpu", gather)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, collections)
+
+	now = now.Add(2 * time.Minute)
+
+	_, err = c.Gather(context.Background(), "", gather)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, collections)
+
+	expected := `
+# HELP windows_exporter_scrape_coalesced_requests_total windows_exporter: Total number of scrape requests served by the result of another collection. reason is in_flight if the request waited for a running collection and max_staleness if a recent result was reused.
+# TYPE windows_exporter_scrape_coalesced_requests_total counter
+windows_exporter_scrape_coalesced_requests_total{reason="max_staleness"} 2
+# HELP windows_exporter_scrape_collections_total windows_exporter: Total number of collections triggered by scrape requests.
+# TYPE windows_exporter_scrape_collections_total counter
+windows_exporter_scrape_collections_total 3
+`
+	require.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(expected)))
+}
+
+func TestCoalescerWaiterContext(t *testing.T) {
+	t.Parallel()
+
+	c := newCoalescer(0)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	go func() {
+		_, _ = c.Gather(context.Background(), "", func() ([]*dto.MetricFamily, error) {
+			close(started)
+			<-release
+
+			return nil, nil
+		})
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// A waiting request gives up on its own deadline without affecting the collection.
+	_, err := c.Gather(ctx, "", func() ([]*dto.MetricFamily, error) {
+		t.Error("unexpected collection")
+
+		return nil, nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+}
+
+func TestScrapesWithDifferentTimeoutsAreNotCoalesced(t *testing.T) {
+	t.Parallel()
+
+	slow := newSlowCollector()
+	handler := New(slog.New(slog.NewTextHandler(io.Discard, nil)), collector.New(collector.Map{"slow": slow}), &Options{
+		TimeoutMargin: 0.5,
+	})
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	first := httptest.NewRecorder()
+
+	go func() {
+		defer wg.Done()
+
+		handler.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/metrics?timeout=5", nil))
+	}()
+
+	<-slow.started
+
+	// A scrape with another timeout doesn't wait for the in-flight collection, but starts its own.
+	// It skips the slow collector, since its previous collection is still running.
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/metrics?timeout=8", nil))
+
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, 2.0, testutil.ToFloat64(handler.coalescer.collectionsTotal))
+	assert.Zero(t, testutil.ToFloat64(handler.coalescer.coalescedRequestTotal.WithLabelValues("in_flight")))
+
+	close(slow.release)
+	wg.Wait()
+
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, int64(1), slow.collections.Load())
+
+	// Each scrape reports its own timeout.
+	assert.Contains(t, first.Body.String(), "windows_exporter_scrape_timeout_seconds 4.5\n")
+	assert.Contains(t, first.Body.String(), "windows_slow_collections 1\n")
+	assert.Contains(t, second.Body.String(), "windows_exporter_scrape_timeout_seconds 7.5\n")
+	assert.NotContains(t, second.Body.String(), "windows_slow_collections")
+}
+
+func TestCoalescerRemovesFinishedCalls(t *testing.T) {
+	t.Parallel()
+
+	handler := New(slog.New(slog.NewTextHandler(io.Discard, nil)), collector.New(collector.Map{}), &Options{
+		TimeoutMargin: 0.5,
+	})
+
+	// Each timeout is a distinct key, which must not be retained after the scrape.
+	for i := range 100 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics?timeout=5."+strconv.Itoa(i), nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	handler.coalescer.mu.Lock()
+	defer handler.coalescer.mu.Unlock()
+
+	assert.Empty(t, handler.coalescer.calls)
+}
+
+func TestCoalescerEvictsStaleCalls(t *testing.T) {
+	t.Parallel()
+
+	c := newCoalescer(time.Minute)
+
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	gather := func() ([]*dto.MetricFamily, error) {
+		return nil, nil
+	}
+
+	for i := range 100 {
+		_, err := c.Gather(context.Background(), strconv.Itoa(i), gather)
+		require.NoError(t, err)
+	}
+
+	// Results are kept for up to maxStaleness.
+	assert.Len(t, c.calls, 100)
+
+	now = now.Add(2 * time.Minute)
+
+	_, err := c.Gather(context.Background(), "new", gather)
+	require.NoError(t, err)
+
+	// Stale results are evicted on the next lookup.
+	assert.Len(t, c.calls, 1)
+	assert.Contains(t, c.calls, "new")
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultCompressions are the content encodings offered by default, in order of preference.
+//
+//nolint:gochecknoglobals
+var DefaultCompressions = []promhttp.Compression{promhttp.Zstd, promhttp.Gzip, promhttp.Identity}
+
+// ParseCompressions parses a comma-separated list of content encodings. The order defines the preference,
+// if a client accepts multiple encodings with the same quality.
+func ParseCompressions(list string) ([]promhttp.Compression, error) {
+	var compressions []promhttp.Compression
+
+	for _, value := range strings.Split(list, ",") {
+		compression := promhttp.Compression(strings.TrimSpace(value))
+		if compression == "" {
+			continue
+		}
+
+		if !slices.Contains([]promhttp.Compression{promhttp.Identity, promhttp.Gzip, promhttp.Zstd}, compression) {
+			return nil, fmt.Errorf("unknown compression %q, must be one of identity, gzip, zstd", compression)
+		}
+
+		compressions = append(compressions, compression)
+	}
+
+	if len(compressions) == 0 {
+		return []promhttp.Compression{promhttp.Identity}, nil
+	}
+
+	return compressions, nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus-community/windows_exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const protobufDelimitedAccept = "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited"
+
+// zstdMagic is the magic number at the start of each zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// syntheticCollector exposes families × series gauges with a few labels each.
+type syntheticCollector struct {
+	descs  []*prometheus.Desc
+	series int
+}
+
+func newSyntheticCollector(families, series int) *syntheticCollector {
+	c := &syntheticCollector{series: series}
+
+	for i := range families {
+		c.descs = append(c.descs, prometheus.NewDesc(
+			fmt.Sprintf("windows_synthetic_metric_%d", i),
+			"Synthetic metric for tests.",
+			[]string{"instance", "volume", "state"},
+			nil,
+		))
+	}
+
+	return c
+}
+
+func (c *syntheticCollector) GetName() string { return "synthetic" }
+
+func (c *syntheticCollector) Build(*slog.Logger, *mi.Session) error { return nil }
+
+func (c *syntheticCollector) Close() error { return nil }
+
+func (c *syntheticCollector) Collect(ch chan<- prometheus.Metric) error {
+	for _, desc := range c.descs {
+		for i := range c.series {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(i)*1.5,
+				"instance_"+strconv.Itoa(i), "C:", "running",
+			)
+		}
+	}
+
+	return nil
+}
+
+func newSyntheticHandler(families, series int, compressions []promhttp.Compression) *MetricsHTTPHandler {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), collector.New(collector.Map{
+		"synthetic": newSyntheticCollector(families, series),
+	}), &Options{
+		TimeoutMargin: 0.5,
+		Compressions:  compressions,
+	})
+}
+
+func scrape(handler http.Handler, accept, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestContentEncodingNegotiation(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name           string
+		compressions   []promhttp.Compression
+		acceptEncoding string
+		expected       string
+	}{
+		{"no compression requested", nil, "", ""},
+		{"gzip", nil, "gzip", "gzip"},
+		{"zstd", nil, "zstd", "zstd"},
+		{"zstd is preferred", nil, "gzip, zstd", "zstd"},
+		{"client preference", nil, "zstd;q=0.5, gzip", "gzip"},
+		{"configured preference", []promhttp.Compression{promhttp.Gzip, promhttp.Zstd}, "gzip, zstd", "gzip"},
+		{"not offered", []promhttp.Compression{promhttp.Gzip, promhttp.Identity}, "zstd", ""},
+	} {
+		rec := scrape(newSyntheticHandler(1, 10, tc.compressions), "", tc.acceptEncoding)
+		require.Equal(t, http.StatusOK, rec.Code, tc.name)
+
+		if tc.expected == "identity" || tc.expected == "" {
+			assert.Empty(t, rec.Header().Get("Content-Encoding"), tc.name)
+			assert.Contains(t, rec.Body.String(), `windows_synthetic_metric_0{instance="instance_9",state="running",volume="C:"} 13.5`, tc.name)
+
+			continue
+		}
+
+		assert.Equal(t, tc.expected, rec.Header().Get("Content-Encoding"), tc.name)
+
+		switch tc.expected {
+		case "gzip":
+			reader, err := gzip.NewReader(rec.Body)
+			require.NoError(t, err, tc.name)
+
+			body, err := io.ReadAll(reader)
+			require.NoError(t, err, tc.name)
+			assert.Contains(t, string(body), `windows_synthetic_metric_0{instance="instance_9",state="running",volume="C:"} 13.5`, tc.name)
+		case "zstd":
+			assert.True(t, bytes.HasPrefix(rec.Body.Bytes(), zstdMagic), tc.name)
+		}
+	}
+}
+
+func TestProtobufNegotiation(t *testing.T) {
+	t.Parallel()
+
+	rec := scrape(newSyntheticHandler(3, 5, nil), protobufDelimitedAccept, "gzip")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, expfmt.TypeProtoDelim, expfmt.Format(rec.Header().Get("Content-Type")).FormatType())
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+
+	decoder := expfmt.NewDecoder(reader, expfmt.NewFormat(expfmt.TypeProtoDelim))
+
+	series := map[string]int{}
+
+	for {
+		var family dto.MetricFamily
+
+		err := decoder.Decode(&family)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+
+		series[family.GetName()] = len(family.GetMetric())
+	}
+
+	for i := range 3 {
+		assert.Equal(t, 5, series[fmt.Sprintf("windows_synthetic_metric_%d", i)])
+	}
+
+	// Native histograms are only exposed in the protobuf format.
+	assert.Contains(t, series, "windows_exporter_collector_latency_seconds")
+}
+
+func BenchmarkMetricsHTTPHandler(b *testing.B) {
+	handler := newSyntheticHandler(50, 1000, nil)
+
+	for _, format := range []struct {
+		name   string
+		accept string
+	}{
+		{"text", ""},
+		{"openmetrics", "application/openmetrics-text;version=1.0.0"},
+		{"protobuf", protobufDelimitedAccept},
+	} {
+		for _, encoding := range []string{"identity", "gzip", "zstd"} {
+			b.Run(format.name+"/"+encoding, func(b *testing.B) {
+				var size int
+
+				b.ReportAllocs()
+
+				for range b.N {
+					rec := scrape(handler, format.accept, encoding)
+					if rec.Code != http.StatusOK {
+						b.Fatalf("unexpected status code %d", rec.Code)
+					}
+
+					size = rec.Body.Len()
+				}
+
+				b.ReportMetric(float64(size), "bytes/scrape")
+			})
+		}
+	}
+}
+
+func TestParseCompressions(t *testing.T) {
+	t.Parallel()
+
+	compressions, err := ParseCompressions(" gzip,zstd ,")
+	require.NoError(t, err)
+	assert.Equal(t, []promhttp.Compression{promhttp.Gzip, promhttp.Zstd}, compressions)
+
+	compressions, err = ParseCompressions("")
+	require.NoError(t, err)
+	assert.Equal(t, []promhttp.Compression{promhttp.Identity}, compressions)
+
+	_, err = ParseCompressions("gzip,br")
+	require.ErrorContains(t, err, `unknown compression "br"`)
+}
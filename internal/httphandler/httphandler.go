@@ -16,56 +16,66 @@
 package httphandler
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus-community/windows_exporter/internal/types"
 	"github.com/prometheus-community/windows_exporter/pkg/collector"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/collectors/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // Interface guard.
 var _ http.Handler = (*MetricsHTTPHandler)(nil)
 
-const defaultScrapeTimeout = 10.0
-
 type MetricsHTTPHandler struct {
 	metricCollectors *collector.Collection
 	// exporterMetricsRegistry is a separate registry for the metrics about
 	// the exporter itself.
 	exporterMetricsRegistry *prometheus.Registry
 
-	logger        *slog.Logger
-	options       Options
-	concurrencyCh chan struct{}
+	logger    *slog.Logger
+	options   Options
+	coalescer *coalescer
 }
 
 type Options struct {
 	DisableExporterMetrics bool
 	TimeoutMargin          float64
+	// Compressions are the content encodings offered to clients, in order of preference. Defaults to DefaultCompressions.
+	// The exposition format, including the delimited protobuf format, is negotiated independently.
+	Compressions []promhttp.Compression
+	// MinScrapeTimeout is the lower bound of the scrape timeout after subtracting the TimeoutMargin.
+	MinScrapeTimeout time.Duration
+	// MaxScrapeTimeout is the upper bound of the scrape timeout after subtracting the TimeoutMargin.
+	MaxScrapeTimeout time.Duration
+	// MaxStaleness is the duration the result of a collection is reused for subsequent scrapes.
+	// Concurrent scrapes of the same collectors with the same timeout are always coalesced onto a single collection.
+	MaxStaleness time.Duration
+	// AdditionalCollectors are registered for each scrape, independent of the requested collectors.
+	AdditionalCollectors []prometheus.Collector
 }
 
 func New(logger *slog.Logger, metricCollectors *collector.Collection, options *Options) *MetricsHTTPHandler {
-	if options == nil {
-		options = &Options{
-			DisableExporterMetrics: false,
-			TimeoutMargin:          0.5,
-		}
-	}
+	options = withDefaults(options)
 
 	handler := &MetricsHTTPHandler{
 		metricCollectors: metricCollectors,
 		logger:           logger,
 		options:          *options,
 
-		// We are expose metrics directly from the memory region of the Win32 API. We should not allow more than one request at a time.
-		concurrencyCh: make(chan struct{}, 1),
+		// We are expose metrics directly from the memory region of the Win32 API.
+		// Concurrent scrapes share a single collection instead of running the collectors multiple times.
+		coalescer: newCoalescer(options.MaxStaleness),
 	}
 
 	if !options.DisableExporterMetrics {
@@ -74,21 +84,54 @@ func New(logger *slog.Logger, metricCollectors *collector.Collection, options *O
 			collectors.NewBuildInfoCollector(),
 			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 			collectors.NewGoCollector(),
+			handler.coalescer,
 		)
 	}
 
 	return handler
 }
 
+// withDefaults returns the options with defaults applied to unset fields.
+func withDefaults(options *Options) *Options {
+	if options == nil {
+		options = &Options{
+			DisableExporterMetrics: false,
+			TimeoutMargin:          0.5,
+			MinScrapeTimeout:       DefaultMinScrapeTimeout,
+			MaxScrapeTimeout:       DefaultMaxScrapeTimeout,
+		}
+	}
+
+	if len(options.Compressions) == 0 {
+		options.Compressions = DefaultCompressions
+	}
+
+	if options.MinScrapeTimeout <= 0 {
+		options.MinScrapeTimeout = DefaultMinScrapeTimeout
+	}
+
+	if options.MaxScrapeTimeout <= 0 {
+		options.MaxScrapeTimeout = DefaultMaxScrapeTimeout
+	}
+
+	return options
+}
+
 func (c *MetricsHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	logger := c.logger.With(
 		slog.Any("remote", r.RemoteAddr),
 		slog.Any("correlation_id", uuid.New().String()),
 	)
 
-	scrapeTimeout := c.getScrapeTimeout(logger, r)
+	scrapeTimeout, err := c.options.getScrapeTimeout(logger, r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error()))
+
+		return
+	}
 
-	handler, err := c.handlerFactory(logger, scrapeTimeout, r.URL.Query()["collect[]"])
+	handler, err := c.handlerFactory(r.Context(), logger, scrapeTimeout, r.URL.Query()["collect[]"])
 	if err != nil {
 		logger.Warn("Couldn't create filtered metrics handler",
 			slog.Any("err", err),
@@ -103,31 +146,25 @@ func (c *MetricsHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
-func (c *MetricsHTTPHandler) getScrapeTimeout(logger *slog.Logger, r *http.Request) time.Duration {
-	var timeoutSeconds float64
-
-	if v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
-		var err error
+func (c *MetricsHTTPHandler) handlerFactory(ctx context.Context, logger *slog.Logger, scrapeTimeout time.Duration, requestedCollectors []string) (http.Handler, error) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(version.NewCollector("windows_exporter"))
 
-		timeoutSeconds, err = strconv.ParseFloat(v, 64)
-		if err != nil {
-			logger.Warn(fmt.Sprintf("Couldn't parse X-Prometheus-Scrape-Timeout-Seconds: %q. Defaulting timeout to %f", v, defaultScrapeTimeout))
+	scrapeTimeoutGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: types.Namespace,
+		Subsystem: "exporter",
+		Name:      "scrape_timeout_seconds",
+		Help:      "windows_exporter: Effective timeout of the scrape after applying the timeout margin.",
+	})
+	scrapeTimeoutGauge.Set(scrapeTimeout.Seconds())
+	reg.MustRegister(scrapeTimeoutGauge)
+
+	for _, additionalCollector := range c.options.AdditionalCollectors {
+		if err := reg.Register(additionalCollector); err != nil {
+			return nil, fmt.Errorf("couldn't register additional Prometheus collector: %w", err)
 		}
 	}
 
-	if timeoutSeconds == 0 {
-		timeoutSeconds = defaultScrapeTimeout
-	}
-
-	timeoutSeconds -= c.options.TimeoutMargin
-
-	return time.Duration(timeoutSeconds) * time.Second
-}
-
-func (c *MetricsHTTPHandler) handlerFactory(logger *slog.Logger, scrapeTimeout time.Duration, requestedCollectors []string) (http.Handler, error) {
-	reg := prometheus.NewRegistry()
-	reg.MustRegister(version.NewCollector("windows_exporter"))
-
 	collectionHandler, err := c.metricCollectors.NewHandler(scrapeTimeout, c.logger, requestedCollectors)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't create collector handler: %w", err)
@@ -137,14 +174,21 @@ func (c *MetricsHTTPHandler) handlerFactory(logger *slog.Logger, scrapeTimeout t
 		return nil, fmt.Errorf("couldn't register Prometheus collector: %w", err)
 	}
 
+	// Scrapes of the same set of collectors share a single collection. Since the result reports the effective
+	// timeout and depends on it, it's only shared between scrapes with the same timeout.
+	key := scrapeTimeout.String() + "|" + strings.Join(slices.Sorted(slices.Values(requestedCollectors)), ",")
+	gatherer := gathererFunc(func() ([]*dto.MetricFamily, error) {
+		return c.coalescer.Gather(ctx, key, reg.Gather)
+	})
+
 	var regHandler http.Handler
 	if c.exporterMetricsRegistry != nil {
 		regHandler = promhttp.HandlerFor(
-			prometheus.Gatherers{c.exporterMetricsRegistry, reg},
+			prometheus.Gatherers{c.exporterMetricsRegistry, gatherer},
 			promhttp.HandlerOpts{
 				ErrorLog:            slog.NewLogLogger(logger.Handler(), slog.LevelError),
 				ErrorHandling:       promhttp.ContinueOnError,
-				MaxRequestsInFlight: 1,
+				OfferedCompressions: c.options.Compressions,
 				Registry:            c.exporterMetricsRegistry,
 				EnableOpenMetrics:   true,
 				ProcessStartTime:    c.metricCollectors.GetStartTime(),
@@ -158,11 +202,11 @@ func (c *MetricsHTTPHandler) handlerFactory(logger *slog.Logger, scrapeTimeout t
 		)
 	} else {
 		regHandler = promhttp.HandlerFor(
-			reg,
+			gatherer,
 			promhttp.HandlerOpts{
 				ErrorLog:            slog.NewLogLogger(logger.Handler(), slog.LevelError),
 				ErrorHandling:       promhttp.ContinueOnError,
-				MaxRequestsInFlight: 1,
+				OfferedCompressions: c.options.Compressions,
 				EnableOpenMetrics:   true,
 				ProcessStartTime:    c.metricCollectors.GetStartTime(),
 			},
@@ -0,0 +1,161 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Interface guard.
+var _ prometheus.Collector = (*HTTPMetrics)(nil)
+
+// unmatchedRoute is the handler label of requests, which don't match any route of the mux.
+const unmatchedRoute = "unmatched"
+
+// requestDurationBuckets covers the scrape timeouts commonly used by Prometheus.
+//
+//nolint:gochecknoglobals
+var requestDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// HTTPMetrics instruments the routes of a [http.ServeMux].
+type HTTPMetrics struct {
+	metricsPath string
+
+	requestsTotal       *prometheus.CounterVec
+	requestDuration     *prometheus.HistogramVec
+	responseSize        *prometheus.HistogramVec
+	requestsInFlight    prometheus.Gauge
+	scrapeRequestsTotal *prometheus.CounterVec
+}
+
+// NewHTTPMetrics creates the HTTP metrics. Requests to metricsPath are additionally counted as scrapes.
+func NewHTTPMetrics(metricsPath string) *HTTPMetrics {
+	return &HTTPMetrics{
+		metricsPath: metricsPath,
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "http_requests_total",
+			Help:      "windows_exporter: Total number of HTTP requests by route, status code and method.",
+		}, []string{"handler", "code", "method"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:                       types.Namespace,
+			Subsystem:                       "exporter",
+			Name:                            "http_request_duration_seconds",
+			Help:                            "windows_exporter: Histogram of HTTP request durations by route.",
+			Buckets:                         requestDurationBuckets,
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: time.Hour,
+		}, []string{"handler"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:                       types.Namespace,
+			Subsystem:                       "exporter",
+			Name:                            "http_response_size_bytes",
+			Help:                            "windows_exporter: Histogram of HTTP response sizes by route, after compression.",
+			Buckets:                         prometheus.ExponentialBuckets(100, 4, 10),
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: time.Hour,
+		}, []string{"handler"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "http_requests_in_flight",
+			Help:      "windows_exporter: Number of HTTP requests currently served.",
+		}),
+		scrapeRequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "scrape_requests_total",
+			Help:      "windows_exporter: Total number of scrape requests by status code, including rejected scrapes.",
+		}, []string{"code"}),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *HTTPMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requestsTotal.Describe(ch)
+	m.requestDuration.Describe(ch)
+	m.responseSize.Describe(ch)
+	m.requestsInFlight.Describe(ch)
+	m.scrapeRequestsTotal.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (m *HTTPMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.requestsTotal.Collect(ch)
+	m.requestDuration.Collect(ch)
+	m.responseSize.Collect(ch)
+	m.requestsInFlight.Collect(ch)
+	m.scrapeRequestsTotal.Collect(ch)
+}
+
+// Middleware wraps the handler and instruments each request by the route of the mux it matches.
+// It should wrap all other middlewares, so rejected requests are instrumented as well.
+func (m *HTTPMetrics) Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	// The instrumented handler of each route is cached, so the handler label is curried once per route.
+	var handlers sync.Map
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		route := routeLabel(pattern)
+
+		handler, ok := handlers.Load(route)
+		if !ok {
+			handler, _ = handlers.LoadOrStore(route, m.instrumentRoute(route, next))
+		}
+
+		handler.(http.Handler).ServeHTTP(w, r) //nolint:forcetypeassert
+	})
+}
+
+// instrumentRoute wraps the handler with the instrumentation of a route.
+func (m *HTTPMetrics) instrumentRoute(route string, next http.Handler) http.Handler {
+	labels := prometheus.Labels{"handler": route}
+
+	var handler http.Handler = promhttp.InstrumentHandlerCounter(m.requestsTotal.MustCurryWith(labels),
+		promhttp.InstrumentHandlerResponseSize(m.responseSize.MustCurryWith(labels), next),
+	)
+
+	if route == m.metricsPath {
+		handler = promhttp.InstrumentHandlerCounter(m.scrapeRequestsTotal, handler)
+	}
+
+	return promhttp.InstrumentHandlerInFlight(m.requestsInFlight,
+		promhttp.InstrumentHandlerDuration(m.requestDuration.MustCurryWith(labels), handler),
+	)
+}
+
+// routeLabel returns the path of a mux pattern, e.g. /textfile/{job} for "PUT /textfile/{job}".
+func routeLabel(pattern string) string {
+	if pattern == "" {
+		return unmatchedRoute
+	}
+
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+
+	return pattern
+}
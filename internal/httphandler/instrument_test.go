@@ -0,0 +1,148 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("timeout") == "invalid" {
+			http.Error(w, "invalid timeout", http.StatusBadRequest)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	mux.Handle("GET /health", NewHealthHandler())
+	mux.Handle("PUT /textfile/{job}", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// Rejecting middlewares are wrapped by the HTTP metrics.
+	rejecting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" && r.URL.Path == "/metrics" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+
+	metrics := NewHTTPMetrics("/metrics")
+	handler := metrics.Middleware(mux, rejecting)
+
+	for _, tc := range []struct {
+		method string
+		target string
+		auth   bool
+		code   int
+	}{
+		{http.MethodGet, "/metrics", true, http.StatusOK},
+		{http.MethodGet, "/metrics", true, http.StatusOK},
+		{http.MethodGet, "/metrics?timeout=invalid", true, http.StatusBadRequest},
+		{http.MethodGet, "/metrics", false, http.StatusUnauthorized},
+		{http.MethodGet, "/health", false, http.StatusOK},
+		{http.MethodPut, "/textfile/job_a", false, http.StatusNoContent},
+		{http.MethodPut, "/textfile/job_b", false, http.StatusNoContent},
+		{http.MethodGet, "/unknown", false, http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		if tc.auth {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, tc.code, rec.Code, tc.target)
+	}
+
+	expected := `
+# HELP windows_exporter_http_requests_in_flight windows_exporter: Number of HTTP requests currently served.
+# TYPE windows_exporter_http_requests_in_flight gauge
+windows_exporter_http_requests_in_flight 0
+# HELP windows_exporter_http_requests_total windows_exporter: Total number of HTTP requests by route, status code and method.
+# TYPE windows_exporter_http_requests_total counter
+windows_exporter_http_requests_total{code="200",handler="/health",method="get"} 1
+windows_exporter_http_requests_total{code="200",handler="/metrics",method="get"} 2
+windows_exporter_http_requests_total{code="204",handler="/textfile/{job}",method="put"} 2
+windows_exporter_http_requests_total{code="400",handler="/metrics",method="get"} 1
+windows_exporter_http_requests_total{code="401",handler="/metrics",method="get"} 1
+windows_exporter_http_requests_total{code="404",handler="unmatched",method="get"} 1
+# HELP windows_exporter_scrape_requests_total windows_exporter: Total number of scrape requests by status code, including rejected scrapes.
+# TYPE windows_exporter_scrape_requests_total counter
+windows_exporter_scrape_requests_total{code="200"} 2
+windows_exporter_scrape_requests_total{code="400"} 1
+windows_exporter_scrape_requests_total{code="401"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(expected),
+		"windows_exporter_http_requests_in_flight",
+		"windows_exporter_http_requests_total",
+		"windows_exporter_scrape_requests_total",
+	))
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(metrics)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	responseSizes := map[string]uint64{}
+	durations := map[string]uint64{}
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch family.GetName() {
+			case "windows_exporter_http_response_size_bytes":
+				responseSizes[metric.GetLabel()[0].GetValue()] = metric.GetHistogram().GetSampleCount()
+
+				if metric.GetLabel()[0].GetValue() == "/textfile/{job}" {
+					assert.Zero(t, metric.GetHistogram().GetSampleSum())
+				}
+			case "windows_exporter_http_request_duration_seconds":
+				durations[metric.GetLabel()[0].GetValue()] = metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+
+	expectedCounts := map[string]uint64{"/metrics": 4, "/health": 1, "/textfile/{job}": 2, "unmatched": 1}
+	assert.Equal(t, expectedCounts, responseSizes)
+	assert.Equal(t, expectedCounts, durations)
+}
+
+func TestRouteLabel(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "/metrics", routeLabel("GET /metrics"))
+	assert.Equal(t, "/textfile/{job}", routeLabel("PUT /textfile/{job}"))
+	assert.Equal(t, "/debug/pprof/", routeLabel("/debug/pprof/"))
+	assert.Equal(t, "unmatched", routeLabel(""))
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Interface guard.
+var _ prometheus.Collector = (*IPFilter)(nil)
+
+const (
+	rejectReasonDenied         = "denied"
+	rejectReasonNotAllowed     = "not_allowed"
+	rejectReasonInvalidAddress = "invalid_address"
+)
+
+type IPFilterOptions struct {
+	// Allow is the list of networks clients are allowed from. An empty list allows all clients, which are not denied.
+	Allow []netip.Prefix
+	// Deny is the list of networks clients are rejected from. Deny takes precedence over Allow.
+	Deny []netip.Prefix
+	// TrustedProxies is the list of networks of proxies, whose X-Forwarded-For header is trusted.
+	TrustedProxies []netip.Prefix
+}
+
+// IPFilter is a middleware, which rejects requests by the IP address of the client.
+type IPFilter struct {
+	logger  *slog.Logger
+	options IPFilterOptions
+
+	rejectedTotal *prometheus.CounterVec
+}
+
+// ParsePrefixes parses a comma-separated list of networks in CIDR notation. Single addresses are accepted as well.
+func ParsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", value, err)
+			}
+
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", value, err)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+func NewIPFilter(logger *slog.Logger, options IPFilterOptions) *IPFilter {
+	return &IPFilter{
+		logger:  logger.With(slog.String("component", "ip_filter")),
+		options: options,
+		rejectedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "http_rejected_requests_total",
+			Help:      "windows_exporter: Total number of HTTP requests rejected by the IP filter.",
+		}, []string{"reason"}),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (f *IPFilter) Describe(ch chan<- *prometheus.Desc) {
+	f.rejectedTotal.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface.
+func (f *IPFilter) Collect(ch chan<- prometheus.Metric) {
+	f.rejectedTotal.Collect(ch)
+}
+
+// Middleware wraps the handler and rejects requests of clients, which are denied or not allowed.
+func (f *IPFilter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, err := f.clientAddr(r)
+
+		var reason string
+
+		switch {
+		case err != nil:
+			reason = rejectReasonInvalidAddress
+		case containsAddr(f.options.Deny, client):
+			reason = rejectReasonDenied
+		case len(f.options.Allow) > 0 && !containsAddr(f.options.Allow, client):
+			reason = rejectReasonNotAllowed
+		default:
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		f.rejectedTotal.WithLabelValues(reason).Inc()
+
+		f.logger.Warn("rejected request",
+			slog.String("remote", r.RemoteAddr),
+			slog.String("client", client.String()),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("reason", reason),
+			slog.Any("err", err),
+		)
+
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+}
+
+// clientAddr returns the address of the client. If the request comes from a trusted proxy, the X-Forwarded-For
+// header is evaluated from right to left and the first address, which is not a trusted proxy, is the client.
+func (f *IPFilter) clientAddr(r *http.Request) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	client, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address %q: %w", r.RemoteAddr, err)
+	}
+
+	// Zones of link-local IPv6 addresses and IPv4-mapped IPv6 addresses should not affect the matching.
+	client = client.WithZone("").Unmap()
+
+	if !containsAddr(f.options.TrustedProxies, client) {
+		return client, nil
+	}
+
+	forwardedFor := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		value := strings.TrimSpace(forwardedFor[i])
+		if value == "" {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return client, fmt.Errorf("invalid X-Forwarded-For address %q: %w", value, err)
+		}
+
+		client = addr.WithZone("").Unmap()
+
+		if !containsAddr(f.options.TrustedProxies, client) {
+			break
+		}
+	}
+
+	return client, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
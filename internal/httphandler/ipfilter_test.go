@@ -0,0 +1,154 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIPFilter(t *testing.T, logger *slog.Logger, allow, deny, trustedProxies string) *IPFilter {
+	t.Helper()
+
+	var (
+		options IPFilterOptions
+		err     error
+	)
+
+	options.Allow, err = ParsePrefixes(allow)
+	require.NoError(t, err)
+
+	options.Deny, err = ParsePrefixes(deny)
+	require.NoError(t, err)
+
+	options.TrustedProxies, err = ParsePrefixes(trustedProxies)
+	require.NoError(t, err)
+
+	return NewIPFilter(logger, options)
+}
+
+func ipFilterRequest(handler http.Handler, remoteAddr string, forwardedFor ...string) int {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = remoteAddr
+
+	for _, value := range forwardedFor {
+		req.Header.Add("X-Forwarded-For", value)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+func TestIPFilter(t *testing.T) {
+	t.Parallel()
+
+	filter := newTestIPFilter(t, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		"10.0.0.0/8, 192.168.1.10, 2001:db8::/32, fe80::/10",
+		"10.1.0.0/16, 2001:db8:bad::/48",
+		"10.9.9.9, 2001:db8:9::1",
+	)
+
+	handler := filter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		code         int
+	}{
+		{"allowed IPv4", "10.2.3.4:1234", nil, http.StatusOK},
+		{"allowed single IPv4", "192.168.1.10:1234", nil, http.StatusOK},
+		{"not allowed IPv4", "192.168.1.11:1234", nil, http.StatusForbidden},
+		{"denied IPv4", "10.1.2.3:1234", nil, http.StatusForbidden},
+		{"IPv4-mapped IPv6", "[::ffff:10.2.3.4]:1234", nil, http.StatusOK},
+		{"denied IPv4-mapped IPv6", "[::ffff:10.1.2.3]:1234", nil, http.StatusForbidden},
+		{"allowed IPv6", "[2001:db8:1::1]:1234", nil, http.StatusOK},
+		{"link-local IPv6 with zone", "[fe80::1%eth0]:1234", nil, http.StatusOK},
+		{"not allowed IPv6", "[2001:db9::1]:1234", nil, http.StatusForbidden},
+		{"denied IPv6", "[2001:db8:bad::1]:1234", nil, http.StatusForbidden},
+		{"invalid remote address", "invalid", nil, http.StatusForbidden},
+		{"untrusted proxy", "10.2.3.4:1234", []string{"10.1.2.3"}, http.StatusOK},
+		{"trusted proxy", "10.9.9.9:1234", []string{"10.1.2.3"}, http.StatusForbidden},
+		{"trusted proxy allowed client", "10.9.9.9:1234", []string{"192.168.1.10"}, http.StatusOK},
+		{"trusted IPv6 proxy", "[2001:db8:9::1]:1234", []string{"2001:db8:bad::1"}, http.StatusForbidden},
+		{"spoofed header behind proxy", "10.9.9.9:1234", []string{"192.168.1.10, 10.1.2.3"}, http.StatusForbidden},
+		{"chain of trusted proxies", "10.9.9.9:1234", []string{"10.1.2.3", "2001:db8:9::1"}, http.StatusForbidden},
+		{"invalid header", "10.9.9.9:1234", []string{"unknown"}, http.StatusForbidden},
+		{"trusted proxy without header", "10.9.9.9:1234", nil, http.StatusOK},
+	} {
+		assert.Equal(t, tc.code, ipFilterRequest(handler, tc.remoteAddr, tc.forwardedFor...), tc.name)
+	}
+
+	expected := `
+# HELP windows_exporter_http_rejected_requests_total windows_exporter: Total number of HTTP requests rejected by the IP filter.
+# TYPE windows_exporter_http_rejected_requests_total counter
+windows_exporter_http_rejected_requests_total{reason="denied"} 7
+windows_exporter_http_rejected_requests_total{reason="invalid_address"} 2
+windows_exporter_http_rejected_requests_total{reason="not_allowed"} 2
+`
+	require.NoError(t, testutil.CollectAndCompare(filter, strings.NewReader(expected)))
+}
+
+func TestIPFilterDenyOnly(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+
+	filter := newTestIPFilter(t, slog.New(slog.NewTextHandler(&logs, nil)), "", "::1, 127.0.0.0/8", "")
+
+	handler := filter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	assert.Equal(t, http.StatusOK, ipFilterRequest(handler, "192.0.2.1:1234"))
+	assert.Equal(t, http.StatusForbidden, ipFilterRequest(handler, "127.0.0.1:1234"))
+	assert.Equal(t, http.StatusForbidden, ipFilterRequest(handler, "[::1]:1234"))
+
+	// Rejected requests are logged for auditing.
+	assert.Contains(t, logs.String(), `msg="rejected request" component=ip_filter remote=[::1]:1234 client=::1 method=GET path=/metrics reason=denied`)
+}
+
+func TestParsePrefixes(t *testing.T) {
+	t.Parallel()
+
+	prefixes, err := ParsePrefixes("10.1.2.3/8, 192.0.2.1,2001:db8::1, ::ffff:192.0.2.2,")
+	require.NoError(t, err)
+	require.Len(t, prefixes, 4)
+
+	assert.Equal(t, "10.0.0.0/8", prefixes[0].String())
+	assert.Equal(t, "192.0.2.1/32", prefixes[1].String())
+	assert.Equal(t, "2001:db8::1/128", prefixes[2].String())
+	assert.Equal(t, "192.0.2.2/32", prefixes[3].String())
+
+	_, err = ParsePrefixes("10.0.0.0/33")
+	require.ErrorContains(t, err, `invalid network "10.0.0.0/33"`)
+
+	_, err = ParsePrefixes("localhost")
+	require.ErrorContains(t, err, `invalid network "localhost"`)
+}
@@ -0,0 +1,385 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// PerfCounterOptions configures the PerfCounterHandler.
+type PerfCounterOptions struct {
+	// MaxConcurrent is the number of queries sampled at the same time. Further requests are rejected.
+	MaxConcurrent int
+	// CacheSize is the number of queries, whose PDH collectors are kept for subsequent requests.
+	CacheSize int
+	// CacheTTL is the duration an unused query is kept.
+	CacheTTL time.Duration
+	// Compressions are the content encodings offered for the response.
+	Compressions []promhttp.Compression
+}
+
+// PerfCounterHandler samples a performance counter on request and returns its values in the Prometheus text format,
+// e.g. /perfcounter?object=Processor%20Information&counter=%25%20Processor%20Time&instance=*.
+//
+// The PDH collectors of recently used queries are cached, so rate counters are sampled by the same query.
+type PerfCounterHandler struct {
+	logger    *slog.Logger
+	options   PerfCounterOptions
+	semaphore chan struct{}
+	desc      *prometheus.Desc
+
+	mu    sync.Mutex
+	cache map[string]*perfCounterQuery
+}
+
+// perfCounterQuery is a cached PDH collector of a query.
+type perfCounterQuery struct {
+	mu        sync.Mutex
+	collector *pdh.Collector
+	valueType reflect.Type
+	lastUsed  time.Time
+	// closed is set once the query was evicted and its collector closed.
+	closed bool
+}
+
+// Fields of the value type of the PDH collectors, see newPerfCounterValueType.
+const (
+	perfCounterValueField      = "Value"
+	perfCounterMetricTypeField = "MetricType"
+	perfCounterNameField       = "Name"
+)
+
+var errTooManyPerfCounterQueries = errors.New("too many concurrent queries")
+
+// NewPerfCounterHandler creates a new PerfCounterHandler. Options without value are set to defaults.
+func NewPerfCounterHandler(logger *slog.Logger, options PerfCounterOptions) *PerfCounterHandler {
+	if options.MaxConcurrent <= 0 {
+		options.MaxConcurrent = 1
+	}
+
+	if options.CacheSize < 0 {
+		options.CacheSize = 0
+	}
+
+	if options.Compressions == nil {
+		options.Compressions = DefaultCompressions
+	}
+
+	return &PerfCounterHandler{
+		logger:    logger.With(slog.String("component", "perfcounter")),
+		options:   options,
+		semaphore: make(chan struct{}, options.MaxConcurrent),
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "perfcounter", "value"),
+			"windows_exporter: Value of the performance counter queried by /perfcounter.",
+			[]string{"object", "counter", "instance"},
+			nil,
+		),
+		cache: make(map[string]*perfCounterQuery),
+	}
+}
+
+func (h *PerfCounterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	object := query.Get("object")
+	counter := query.Get("counter")
+	instances := slices.Sorted(slices.Values(query["instance"]))
+
+	if object == "" || counter == "" {
+		http.Error(w, "object and counter are required", http.StatusBadRequest)
+
+		return
+	}
+
+	logger := h.logger.With(
+		slog.Any("remote", r.RemoteAddr),
+		slog.String("object", object),
+		slog.String("counter", counter),
+	)
+
+	select {
+	case h.semaphore <- struct{}{}:
+		defer func() { <-h.semaphore }()
+	default:
+		http.Error(w, errTooManyPerfCounterQueries.Error(), http.StatusTooManyRequests)
+
+		return
+	}
+
+	metrics, err := h.sample(object, counter, instances)
+	if err != nil {
+		logger.Debug("couldn't sample performance counter",
+			slog.Any("err", err),
+		)
+
+		status := http.StatusInternalServerError
+		if errors.Is(err, pdh.NewPdhError(pdh.CstatusNoObject)) || errors.Is(err, pdh.NewPdhError(pdh.CstatusNoCounter)) ||
+			errors.Is(err, pdh.NewPdhError(pdh.CstatusNoInstance)) || errors.Is(err, pdh.ErrNoData) {
+			status = http.StatusNotFound
+		}
+
+		http.Error(w, err.Error(), status)
+
+		return
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(constMetrics(metrics))
+
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{
+		ErrorLog:            slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ErrorHandling:       promhttp.ContinueOnError,
+		OfferedCompressions: h.options.Compressions,
+	}).ServeHTTP(w, r)
+}
+
+// sample collects the values of the counter with the cached collector of the query or a new one.
+func (h *PerfCounterHandler) sample(object, counter string, instances []string) ([]prometheus.Metric, error) {
+	entry, cached, err := h.lockedQuery(object, counter, instances)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cached {
+		defer closeQueries([]*perfCounterQuery{entry})
+	}
+
+	defer entry.mu.Unlock()
+
+	values := reflect.New(reflect.SliceOf(entry.valueType))
+
+	if err := entry.collector.Collect(values.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to collect %s: %w", counter, err)
+	}
+
+	metrics := make([]prometheus.Metric, 0, values.Elem().Len())
+
+	for i := range values.Elem().Len() {
+		value := values.Elem().Index(i)
+
+		metricType, _ := value.FieldByName(perfCounterMetricTypeField).Interface().(prometheus.ValueType)
+		if metricType == 0 {
+			continue
+		}
+
+		instance := ""
+		if field := value.FieldByName(perfCounterNameField); field.IsValid() && field.String() != pdh.InstanceEmpty {
+			instance = field.String()
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			h.desc,
+			metricType,
+			value.FieldByName(perfCounterValueField).Float(),
+			object, counter, instance,
+		))
+	}
+
+	return metrics, nil
+}
+
+// lockedQuery returns the query like query, but locked. A cached query may be evicted by another request,
+// before it's locked. Evicted queries are closed, so the query is looked up again.
+func (h *PerfCounterHandler) lockedQuery(object, counter string, instances []string) (*perfCounterQuery, bool, error) {
+	for {
+		entry, cached, err := h.query(object, counter, instances)
+		if err != nil {
+			return nil, false, err
+		}
+
+		entry.mu.Lock()
+
+		if !entry.closed {
+			return entry, cached, nil
+		}
+
+		entry.mu.Unlock()
+	}
+}
+
+// query returns the cached query or builds a new PDH collector. Unused and least recently used queries are evicted.
+// Queries, which aren't cached, must be closed by the caller.
+func (h *PerfCounterHandler) query(object, counter string, instances []string) (*perfCounterQuery, bool, error) {
+	key := strings.Join(append([]string{object, counter}, instances...), "\x00")
+	now := time.Now()
+
+	h.mu.Lock()
+
+	entry, ok := h.cache[key]
+	if ok {
+		entry.lastUsed = now
+	}
+
+	evicted := h.evictLocked(now, key)
+
+	h.mu.Unlock()
+
+	closeQueries(evicted)
+
+	if ok {
+		return entry, true, nil
+	}
+
+	valueType := newPerfCounterValueType(counter, len(instances) > 0)
+
+	collector, err := pdh.NewCollectorWithReflection(object, instances, valueType)
+	if err != nil {
+		collector.Close()
+
+		return nil, false, err
+	}
+
+	entry = &perfCounterQuery{
+		collector: collector,
+		valueType: valueType,
+		lastUsed:  now,
+	}
+
+	if h.options.CacheSize == 0 {
+		return entry, false, nil
+	}
+
+	h.mu.Lock()
+
+	if cached, ok := h.cache[key]; ok {
+		// Another request built the same query in the meantime.
+		h.mu.Unlock()
+		collector.Close()
+
+		return cached, true, nil
+	}
+
+	h.cache[key] = entry
+	evicted = h.evictLocked(now, key)
+
+	h.mu.Unlock()
+
+	closeQueries(evicted)
+
+	return entry, true, nil
+}
+
+// evictLocked removes the queries, which weren't used within the TTL, and the least recently used ones
+// exceeding the cache size. The query of key is kept.
+func (h *PerfCounterHandler) evictLocked(now time.Time, key string) []*perfCounterQuery {
+	var evicted []*perfCounterQuery
+
+	for k, entry := range h.cache {
+		if k != key && h.options.CacheTTL > 0 && now.Sub(entry.lastUsed) > h.options.CacheTTL {
+			evicted = append(evicted, entry)
+			delete(h.cache, k)
+		}
+	}
+
+	for len(h.cache) > h.options.CacheSize {
+		var (
+			oldestKey string
+			oldest    *perfCounterQuery
+		)
+
+		for k, entry := range h.cache {
+			if k != key && (oldest == nil || entry.lastUsed.Before(oldest.lastUsed)) {
+				oldestKey, oldest = k, entry
+			}
+		}
+
+		if oldest == nil {
+			break
+		}
+
+		evicted = append(evicted, oldest)
+		delete(h.cache, oldestKey)
+	}
+
+	return evicted
+}
+
+// Close closes the PDH collectors of all cached queries.
+func (h *PerfCounterHandler) Close() {
+	h.mu.Lock()
+
+	evicted := make([]*perfCounterQuery, 0, len(h.cache))
+
+	for k, entry := range h.cache {
+		evicted = append(evicted, entry)
+		delete(h.cache, k)
+	}
+
+	h.mu.Unlock()
+
+	closeQueries(evicted)
+}
+
+// closeQueries closes the collectors once running collections are finished.
+func closeQueries(queries []*perfCounterQuery) {
+	for _, entry := range queries {
+		entry.mu.Lock()
+		entry.collector.Close()
+		entry.closed = true
+		entry.mu.Unlock()
+	}
+}
+
+// newPerfCounterValueType returns the struct type the values of the counter are collected into.
+func newPerfCounterValueType(counter string, withInstances bool) reflect.Type {
+	fields := []reflect.StructField{
+		{
+			Name: perfCounterValueField,
+			Type: reflect.TypeOf(float64(0)),
+			Tag:  reflect.StructTag(`perfdata:` + strconv.Quote(counter)),
+		},
+		{
+			Name: perfCounterMetricTypeField,
+			Type: reflect.TypeOf(prometheus.ValueType(0)),
+			Tag:  reflect.StructTag(`perfdata:` + strconv.Quote(counter+",metrictype")),
+		},
+	}
+
+	if withInstances {
+		fields = append(fields, reflect.StructField{
+			Name: perfCounterNameField,
+			Type: reflect.TypeOf(""),
+		})
+	}
+
+	return reflect.StructOf(fields)
+}
+
+// constMetrics is an unchecked collector, which sends the sampled metrics.
+type constMetrics []prometheus.Metric
+
+func (constMetrics) Describe(chan<- *prometheus.Desc) {}
+
+func (m constMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, metric := range m {
+		ch <- metric
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePDH is a PDH backend with fixed counter values, see pdh.SetSourceFactory.
+type fakePDH struct {
+	mu      sync.Mutex
+	sources []*fakePDHSource
+}
+
+type fakePDHSource struct {
+	samples []pdh.Sample
+	closed  bool
+}
+
+func (s *fakePDHSource) Describe() map[string]string { return map[string]string{} }
+
+func (s *fakePDHSource) Collect() ([]pdh.Sample, error) { return s.samples, nil }
+
+func (s *fakePDHSource) Close() { s.closed = true }
+
+func (f *fakePDH) newSource(object string, counters []string, instances []string) (pdh.Source, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var samples []pdh.Sample
+
+	switch {
+	case object == "Processor Information" && counters[0] == "% Processor Time":
+		samples = []pdh.Sample{
+			{Counter: counters[0], Instance: "0,0", Type: pdh.PERF_100NSEC_TIMER, FirstValue: 25_000_000},
+			{Counter: counters[0], Instance: "0,1", Type: pdh.PERF_100NSEC_TIMER, FirstValue: 50_000_000},
+		}
+	case object == "Memory" && counters[0] == "Available Bytes":
+		samples = []pdh.Sample{
+			{Counter: counters[0], Instance: instances[0], Type: pdh.PERF_COUNTER_LARGE_RAWCOUNT, FirstValue: 8589934592},
+		}
+	case object == "Memory":
+		return nil, pdh.NewPdhError(pdh.CstatusNoCounter)
+	default:
+		return nil, pdh.NewPdhError(pdh.CstatusNoObject)
+	}
+
+	source := &fakePDHSource{samples: samples}
+	f.sources = append(f.sources, source)
+
+	return source, nil
+}
+
+func (f *fakePDH) created() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.sources)
+}
+
+// withFakePDH replaces the PDH backend for the duration of the test.
+// Tests using it must not run in parallel, since the backend is global.
+func withFakePDH(t *testing.T) *fakePDH {
+	t.Helper()
+
+	backend := &fakePDH{}
+	previous := pdh.SetSourceFactory(backend.newSource)
+
+	t.Cleanup(func() {
+		pdh.SetSourceFactory(previous)
+	})
+
+	return backend
+}
+
+func newTestPerfCounterHandler(t *testing.T, options PerfCounterOptions) *PerfCounterHandler {
+	t.Helper()
+
+	options.Compressions = []promhttp.Compression{promhttp.Identity}
+
+	handler := NewPerfCounterHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), options)
+
+	t.Cleanup(handler.Close)
+
+	return handler
+}
+
+func getPerfCounter(handler http.Handler, query string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/perfcounter?"+query, nil))
+
+	return rw
+}
+
+func TestPerfCounterHandler(t *testing.T) {
+	backend := withFakePDH(t)
+	handler := newTestPerfCounterHandler(t, PerfCounterOptions{MaxConcurrent: 1, CacheSize: 4, CacheTTL: time.Minute})
+
+	rw := getPerfCounter(handler, "object=Processor+Information&counter=%25+Processor+Time&instance=*")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Equal(t, `# HELP windows_perfcounter_value windows_exporter: Value of the performance counter queried by /perfcounter.
+# TYPE windows_perfcounter_value counter
+windows_perfcounter_value{counter="% Processor Time",instance="0,0",object="Processor Information"} 2.5
+windows_perfcounter_value{counter="% Processor Time",instance="0,1",object="Processor Information"} 5
+`, rw.Body.String())
+
+	rw = getPerfCounter(handler, "object=Memory&counter=Available+Bytes")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Equal(t, `# HELP windows_perfcounter_value windows_exporter: Value of the performance counter queried by /perfcounter.
+# TYPE windows_perfcounter_value gauge
+windows_perfcounter_value{counter="Available Bytes",instance="",object="Memory"} 8.589934592e+09
+`, rw.Body.String())
+
+	// Subsequent requests use the cached queries.
+	rw = getPerfCounter(handler, "object=Processor+Information&counter=%25+Processor+Time&instance=*")
+	require.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+	assert.Equal(t, 2, backend.created())
+}
+
+func TestPerfCounterHandlerErrors(t *testing.T) {
+	withFakePDH(t)
+
+	handler := newTestPerfCounterHandler(t, PerfCounterOptions{MaxConcurrent: 1, CacheSize: 4})
+
+	for _, tc := range []struct {
+		query  string
+		status int
+	}{
+		{"counter=Available+Bytes", http.StatusBadRequest},
+		{"object=Memory", http.StatusBadRequest},
+		{"object=Processor&counter=%25+Processor+Time", http.StatusNotFound},
+		{"object=Memory&counter=Unknown", http.StatusNotFound},
+	} {
+		rw := getPerfCounter(handler, tc.query)
+		assert.Equal(t, tc.status, rw.Code, tc.query)
+	}
+
+	assert.Empty(t, handler.cache)
+
+	// Requests exceeding the concurrency limit are rejected.
+	handler.semaphore <- struct{}{}
+
+	rw := getPerfCounter(handler, "object=Memory&counter=Available+Bytes")
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+
+	<-handler.semaphore
+
+	rw = getPerfCounter(handler, "object=Memory&counter=Available+Bytes")
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
+
+func TestPerfCounterHandlerCache(t *testing.T) {
+	backend := withFakePDH(t)
+	handler := newTestPerfCounterHandler(t, PerfCounterOptions{MaxConcurrent: 1, CacheSize: 1, CacheTTL: time.Minute})
+
+	require.Equal(t, http.StatusOK, getPerfCounter(handler, "object=Memory&counter=Available+Bytes").Code)
+	require.Equal(t, http.StatusOK, getPerfCounter(handler, "object=Processor+Information&counter=%25+Processor+Time&instance=*").Code)
+
+	// The least recently used query exceeding the cache size is closed.
+	require.Equal(t, 2, backend.created())
+	assert.True(t, backend.sources[0].closed)
+	assert.False(t, backend.sources[1].closed)
+
+	// Queries unused for longer than the TTL are closed.
+	for _, entry := range handler.cache {
+		entry.lastUsed = time.Now().Add(-2 * time.Minute)
+	}
+
+	require.Equal(t, http.StatusOK, getPerfCounter(handler, "object=Memory&counter=Available+Bytes").Code)
+	require.Equal(t, 3, backend.created())
+	assert.True(t, backend.sources[1].closed)
+	assert.Len(t, handler.cache, 1)
+
+	handler.Close()
+
+	assert.True(t, backend.sources[2].closed)
+	assert.Empty(t, handler.cache)
+}
+
+func TestPerfCounterHandlerWithoutCache(t *testing.T) {
+	backend := withFakePDH(t)
+	handler := newTestPerfCounterHandler(t, PerfCounterOptions{MaxConcurrent: 1})
+
+	rw := getPerfCounter(handler, "object=Memory&counter=Available+Bytes")
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.True(t, strings.HasSuffix(rw.Body.String(), "8.589934592e+09\n"))
+
+	require.Equal(t, 1, backend.created())
+	assert.True(t, backend.sources[0].closed)
+	assert.Empty(t, handler.cache)
+}
+
+func TestPerfCounterHandlerConcurrentEviction(t *testing.T) {
+	withFakePDH(t)
+
+	handler := newTestPerfCounterHandler(t, PerfCounterOptions{MaxConcurrent: 8, CacheSize: 1, CacheTTL: time.Minute})
+	queries := []string{
+		"object=Memory&counter=Available+Bytes",
+		"object=Processor+Information&counter=%25+Processor+Time&instance=*",
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// Alternating queries evict each other, while other requests are about to use them.
+			for j := range 100 {
+				rw := getPerfCounter(handler, queries[(i+j)%len(queries)])
+				if rw.Code == http.StatusTooManyRequests {
+					continue
+				}
+
+				assert.Equal(t, http.StatusOK, rw.Code, rw.Body.String())
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,235 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus-community/windows_exporter/internal/probe"
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus-community/windows_exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const defaultProbeModule = "default"
+
+var errTooManyProbes = errors.New("too many concurrent probes")
+
+// ProbeHandler runs the collectors of a module against a remote target, in the style of the
+// blackbox_exporter, e.g. /probe?target=host&module=default.
+type ProbeHandler struct {
+	logger    *slog.Logger
+	config    *probe.Config
+	sessions  probe.SessionFactory
+	builders  map[string]probe.Builder
+	options   Options
+	semaphore chan struct{}
+}
+
+// NewProbeHandler creates a new ProbeHandler, which runs at most maxConcurrent probes at the same time.
+// Further requests are rejected. Only TimeoutMargin, MinScrapeTimeout, MaxScrapeTimeout
+// and Compressions of the options are used.
+func NewProbeHandler(logger *slog.Logger, config *probe.Config, sessions probe.SessionFactory, maxConcurrent int, options *Options) *ProbeHandler {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	return &ProbeHandler{
+		logger:    logger.With(slog.String("component", "probe")),
+		config:    config,
+		sessions:  sessions,
+		builders:  probe.Builders,
+		options:   *withDefaults(options),
+		semaphore: make(chan struct{}, maxConcurrent),
+	}
+}
+
+func (h *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case h.semaphore <- struct{}{}:
+		defer func() { <-h.semaphore }()
+	default:
+		http.Error(w, errTooManyProbes.Error(), http.StatusTooManyRequests)
+
+		return
+	}
+
+	query := r.URL.Query()
+
+	moduleName := query.Get("module")
+	if moduleName == "" {
+		moduleName = defaultProbeModule
+	}
+
+	module, err := h.config.Module(moduleName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	destination, err := module.ResolveTarget(query.Get("target"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	logger := h.logger.With(
+		slog.Any("remote", r.RemoteAddr),
+		slog.Any("correlation_id", uuid.New().String()),
+		slog.String("target", destination),
+		slog.String("module", moduleName),
+	)
+
+	scrapeTimeout, err := h.options.getScrapeTimeout(logger, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	startTime := time.Now()
+
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: types.Namespace,
+		Subsystem: "probe",
+		Name:      "success",
+		Help:      "windows_exporter: Whether the session to the target was established. See windows_exporter_collector_success for the state of the collectors.",
+	})
+	probeDuration := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: types.Namespace,
+		Subsystem: "probe",
+		Name:      "duration_seconds",
+		Help:      "windows_exporter: Duration of the probe including the connection to the target.",
+	}, func() float64 {
+		return time.Since(startTime).Seconds()
+	})
+
+	reg := prometheus.NewRegistry()
+
+	// The probe metrics are gathered after the collectors, so the duration covers the whole probe.
+	probeReg := prometheus.NewRegistry()
+	probeReg.MustRegister(probeSuccess, probeDuration)
+
+	session, err := h.sessions.NewSession(destination, module)
+	if err != nil {
+		logger.Warn("couldn't open session to target",
+			slog.Any("err", err),
+		)
+	} else {
+		probeSuccess.Set(1)
+
+		collection, collectors := h.buildCollection(logger, session.MISession(), module)
+
+		// Collectors may still run after a timeout, so they are closed in the background.
+		defer func() {
+			go closeProbe(logger, collection, collectors, session)
+		}()
+
+		collectionHandler, err := collection.NewHandler(scrapeTimeout, logger, nil)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("couldn't create collector handler: %s", err), http.StatusInternalServerError)
+
+			return
+		}
+
+		reg.MustRegister(collectionHandler)
+	}
+
+	promhttp.HandlerFor(
+		prometheus.Gatherers{reg, probeReg},
+		promhttp.HandlerOpts{
+			ErrorLog:            slog.NewLogLogger(logger.Handler(), slog.LevelError),
+			ErrorHandling:       promhttp.ContinueOnError,
+			OfferedCompressions: h.options.Compressions,
+			EnableOpenMetrics:   true,
+		},
+	).ServeHTTP(w, r)
+}
+
+// buildCollection builds the collectors of the module with the session of the target. Collectors, which fail to
+// build, report the error on collection, so the failure is visible in windows_exporter_collector_success.
+func (h *ProbeHandler) buildCollection(logger *slog.Logger, miSession *mi.Session, module *probe.Module) (*collector.Collection, collector.Map) {
+	collectors := make(collector.Map, len(module.Collectors))
+
+	for _, name := range module.Collectors {
+		builder, ok := h.builders[name]
+		if !ok {
+			collectors[name] = failedCollector{name: name, err: fmt.Errorf("collector %s does not support remote targets", name)}
+
+			continue
+		}
+
+		c := builder()
+
+		if err := c.Build(logger, miSession); err != nil {
+			logger.Warn("couldn't build collector",
+				slog.String("collector", name),
+				slog.Any("err", err),
+			)
+
+			_ = c.Close()
+			collectors[name] = failedCollector{name: name, err: fmt.Errorf("couldn't build collector: %w", err)}
+
+			continue
+		}
+
+		collectors[name] = c
+	}
+
+	return collector.New(collectors), collectors
+}
+
+func closeProbe(logger *slog.Logger, collection *collector.Collection, collectors collector.Map, session probe.Session) {
+	collection.WaitInflight()
+
+	for name, c := range collectors {
+		if err := c.Close(); err != nil {
+			logger.Debug("couldn't close collector",
+				slog.String("collector", name),
+				slog.Any("err", err),
+			)
+		}
+	}
+
+	if err := session.Close(); err != nil {
+		logger.Debug("couldn't close session",
+			slog.Any("err", err),
+		)
+	}
+}
+
+// failedCollector reports the error of a collector, which could not be built for the target.
+type failedCollector struct {
+	name string
+	err  error
+}
+
+func (c failedCollector) GetName() string { return c.name }
+
+func (c failedCollector) Build(*slog.Logger, *mi.Session) error { return c.err }
+
+func (c failedCollector) Collect(chan<- prometheus.Metric) error { return c.err }
+
+func (c failedCollector) Close() error { return nil }
@@ -0,0 +1,236 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus-community/windows_exporter/internal/probe"
+	"github.com/prometheus-community/windows_exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSession is a session, which does not connect to any target.
+type fakeSession struct {
+	session *mi.Session
+	closed  atomic.Bool
+}
+
+func (s *fakeSession) MISession() *mi.Session { return s.session }
+
+func (s *fakeSession) Close() error {
+	s.closed.Store(true)
+
+	return nil
+}
+
+// fakeSessionFactory records the requested destinations and fails for unreachable ones.
+type fakeSessionFactory struct {
+	mu           sync.Mutex
+	destinations []string
+	sessions     []*fakeSession
+	// release unblocks sessions to the target "slow".
+	release chan struct{}
+}
+
+func (f *fakeSessionFactory) NewSession(destination string, module *probe.Module) (probe.Session, error) {
+	f.mu.Lock()
+	f.destinations = append(f.destinations, destination+" "+module.Protocol)
+	f.mu.Unlock()
+
+	if destination == "slow" {
+		<-f.release
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if destination == "unreachable" {
+		return nil, errors.New("the WinRM client cannot process the request")
+	}
+
+	session := &fakeSession{session: &mi.Session{}}
+	f.sessions = append(f.sessions, session)
+
+	return session, nil
+}
+
+// fakeRemoteCollector exposes the session it was built with.
+type fakeRemoteCollector struct {
+	name     string
+	buildErr error
+	session  *mi.Session
+	desc     *prometheus.Desc
+}
+
+func (c *fakeRemoteCollector) GetName() string { return c.name }
+
+func (c *fakeRemoteCollector) Build(_ *slog.Logger, miSession *mi.Session) error {
+	if c.buildErr != nil {
+		return c.buildErr
+	}
+
+	c.session = miSession
+	c.desc = prometheus.NewDesc("windows_"+c.name+"_info", "Fake remote collector.", nil, nil)
+
+	return nil
+}
+
+func (c *fakeRemoteCollector) Collect(ch chan<- prometheus.Metric) error {
+	if c.session == nil {
+		return errors.New("collector not built")
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1)
+
+	return nil
+}
+
+func (c *fakeRemoteCollector) Close() error { return nil }
+
+func newTestProbeHandler(sessions probe.SessionFactory) *ProbeHandler {
+	handler := NewProbeHandler(slog.New(slog.NewTextHandler(io.Discard, nil)), &probe.Config{
+		Modules: map[string]*probe.Module{
+			"default": {Protocol: "winrm", Collectors: []string{"remote_a", "remote_b"}, AllowedTargets: []string{"*"}},
+			"broken":  {Protocol: "dcom", Collectors: []string{"remote_a", "remote_broken", "cpu"}, AllowedTargets: []string{"*"}},
+		},
+	}, sessions, 1, nil)
+
+	handler.builders = map[string]probe.Builder{
+		"remote_a": func() collector.Collector { return &fakeRemoteCollector{name: "remote_a"} },
+		"remote_b": func() collector.Collector { return &fakeRemoteCollector{name: "remote_b"} },
+		"remote_broken": func() collector.Collector {
+			return &fakeRemoteCollector{name: "remote_broken", buildErr: mi.MI_RESULT_INVALID_NAMESPACE}
+		},
+	}
+
+	return handler
+}
+
+func probeRequest(handler http.Handler, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?"+query, nil))
+
+	return rec
+}
+
+func TestProbeHandler(t *testing.T) {
+	t.Parallel()
+
+	sessions := &fakeSessionFactory{}
+	handler := newTestProbeHandler(sessions)
+
+	rec := probeRequest(handler, "target=SRV01.corp.example.com")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "windows_remote_a_info 1")
+	assert.Contains(t, body, "windows_remote_b_info 1")
+	assert.Contains(t, body, `windows_exporter_collector_success{collector="remote_a"} 1`)
+	assert.Contains(t, body, "windows_probe_success 1")
+	assert.Contains(t, body, "windows_probe_duration_seconds")
+
+	// The module defaults to "default" and the target is normalized.
+	assert.Equal(t, []string{"srv01.corp.example.com winrm"}, sessions.destinations)
+
+	// The session is closed once the probe finished.
+	require.Eventually(t, func() bool {
+		return sessions.sessions[0].closed.Load()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestProbeHandlerFailures(t *testing.T) {
+	t.Parallel()
+
+	sessions := &fakeSessionFactory{}
+	handler := newTestProbeHandler(sessions)
+
+	// Unreachable targets are reported via windows_probe_success.
+	rec := probeRequest(handler, "target=unreachable")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "windows_probe_success 0")
+	assert.NotContains(t, rec.Body.String(), "windows_exporter_collector_success{")
+
+	// Collectors, which fail to build or do not support remote targets, are reported as failed.
+	rec = probeRequest(handler, "target=srv01&module=broken")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "windows_probe_success 1")
+	assert.Contains(t, body, `windows_exporter_collector_success{collector="remote_a"} 1`)
+	assert.Contains(t, body, `windows_exporter_collector_success{collector="remote_broken"} 0`)
+	assert.Contains(t, body, `windows_exporter_collector_success{collector="cpu"} 0`)
+	assert.Equal(t, []string{"unreachable winrm", "srv01 dcom"}, sessions.destinations)
+
+	for _, tc := range []struct {
+		query string
+		err   string
+	}{
+		{"target=srv01&module=unknown", `unknown module "unknown"`},
+		{"module=default", "target parameter is missing"},
+		{"target=srv01:5985", "must be a host name or an IP address"},
+		{"target=srv01&timeout=-1", "invalid timeout query parameter"},
+	} {
+		rec = probeRequest(handler, tc.query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tc.query)
+		assert.Contains(t, rec.Body.String(), tc.err, tc.query)
+	}
+
+	// Invalid requests never open a session.
+	assert.Len(t, sessions.destinations, 2)
+}
+
+func TestProbeHandlerMaxConcurrent(t *testing.T) {
+	t.Parallel()
+
+	sessions := &fakeSessionFactory{release: make(chan struct{})}
+	handler := newTestProbeHandler(sessions)
+
+	done := make(chan *httptest.ResponseRecorder)
+
+	go func() {
+		done <- probeRequest(handler, "target=slow")
+	}()
+
+	require.Eventually(t, func() bool {
+		sessions.mu.Lock()
+		defer sessions.mu.Unlock()
+
+		return len(sessions.destinations) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The handler allows a single probe, so further probes are rejected while the first one connects.
+	rec := probeRequest(handler, "target=srv01")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Contains(t, rec.Body.String(), errTooManyProbes.Error())
+
+	close(sessions.release)
+	require.Equal(t, http.StatusOK, (<-done).Code)
+
+	rec = probeRequest(handler, "target=srv01")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
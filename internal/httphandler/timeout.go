@@ -0,0 +1,99 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultScrapeTimeout = 10 * time.Second
+	// DefaultMinScrapeTimeout is the default lower bound of the scrape timeout.
+	DefaultMinScrapeTimeout = 500 * time.Millisecond
+	// DefaultMaxScrapeTimeout is the default upper bound of the scrape timeout.
+	DefaultMaxScrapeTimeout = 2 * time.Minute
+
+	scrapeTimeoutHeader     = "X-Prometheus-Scrape-Timeout-Seconds"
+	scrapeTimeoutQueryParam = "timeout"
+)
+
+var errInvalidTimeout = errors.New("timeout must be a positive number of seconds or a duration")
+
+// parseTimeout parses a timeout given in seconds, e.g. "9.5", or as duration, e.g. "9500ms".
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 || math.IsNaN(seconds) || seconds > math.MaxInt64/float64(time.Second) {
+			return 0, errInvalidTimeout
+		}
+
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, errInvalidTimeout
+	}
+
+	return timeout, nil
+}
+
+// getScrapeTimeout returns the timeout of a scrape request. The timeout allowed by the client is taken from the
+// timeout query parameter or the X-Prometheus-Scrape-Timeout-Seconds header, in this order. The TimeoutMargin is
+// subtracted from it, but the result is never lower than MinScrapeTimeout. Clients can't extend the timeout beyond
+// MaxScrapeTimeout, which bounds the time collectors run for a single scrape.
+//
+// An invalid query parameter results in an error. An invalid header is logged and the default timeout is used instead.
+func (o *Options) getScrapeTimeout(logger *slog.Logger, r *http.Request) (time.Duration, error) {
+	var timeout time.Duration
+
+	if v := r.URL.Query().Get(scrapeTimeoutQueryParam); v != "" {
+		var err error
+
+		timeout, err = parseTimeout(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s query parameter %q: %w", scrapeTimeoutQueryParam, v, err)
+		}
+	} else if v := r.Header.Get(scrapeTimeoutHeader); v != "" {
+		var err error
+
+		timeout, err = parseTimeout(v)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Couldn't parse %s: %q. Defaulting timeout to %s", scrapeTimeoutHeader, v, defaultScrapeTimeout),
+				slog.Any("err", err),
+			)
+		}
+	}
+
+	if timeout == 0 {
+		timeout = defaultScrapeTimeout
+	}
+
+	margin := time.Duration(o.TimeoutMargin * float64(time.Second))
+
+	timeout -= margin
+
+	if o.MaxScrapeTimeout > 0 {
+		timeout = min(timeout, o.MaxScrapeTimeout)
+	}
+
+	return max(timeout, o.MinScrapeTimeout), nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package httphandler
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeout(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		value    string
+		expected time.Duration
+		err      bool
+	}{
+		{"10", 10 * time.Second, false},
+		{"9.5", 9500 * time.Millisecond, false},
+		{"0.8", 800 * time.Millisecond, false},
+		{"1e1", 10 * time.Second, false},
+		{"9500ms", 9500 * time.Millisecond, false},
+		{"1m", time.Minute, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"-1s", 0, true},
+		{"NaN", 0, true},
+		{"1e300", 0, true},
+		{"ten", 0, true},
+	} {
+		timeout, err := parseTimeout(tc.value)
+		if tc.err {
+			require.ErrorIs(t, err, errInvalidTimeout, tc.value)
+
+			continue
+		}
+
+		require.NoError(t, err, tc.value)
+		assert.Equal(t, tc.expected, timeout, tc.value)
+	}
+}
+
+func TestGetScrapeTimeout(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		header   string
+		query    string
+		margin   float64
+		minimum  time.Duration
+		maximum  time.Duration
+		expected time.Duration
+		err      string
+	}{
+		{name: "default", margin: 0.5, expected: 9500 * time.Millisecond},
+		{name: "fractional header", header: "9.5", margin: 0.5, expected: 9 * time.Second},
+		{name: "fractional margin", header: "10", margin: 0.25, expected: 9750 * time.Millisecond},
+		{name: "sub-second header", header: "0.8", expected: 800 * time.Millisecond},
+		{name: "floor", header: "0.8", margin: 0.5, expected: DefaultMinScrapeTimeout},
+		{name: "custom floor", header: "1", margin: 0.5, minimum: 2 * time.Second, expected: 2 * time.Second},
+		{name: "invalid header", header: "ten", margin: 0.5, expected: 9500 * time.Millisecond},
+		{name: "query parameter", header: "10", query: "timeout=3.5", margin: 0.5, expected: 3 * time.Second},
+		{name: "query parameter duration", query: "timeout=2500ms", margin: 0.5, expected: 2 * time.Second},
+		{name: "invalid query parameter", header: "10", query: "timeout=-1", err: `invalid timeout query parameter "-1"`},
+		{name: "ceiling", header: "3600", margin: 0.5, maximum: 30 * time.Second, expected: 30 * time.Second},
+		{name: "query parameter ceiling", query: "timeout=24h", margin: 0.5, maximum: 30 * time.Second, expected: 30 * time.Second},
+		{name: "below ceiling", header: "20", margin: 0.5, maximum: 30 * time.Second, expected: 19500 * time.Millisecond},
+		{name: "floor wins over ceiling", header: "10", minimum: 2 * time.Second, maximum: time.Second, expected: 2 * time.Second},
+	} {
+		handler := &MetricsHTTPHandler{options: Options{TimeoutMargin: tc.margin, MinScrapeTimeout: tc.minimum, MaxScrapeTimeout: tc.maximum}}
+		if tc.minimum == 0 {
+			handler.options.MinScrapeTimeout = DefaultMinScrapeTimeout
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics?"+tc.query, nil)
+		if tc.header != "" {
+			req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tc.header)
+		}
+
+		timeout, err := handler.options.getScrapeTimeout(slog.New(slog.NewTextHandler(io.Discard, nil)), req)
+		if tc.err != "" {
+			require.ErrorContains(t, err, tc.err, tc.name)
+
+			continue
+		}
+
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, timeout, tc.name)
+	}
+}
+
+func TestGetScrapeTimeoutDefaultCeiling(t *testing.T) {
+	t.Parallel()
+
+	options := withDefaults(&Options{TimeoutMargin: 0.5})
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics?timeout=1000000", nil)
+
+	timeout, err := options.getScrapeTimeout(slog.New(slog.NewTextHandler(io.Discard, nil)), req)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMaxScrapeTimeout, timeout)
+}
@@ -48,7 +48,7 @@ func (h VersionHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 	// can be serialized.
 	err := json.NewEncoder(w).Encode(prometheusVersion{
 		Version:   version.Version,
-		Revision:  version.Revision,
+		Revision:  version.GetRevision(),
 		Branch:    version.Branch,
 		BuildUser: version.BuildUser,
 		BuildDate: version.BuildDate,
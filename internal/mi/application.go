@@ -31,6 +31,33 @@ const (
 	LocaleEnglish = "en-us"
 )
 
+// Protocol handlers of a session.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mi/nf-mi-mi_application_newsession
+const (
+	ProtocolWinRM = "WINRM"
+	ProtocolDCOM  = "WMIDCOM"
+)
+
+// Authentication types of [UserCredentials].
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mi/ns-mi-mi_usercredentials
+const (
+	AuthTypeDefault      = "Default"
+	AuthTypeNegoWithCred = "NegoWithCreds"
+	AuthTypeKerberos     = "Kerberos"
+	AuthTypeNTLM         = "NtlmDomain"
+	AuthTypeBasic        = "Basic"
+	AuthTypeDigest       = "Digest"
+	AuthTypeCredSSP      = "CredSSP"
+)
+
+// Transports of a WinRM session.
+const (
+	TransportHTTP  = "HTTP"
+	TransportHTTPS = "HTTPS"
+)
+
 //nolint:gochecknoglobals
 var (
 	// DestinationOptionsTimeout is the key for the timeout option.
@@ -42,6 +69,15 @@ var (
 	//
 	// https://github.com/microsoft/win32metadata/blob/527806d20d83d3abd43d16cd3fa8795d8deba343/generation/WinSDK/RecompiledIdlHeaders/um/mi.h#L8248
 	DestinationOptionsUILocale = UTF16PtrFromString[*uint16]("__MI_DESTINATIONOPTIONS_UI_LOCALE")
+
+	// DestinationOptionsCredentials is the key for the credentials of the destination.
+	DestinationOptionsCredentials = UTF16PtrFromString[*uint16]("__MI_DESTINATIONOPTIONS_DESTINATION_CREDENTIALS")
+
+	// DestinationOptionsTransport is the key for the transport of a WinRM session.
+	DestinationOptionsTransport = UTF16PtrFromString[*uint16]("__MI_DESTINATIONOPTIONS_TRANSPORT")
+
+	// DestinationOptionsPort is the key for the port of a WinRM session.
+	DestinationOptionsPort = UTF16PtrFromString[*uint16]("__MI_DESTINATIONOPTIONS_DESTINATION_PORT")
 )
 
 //nolint:gochecknoglobals
@@ -81,6 +117,17 @@ type DestinationOptions struct {
 	ft        *DestinationOptionsFT
 }
 
+// UserCredentials represents the credentials used to authenticate at the destination.
+// The union of the C structure is always represented by the username and password variant.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mi/ns-mi-mi_usercredentials
+type UserCredentials struct {
+	AuthenticationType *uint16
+	Domain             *uint16
+	Username           *uint16
+	Password           *uint16
+}
+
 type DestinationOptionsFT struct {
 	Delete                   uintptr
 	SetString                uintptr
@@ -146,10 +193,32 @@ func (application *Application) Close() error {
 	return nil
 }
 
-// NewSession creates a session used to share connections for a set of operations to a single destination.
+// NewSession creates a session used to share connections for a set of operations to the local machine.
 //
 // https://learn.microsoft.com/en-us/windows/win32/api/mi/nf-mi-mi_application_newsession
 func (application *Application) NewSession(options *DestinationOptions) (*Session, error) {
+	return application.newSession(nil, nil, options)
+}
+
+// NewRemoteSession creates a session used to share connections for a set of operations to a remote destination.
+// The protocol is either [ProtocolWinRM] or [ProtocolDCOM].
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mi/nf-mi-mi_application_newsession
+func (application *Application) NewRemoteSession(protocol, destination string, options *DestinationOptions) (*Session, error) {
+	protocolUTF16, err := windows.UTF16PtrFromString(protocol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert protocol: %w", err)
+	}
+
+	destinationUTF16, err := windows.UTF16PtrFromString(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert destination: %w", err)
+	}
+
+	return application.newSession(protocolUTF16, destinationUTF16, options)
+}
+
+func (application *Application) newSession(protocol, destination *uint16, options *DestinationOptions) (*Session, error) {
 	if application == nil || application.ft == nil {
 		return nil, ErrNotInitialized
 	}
@@ -159,8 +228,8 @@ func (application *Application) NewSession(options *DestinationOptions) (*Sessio
 	r0, _, _ := syscall.SyscallN(
 		application.ft.NewSession,
 		uintptr(unsafe.Pointer(application)),
-		0,
-		0,
+		uintptr(unsafe.Pointer(protocol)),
+		uintptr(unsafe.Pointer(destination)),
 		uintptr(unsafe.Pointer(options)),
 		0,
 		0,
@@ -284,6 +353,103 @@ func (do *DestinationOptions) SetLocale(locale string) error {
 	return nil
 }
 
+// SetTransport sets the transport of a WinRM session, either [TransportHTTP] or [TransportHTTPS].
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mi/nf-mi-mi_destinationoptions_settransport
+func (do *DestinationOptions) SetTransport(transport string) error {
+	if do == nil || do.ft == nil {
+		return ErrNotInitialized
+	}
+
+	transportUTF16, err := windows.UTF16PtrFromString(transport)
+	if err != nil {
+		return fmt.Errorf("failed to convert transport: %w", err)
+	}
+
+	r0, _, _ := syscall.SyscallN(
+		do.ft.SetString,
+		uintptr(unsafe.Pointer(do)),
+		uintptr(unsafe.Pointer(DestinationOptionsTransport)),
+		uintptr(unsafe.Pointer(transportUTF16)),
+		0,
+	)
+
+	if result := ResultError(r0); !errors.Is(result, MI_RESULT_OK) {
+		return result
+	}
+
+	return nil
+}
+
+// SetPort sets the port of a WinRM session.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mi/nf-mi-mi_destinationoptions_setdestinationport
+func (do *DestinationOptions) SetPort(port uint32) error {
+	if do == nil || do.ft == nil {
+		return ErrNotInitialized
+	}
+
+	r0, _, _ := syscall.SyscallN(
+		do.ft.SetNumber,
+		uintptr(unsafe.Pointer(do)),
+		uintptr(unsafe.Pointer(DestinationOptionsPort)),
+		uintptr(port),
+		0,
+	)
+
+	if result := ResultError(r0); !errors.Is(result, MI_RESULT_OK) {
+		return result
+	}
+
+	return nil
+}
+
+// AddCredentials adds the credentials used to authenticate at the destination.
+// The authentication type is one of the AuthType constants, e.g. [AuthTypeNegoWithCred].
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mi/nf-mi-mi_destinationoptions_adddestinationcredentials
+func (do *DestinationOptions) AddCredentials(authenticationType, domain, username, password string) error {
+	if do == nil || do.ft == nil {
+		return ErrNotInitialized
+	}
+
+	credentials := UserCredentials{}
+
+	var err error
+
+	for _, field := range []struct {
+		dst   **uint16
+		value string
+	}{
+		{&credentials.AuthenticationType, authenticationType},
+		{&credentials.Domain, domain},
+		{&credentials.Username, username},
+		{&credentials.Password, password},
+	} {
+		if field.value == "" {
+			continue
+		}
+
+		if *field.dst, err = windows.UTF16PtrFromString(field.value); err != nil {
+			return fmt.Errorf("failed to convert credentials: %w", err)
+		}
+	}
+
+	r0, _, _ := syscall.SyscallN(
+		do.ft.AddCredentials,
+		uintptr(unsafe.Pointer(do)),
+		uintptr(unsafe.Pointer(DestinationOptionsCredentials)),
+		uintptr(unsafe.Pointer(&credentials)),
+		0,
+	)
+
+	if result := ResultError(r0); !errors.Is(result, MI_RESULT_OK) {
+		return result
+	}
+
+	return nil
+}
+
 func (do *DestinationOptions) Delete() error {
 	r0, _, _ := syscall.SyscallN(
 		do.ft.Delete,
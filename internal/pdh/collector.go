@@ -11,8 +11,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build windows
-
 package pdh
 
 import (
@@ -22,11 +20,14 @@ import (
 	"slices"
 	"strings"
 	"sync"
-	"unsafe"
 
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/sys/windows"
+)
+
+const (
+	InstanceEmpty = "------"
+	InstanceTotal = "_Total"
 )
 
 //nolint:gochecknoglobals
@@ -37,10 +38,16 @@ var (
 
 type CounterValues = map[string]map[string]CounterValue
 
+type CounterValue struct {
+	Type        prometheus.ValueType
+	FirstValue  float64
+	SecondValue float64
+}
+
 type Collector struct {
 	object                string
 	counters              map[string]Counter
-	handle                pdhQueryHandle
+	source                Source
 	totalCounterRequested bool
 	mu                    sync.RWMutex
 
@@ -52,15 +59,11 @@ type Collector struct {
 }
 
 type Counter struct {
-	Name       string
-	Desc       string
-	MetricType prometheus.ValueType
-	Instances  map[string]pdhCounterHandle
-	Type       uint32
-	Frequency  int64
+	Name string
 
 	FieldIndexValue       int
 	FieldIndexSecondValue int
+	FieldIndexMetricType  int
 }
 
 func NewCollector[T any](object string, instances []string) (*Collector, error) {
@@ -70,12 +73,6 @@ func NewCollector[T any](object string, instances []string) (*Collector, error)
 }
 
 func NewCollectorWithReflection(object string, instances []string, valueType reflect.Type) (*Collector, error) {
-	var handle pdhQueryHandle
-
-	if ret := OpenQuery(0, 0, &handle); ret != ErrorSuccess {
-		return nil, NewPdhError(ret)
-	}
-
 	if len(instances) == 0 {
 		instances = []string{InstanceEmpty}
 	}
@@ -83,7 +80,6 @@ func NewCollectorWithReflection(object string, instances []string, valueType ref
 	collector := &Collector{
 		object:                object,
 		counters:              make(map[string]Counter, valueType.NumField()),
-		handle:                handle,
 		totalCounterRequested: slices.Contains(instances, InstanceTotal),
 		mu:                    sync.RWMutex{},
 		nameIndexValue:        -1,
@@ -91,6 +87,7 @@ func NewCollectorWithReflection(object string, instances []string, valueType ref
 	}
 
 	errs := make([]error, 0, valueType.NumField())
+	counterNames := make([]string, 0, valueType.NumField())
 
 	if f, ok := valueType.FieldByName("Name"); ok {
 		if f.Type.Kind() == reflect.String {
@@ -110,7 +107,18 @@ func NewCollectorWithReflection(object string, instances []string, valueType ref
 			continue
 		}
 
-		if f.Type.Kind() != reflect.Float64 {
+		isSecondValue := strings.HasSuffix(counterName, ",secondvalue")
+		counterName = strings.TrimSuffix(counterName, ",secondvalue")
+
+		isMetricType := strings.HasSuffix(counterName, ",metrictype")
+		counterName = strings.TrimSuffix(counterName, ",metrictype")
+
+		switch {
+		case isMetricType && f.Type != reflect.TypeOf(prometheus.ValueType(0)):
+			errs = append(errs, fmt.Errorf("field %s must be a prometheus.ValueType", f.Name))
+
+			continue
+		case !isMetricType && f.Type.Kind() != reflect.Float64:
 			errs = append(errs, fmt.Errorf("field %s must be a float64", f.Name))
 
 			continue
@@ -120,82 +128,35 @@ func NewCollectorWithReflection(object string, instances []string, valueType ref
 		if counter, ok = collector.counters[counterName]; !ok {
 			counter = Counter{
 				Name:                  counterName,
-				Instances:             make(map[string]pdhCounterHandle, len(instances)),
 				FieldIndexSecondValue: -1,
 				FieldIndexValue:       -1,
+				FieldIndexMetricType:  -1,
 			}
-		}
 
-		if strings.HasSuffix(counterName, ",secondvalue") {
-			counterName = strings.TrimSuffix(counterName, ",secondvalue")
+			counterNames = append(counterNames, counterName)
+		}
 
+		switch {
+		case isSecondValue:
 			counter.FieldIndexSecondValue = f.Index[0]
-		} else {
+		case isMetricType:
+			counter.FieldIndexMetricType = f.Index[0]
+		default:
 			counter.FieldIndexValue = f.Index[0]
 		}
 
-		if len(counter.Instances) != 0 {
-			collector.counters[counterName] = counter
-
-			continue
-		}
-
-		var counterPath string
-
-		for _, instance := range instances {
-			counterPath = formatCounterPath(object, instance, counterName)
-
-			var counterHandle pdhCounterHandle
-
-			if ret := AddEnglishCounter(handle, counterPath, 0, &counterHandle); ret != ErrorSuccess {
-				errs = append(errs, fmt.Errorf("failed to add counter %s: %w", counterPath, NewPdhError(ret)))
-
-				continue
-			}
-
-			counter.Instances[instance] = counterHandle
-
-			if counter.Type != 0 {
-				continue
-			}
-
-			// Get the info with the current buffer size
-			bufLen := uint32(0)
-
-			if ret := GetCounterInfo(counterHandle, 0, &bufLen, nil); ret != MoreData {
-				errs = append(errs, fmt.Errorf("GetCounterInfo: %w", NewPdhError(ret)))
-
-				continue
-			}
-
-			buf := make([]byte, bufLen)
-			if ret := GetCounterInfo(counterHandle, 0, &bufLen, &buf[0]); ret != ErrorSuccess {
-				errs = append(errs, fmt.Errorf("GetCounterInfo: %w", NewPdhError(ret)))
-
-				continue
-			}
-
-			ci := (*CounterInfo)(unsafe.Pointer(&buf[0]))
-			counter.Type = ci.DwType
-			counter.Desc = windows.UTF16PtrToString(ci.SzExplainText)
-			counter.Desc = windows.UTF16PtrToString(ci.SzExplainText)
-
-			if val, ok := SupportedCounterTypes[counter.Type]; ok {
-				counter.MetricType = val
-			} else {
-				counter.MetricType = prometheus.GaugeValue
-			}
+		collector.counters[counterName] = counter
+	}
 
-			if counter.Type == PERF_ELAPSED_TIME {
-				if ret := GetCounterTimeBase(counterHandle, &counter.Frequency); ret != ErrorSuccess {
-					errs = append(errs, fmt.Errorf("GetCounterTimeBase: %w", NewPdhError(ret)))
+	source, err := newSource(object, counterNames, instances)
+	if source == nil {
+		return nil, err
+	}
 
-					continue
-				}
-			}
-		}
+	collector.source = source
 
-		collector.counters[counterName] = counter
+	if err != nil {
+		errs = append(errs, err)
 	}
 
 	if err := errors.Join(errs...); err != nil {
@@ -203,6 +164,8 @@ func NewCollectorWithReflection(object string, instances []string, valueType ref
 	}
 
 	if len(collector.counters) == 0 {
+		source.Close()
+
 		return nil, errors.New("no counters configured")
 	}
 
@@ -228,13 +191,11 @@ func (c *Collector) Describe() map[string]string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	desc := make(map[string]string, len(c.counters))
-
-	for _, counter := range c.counters {
-		desc[counter.Name] = counter.Desc
+	if c.source == nil {
+		return map[string]string{}
 	}
 
-	return desc
+	return c.source.Describe()
 }
 
 func (c *Collector) Collect(dst any) error {
@@ -245,7 +206,7 @@ func (c *Collector) Collect(dst any) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if len(c.counters) == 0 || c.handle == 0 || c.collectCh == nil || c.errorCh == nil {
+	if len(c.counters) == 0 || c.source == nil || c.collectCh == nil || c.errorCh == nil {
 		return ErrPerformanceCounterNotInitialized
 	}
 
@@ -255,19 +216,17 @@ func (c *Collector) Collect(dst any) error {
 }
 
 func (c *Collector) collectRoutine() {
-	var (
-		err         error
-		itemCount   uint32
-		items       []RawCounterItem
-		bytesNeeded uint32
-	)
-
-	buf := make([]byte, 1)
+	var err error
 
 	for data := range c.collectCh {
 		err = (func() error {
-			if ret := CollectQueryData(c.handle); ret != ErrorSuccess {
-				return fmt.Errorf("failed to collect query data: %w", NewPdhError(ret))
+			samples, err := c.source.Collect()
+			if err != nil {
+				if isStaleQueryError(err) {
+					return fmt.Errorf("%w: %w", ErrStaleQuery, err)
+				}
+
+				return err
 			}
 
 			dv := reflect.ValueOf(data)
@@ -296,110 +255,79 @@ func (c *Collector) collectRoutine() {
 			elemValue := reflect.ValueOf(reflect.New(elemType).Interface()).Elem()
 
 			indexMap := map[string]int{}
-			stringMap := map[*uint16]string{}
 
-			for _, counter := range c.counters {
-				for _, instance := range counter.Instances {
-					// Get the info with the current buffer size
-					bytesNeeded = uint32(cap(buf))
+			for _, sample := range samples {
+				counter, ok := c.counters[sample.Counter]
+				if !ok {
+					continue
+				}
 
-					for {
-						ret := GetRawCounterArray(instance, &bytesNeeded, &itemCount, &buf[0])
+				instanceName := sample.Instance
 
-						if ret == ErrorSuccess {
-							break
-						}
+				if strings.HasSuffix(instanceName, InstanceTotal) && !c.totalCounterRequested {
+					continue
+				}
 
-						if err := NewPdhError(ret); ret != MoreData && !isKnownCounterDataError(err) {
-							return fmt.Errorf("GetRawCounterArray: %w", err)
-						}
+				if instanceName == "" || instanceName == "*" {
+					instanceName = InstanceEmpty
+				}
 
-						if bytesNeeded <= uint32(cap(buf)) {
-							return fmt.Errorf("GetRawCounterArray reports buffer too small (%d), but buffer is large enough (%d): %w", uint32(cap(buf)), bytesNeeded, NewPdhError(ret))
-						}
+				index, ok := indexMap[instanceName]
+				if !ok {
+					index = dv.Len()
+					indexMap[instanceName] = index
 
-						buf = make([]byte, bytesNeeded)
+					if c.nameIndexValue != -1 {
+						elemValue.Field(c.nameIndexValue).SetString(instanceName)
 					}
 
-					items = unsafe.Slice((*RawCounterItem)(unsafe.Pointer(&buf[0])), itemCount)
-
-					var (
-						instanceName string
-						ok           bool
-					)
-
-					for _, item := range items {
-						if item.RawValue.CStatus != CstatusValidData && item.RawValue.CStatus != CstatusNewData {
-							continue
-						}
-
-						if instanceName, ok = stringMap[item.SzName]; !ok {
-							instanceName = windows.UTF16PtrToString(item.SzName)
-							stringMap[item.SzName] = instanceName
-						}
-
-						if strings.HasSuffix(instanceName, InstanceTotal) && !c.totalCounterRequested {
-							continue
+					if c.metricsTypeIndexValue != -1 {
+						var metricsType prometheus.ValueType
+						if metricsType, ok = SupportedCounterTypes[sample.Type]; !ok {
+							metricsType = prometheus.GaugeValue
 						}
 
-						if instanceName == "" || instanceName == "*" {
-							instanceName = InstanceEmpty
-						}
-
-						var (
-							index int
-							ok    bool
-						)
-
-						if index, ok = indexMap[instanceName]; !ok {
-							index = dv.Len()
-							indexMap[instanceName] = index
-
-							if c.nameIndexValue != -1 {
-								elemValue.Field(c.nameIndexValue).SetString(instanceName)
-							}
+						elemValue.Field(c.metricsTypeIndexValue).Set(reflect.ValueOf(metricsType))
+					}
 
-							if c.metricsTypeIndexValue != -1 {
-								var metricsType prometheus.ValueType
-								if metricsType, ok = SupportedCounterTypes[counter.Type]; !ok {
-									metricsType = prometheus.GaugeValue
-								}
+					dv.Set(reflect.Append(dv, elemValue))
+				}
 
-								elemValue.Field(c.metricsTypeIndexValue).Set(reflect.ValueOf(metricsType))
-							}
+				// This is a workaround for the issue with the elapsed time counter type.
+				// Source: https://github.com/prometheus-community/windows_exporter/pull/335/files#diff-d5d2528f559ba2648c2866aec34b1eaa5c094dedb52bd0ff22aa5eb83226bd8dR76-R83
+				// Ref: https://learn.microsoft.com/en-us/windows/win32/perfctrs/calculating-counter-values
+				if value, ok := DerivedValue(sample.Type, sample.FirstValue, sample.Frequency); ok {
+					dv.Index(index).
+						Field(counter.FieldIndexValue).
+						SetFloat(value)
+				} else {
+					if counter.FieldIndexSecondValue != -1 {
+						dv.Index(index).
+							Field(counter.FieldIndexSecondValue).
+							SetFloat(float64(sample.SecondValue))
+					}
 
-							dv.Set(reflect.Append(dv, elemValue))
-						}
+					if counter.FieldIndexValue != -1 {
+						dv.Index(index).
+							Field(counter.FieldIndexValue).
+							SetFloat(float64(sample.FirstValue))
+					}
+				}
 
-						// This is a workaround for the issue with the elapsed time counter type.
-						// Source: https://github.com/prometheus-community/windows_exporter/pull/335/files#diff-d5d2528f559ba2648c2866aec34b1eaa5c094dedb52bd0ff22aa5eb83226bd8dR76-R83
-						// Ref: https://learn.microsoft.com/en-us/windows/win32/perfctrs/calculating-counter-values
-						switch counter.Type {
-						case PERF_ELAPSED_TIME:
-							dv.Index(index).
-								Field(counter.FieldIndexValue).
-								SetFloat(float64((item.RawValue.FirstValue - WindowsEpoch) / counter.Frequency))
-						case PERF_100NSEC_TIMER, PERF_PRECISION_100NS_TIMER:
-							dv.Index(index).
-								Field(counter.FieldIndexValue).
-								SetFloat(float64(item.RawValue.FirstValue) * TicksToSecondScaleFactor)
-						default:
-							if counter.FieldIndexSecondValue != -1 {
-								dv.Index(index).
-									Field(counter.FieldIndexSecondValue).
-									SetFloat(float64(item.RawValue.SecondValue))
-							}
-
-							if counter.FieldIndexValue != -1 {
-								dv.Index(index).
-									Field(counter.FieldIndexValue).
-									SetFloat(float64(item.RawValue.FirstValue))
-							}
-						}
+				if counter.FieldIndexMetricType != -1 {
+					metricType, ok := SupportedCounterTypes[sample.Type]
+					if !ok {
+						metricType = prometheus.GaugeValue
 					}
+
+					dv.Index(index).
+						Field(counter.FieldIndexMetricType).
+						Set(reflect.ValueOf(metricType))
 				}
 			}
 
+			// An object may have no instances, e.g. if all application pools are stopped.
+			// Only status errors of the query mark it as stale.
 			if dv.Len() == 0 {
 				return ErrNoData
 			}
@@ -419,9 +347,11 @@ func (c *Collector) Close() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	CloseQuery(c.handle)
+	if c.source != nil {
+		c.source.Close()
+	}
 
-	c.handle = 0
+	c.source = nil
 
 	if c.collectCh != nil {
 		close(c.collectCh)
@@ -434,26 +364,3 @@ func (c *Collector) Close() {
 	c.collectCh = nil
 	c.errorCh = nil
 }
-
-func formatCounterPath(object, instance, counterName string) string {
-	var counterPath string
-
-	if instance == InstanceEmpty {
-		counterPath = fmt.Sprintf(`\%s\%s`, object, counterName)
-	} else {
-		counterPath = fmt.Sprintf(`\%s(%s)\%s`, object, instance, counterName)
-	}
-
-	return counterPath
-}
-
-func isKnownCounterDataError(err error) bool {
-	var pdhErr *Error
-
-	return errors.As(err, &pdhErr) && (pdhErr.ErrorCode == InvalidData ||
-		pdhErr.ErrorCode == CalcNegativeDenominator ||
-		pdhErr.ErrorCode == CalcNegativeValue ||
-		pdhErr.ErrorCode == CstatusInvalidData ||
-		pdhErr.ErrorCode == CstatusNoInstance ||
-		pdhErr.ErrorCode == NoData)
-}
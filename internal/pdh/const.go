@@ -11,8 +11,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build windows
-
 package pdh
 
 import "github.com/prometheus/client_golang/prometheus"
@@ -90,3 +88,94 @@ var SupportedCounterTypes = map[uint32]prometheus.ValueType{
 	PERF_RAW_BASE:                   prometheus.GaugeValue,
 	PERF_LARGE_RAW_BASE:             prometheus.GaugeValue,
 }
+
+// PDH error codes, which can be returned by all Pdh* functions. Taken from mingw-w64 pdhmsg.h
+
+const (
+	CstatusValidData                   uint32 = 0x00000000 // The returned data is valid.
+	CstatusNewData                     uint32 = 0x00000001 // The return data value is valid and different from the last sample.
+	CstatusNoMachine                   uint32 = 0x800007D0 // Unable to connect to the specified computer, or the computer is offline.
+	CstatusNoInstance                  uint32 = 0x800007D1
+	MoreData                           uint32 = 0x800007D2 // The PdhGetFormattedCounterArray* function can return this if there's 'more data to be displayed'.
+	CstatusItemNotValidated            uint32 = 0x800007D3
+	Retry                              uint32 = 0x800007D4
+	NoData                             uint32 = 0x800007D5 // The query does not currently contain any counters (for example, limited access)
+	CalcNegativeDenominator            uint32 = 0x800007D6
+	CalcNegativeTimebase               uint32 = 0x800007D7
+	CalcNegativeValue                  uint32 = 0x800007D8
+	DialogCancelled                    uint32 = 0x800007D9
+	EndOfLogFile                       uint32 = 0x800007DA
+	AsyncQueryTimeout                  uint32 = 0x800007DB
+	CannotSetDefaultRealtimeDatasource uint32 = 0x800007DC
+	CstatusNoObject                    uint32 = 0xC0000BB8
+	CstatusNoCounter                   uint32 = 0xC0000BB9 // The specified counter could not be found.
+	CstatusInvalidData                 uint32 = 0xC0000BBA // The counter was successfully found, but the data returned is not valid.
+	MemoryAllocationFailure            uint32 = 0xC0000BBB
+	InvalidHandle                      uint32 = 0xC0000BBC
+	InvalidArgument                    uint32 = 0xC0000BBD // Required argument is missing or incorrect.
+	FunctionNotFound                   uint32 = 0xC0000BBE
+	CstatusNoCountername               uint32 = 0xC0000BBF
+	CstatusBadCountername              uint32 = 0xC0000BC0 // Unable to parse the counter path. Check the format and syntax of the specified path.
+	InvalidBuffer                      uint32 = 0xC0000BC1
+	InsufficientBuffer                 uint32 = 0xC0000BC2
+	CannotConnectMachine               uint32 = 0xC0000BC3
+	InvalidPath                        uint32 = 0xC0000BC4
+	InvalidInstance                    uint32 = 0xC0000BC5
+	InvalidData                        uint32 = 0xC0000BC6 // specified counter does not contain valid data or a successful status code.
+	NoDialogData                       uint32 = 0xC0000BC7
+	CannotReadNameStrings              uint32 = 0xC0000BC8
+	LogFileCreateError                 uint32 = 0xC0000BC9
+	LogFileOpenError                   uint32 = 0xC0000BCA
+	LogTypeNotFound                    uint32 = 0xC0000BCB
+	NoMoreData                         uint32 = 0xC0000BCC
+	EntryNotInLogFile                  uint32 = 0xC0000BCD
+	DataSourceIsLogFile                uint32 = 0xC0000BCE
+	DataSourceIsRealTime               uint32 = 0xC0000BCF
+	UnableReadLogHeader                uint32 = 0xC0000BD0
+	FileNotFound                       uint32 = 0xC0000BD1
+	FileAlreadyExists                  uint32 = 0xC0000BD2
+	NotImplemented                     uint32 = 0xC0000BD3
+	StringNotFound                     uint32 = 0xC0000BD4
+	UnableMapNameFiles                 uint32 = 0x80000BD5
+	UnknownLogFormat                   uint32 = 0xC0000BD6
+	UnknownLogsvcCommand               uint32 = 0xC0000BD7
+	LogsvcQueryNotFound                uint32 = 0xC0000BD8
+	LogsvcNotOpened                    uint32 = 0xC0000BD9
+	WbemError                          uint32 = 0xC0000BDA
+	AccessDenied                       uint32 = 0xC0000BDB
+	LogFileTooSmall                    uint32 = 0xC0000BDC
+	InvalidDatasource                  uint32 = 0xC0000BDD
+	InvalidSqldb                       uint32 = 0xC0000BDE
+	NoCounters                         uint32 = 0xC0000BDF
+	SQLAllocFailed                     uint32 = 0xC0000BE0
+	SQLAllocconFailed                  uint32 = 0xC0000BE1
+	SQLExecDirectFailed                uint32 = 0xC0000BE2
+	SQLFetchFailed                     uint32 = 0xC0000BE3
+	SQLRowcountFailed                  uint32 = 0xC0000BE4
+	SQLMoreResultsFailed               uint32 = 0xC0000BE5
+	SQLConnectFailed                   uint32 = 0xC0000BE6
+	SQLBindFailed                      uint32 = 0xC0000BE7
+	CannotConnectWmiServer             uint32 = 0xC0000BE8
+	PlaCollectionAlreadyRunning        uint32 = 0xC0000BE9
+	PlaErrorScheduleOverlap            uint32 = 0xC0000BEA
+	PlaCollectionNotFound              uint32 = 0xC0000BEB
+	PlaErrorScheduleElapsed            uint32 = 0xC0000BEC
+	PlaErrorNostart                    uint32 = 0xC0000BED
+	PlaErrorAlreadyExists              uint32 = 0xC0000BEE
+	PlaErrorTypeMismatch               uint32 = 0xC0000BEF
+	PlaErrorFilepath                   uint32 = 0xC0000BF0
+	PlaServiceError                    uint32 = 0xC0000BF1
+	PlaValidationError                 uint32 = 0xC0000BF2
+	PlaValidationWarning               uint32 = 0x80000BF3
+	PlaErrorNameTooLong                uint32 = 0xC0000BF4
+	InvalidSQLLogFormat                uint32 = 0xC0000BF5
+	CounterAlreadyInQuery              uint32 = 0xC0000BF6
+	BinaryLogCorrupt                   uint32 = 0xC0000BF7
+	LogSampleTooSmall                  uint32 = 0xC0000BF8
+	OsLaterVersion                     uint32 = 0xC0000BF9
+	OsEarlierVersion                   uint32 = 0xC0000BFA
+	IncorrectAppendTime                uint32 = 0xC0000BFB
+	UnmatchedAppendCounter             uint32 = 0xC0000BFC
+	SQLAlterDetailFailed               uint32 = 0xC0000BFD
+	QueryPerfDataTimeout               uint32 = 0xC0000BFE
+)
@@ -0,0 +1,209 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdh
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ticksPerSecond100ns is the time base of the PERF_100NSEC_* counter types.
+const ticksPerSecond100ns = 10_000_000
+
+var (
+	ErrUnsupportedCounterType = errors.New("unsupported counter type")
+	ErrMissingTimeBase        = errors.New("counter type requires a time base")
+)
+
+// ComputedValue is a Prometheus value derived from a raw counter value.
+type ComputedValue struct {
+	// Suffix is appended to the metric name and carries the unit, e.g. "_seconds_total".
+	Suffix string
+	Type   prometheus.ValueType
+	Value  float64
+}
+
+//nolint:gochecknoglobals
+var counterTypeNames = map[uint32]string{
+	PERF_COUNTER_RAWCOUNT_HEX:           "PERF_COUNTER_RAWCOUNT_HEX",
+	PERF_COUNTER_LARGE_RAWCOUNT_HEX:     "PERF_COUNTER_LARGE_RAWCOUNT_HEX",
+	PERF_COUNTER_TEXT:                   "PERF_COUNTER_TEXT",
+	PERF_COUNTER_RAWCOUNT:               "PERF_COUNTER_RAWCOUNT",
+	PERF_COUNTER_LARGE_RAWCOUNT:         "PERF_COUNTER_LARGE_RAWCOUNT",
+	PERF_DOUBLE_RAW:                     "PERF_DOUBLE_RAW",
+	PERF_COUNTER_DELTA:                  "PERF_COUNTER_DELTA",
+	PERF_COUNTER_LARGE_DELTA:            "PERF_COUNTER_LARGE_DELTA",
+	PERF_SAMPLE_COUNTER:                 "PERF_SAMPLE_COUNTER",
+	PERF_COUNTER_QUEUELEN_TYPE:          "PERF_COUNTER_QUEUELEN_TYPE",
+	PERF_COUNTER_LARGE_QUEUELEN_TYPE:    "PERF_COUNTER_LARGE_QUEUELEN_TYPE",
+	PERF_COUNTER_100NS_QUEUELEN_TYPE:    "PERF_COUNTER_100NS_QUEUELEN_TYPE",
+	PERF_COUNTER_OBJ_TIME_QUEUELEN_TYPE: "PERF_COUNTER_OBJ_TIME_QUEUELEN_TYPE",
+	PERF_COUNTER_COUNTER:                "PERF_COUNTER_COUNTER",
+	PERF_COUNTER_BULK_COUNT:             "PERF_COUNTER_BULK_COUNT",
+	PERF_RAW_FRACTION:                   "PERF_RAW_FRACTION",
+	PERF_LARGE_RAW_FRACTION:             "PERF_LARGE_RAW_FRACTION",
+	PERF_COUNTER_TIMER:                  "PERF_COUNTER_TIMER",
+	PERF_PRECISION_SYSTEM_TIMER:         "PERF_PRECISION_SYSTEM_TIMER",
+	PERF_100NSEC_TIMER:                  "PERF_100NSEC_TIMER",
+	PERF_PRECISION_100NS_TIMER:          "PERF_PRECISION_100NS_TIMER",
+	PERF_OBJ_TIME_TIMER:                 "PERF_OBJ_TIME_TIMER",
+	PERF_PRECISION_OBJECT_TIMER:         "PERF_PRECISION_OBJECT_TIMER",
+	PERF_SAMPLE_FRACTION:                "PERF_SAMPLE_FRACTION",
+	PERF_COUNTER_TIMER_INV:              "PERF_COUNTER_TIMER_INV",
+	PERF_100NSEC_TIMER_INV:              "PERF_100NSEC_TIMER_INV",
+	PERF_COUNTER_MULTI_TIMER:            "PERF_COUNTER_MULTI_TIMER",
+	PERF_100NSEC_MULTI_TIMER:            "PERF_100NSEC_MULTI_TIMER",
+	PERF_COUNTER_MULTI_TIMER_INV:        "PERF_COUNTER_MULTI_TIMER_INV",
+	PERF_100NSEC_MULTI_TIMER_INV:        "PERF_100NSEC_MULTI_TIMER_INV",
+	PERF_AVERAGE_TIMER:                  "PERF_AVERAGE_TIMER",
+	PERF_ELAPSED_TIME:                   "PERF_ELAPSED_TIME",
+	PERF_COUNTER_NODATA:                 "PERF_COUNTER_NODATA",
+	PERF_AVERAGE_BULK:                   "PERF_AVERAGE_BULK",
+	PERF_SAMPLE_BASE:                    "PERF_SAMPLE_BASE",
+	PERF_AVERAGE_BASE:                   "PERF_AVERAGE_BASE",
+	PERF_RAW_BASE:                       "PERF_RAW_BASE",
+	PERF_PRECISION_TIMESTAMP:            "PERF_PRECISION_TIMESTAMP",
+	PERF_LARGE_RAW_BASE:                 "PERF_LARGE_RAW_BASE",
+	PERF_COUNTER_MULTI_BASE:             "PERF_COUNTER_MULTI_BASE",
+	PERF_COUNTER_HISTOGRAM_TYPE:         "PERF_COUNTER_HISTOGRAM_TYPE",
+}
+
+// CounterTypeName returns the name of the counter type as defined in winperf.h.
+func CounterTypeName(counterType uint32) string {
+	if name, ok := counterTypeNames[counterType]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("0x%08x", counterType)
+}
+
+// UsesFrequency reports whether the counter type is measured in ticks of a time base, which has to be passed
+// as frequency to ComputeValues. This is the system performance frequency for system timers, and the
+// frequency of the object for object timers and PERF_ELAPSED_TIME.
+func UsesFrequency(counterType uint32) bool {
+	switch counterType {
+	case PERF_COUNTER_TIMER, PERF_COUNTER_TIMER_INV, PERF_PRECISION_SYSTEM_TIMER,
+		PERF_COUNTER_MULTI_TIMER, PERF_COUNTER_MULTI_TIMER_INV,
+		PERF_OBJ_TIME_TIMER, PERF_PRECISION_OBJECT_TIMER,
+		PERF_COUNTER_QUEUELEN_TYPE, PERF_COUNTER_LARGE_QUEUELEN_TYPE, PERF_COUNTER_OBJ_TIME_QUEUELEN_TYPE,
+		PERF_AVERAGE_TIMER, PERF_ELAPSED_TIME:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+ComputeValues derives the Prometheus values of a raw counter value, see
+https://learn.microsoft.com/en-us/windows/win32/perfctrs/calculating-counter-values.
+
+Instead of calculating the displayed value from two samples like PDH does, the raw values are exposed,
+so Prometheus computes rates and averages over any range:
+
+  - raw counts are gauges,
+  - rates (e.g. PERF_COUNTER_COUNTER) are counters with a "_total" suffix,
+  - timers are counters in seconds with a "_seconds_total" suffix, inverse timers (*_INV) count the inactive time,
+  - queue lengths are counters of the queue length integrated over seconds, whose rate is the average queue length,
+  - averages (PERF_AVERAGE_TIMER, PERF_AVERAGE_BULK) and sample fractions are "_sum" and "_count" counter pairs,
+  - raw fractions are gauges with a "_ratio" suffix,
+  - PERF_ELAPSED_TIME is the start time as Unix timestamp with a "_timestamp_seconds" suffix.
+
+Base counters, time stamps and counters without numeric data have no values.
+*/
+func ComputeValues(counterType uint32, firstValue, secondValue, frequency int64) ([]ComputedValue, error) {
+	if UsesFrequency(counterType) && frequency <= 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingTimeBase, CounterTypeName(counterType))
+	}
+
+	switch counterType {
+	case PERF_COUNTER_RAWCOUNT, PERF_COUNTER_LARGE_RAWCOUNT, PERF_COUNTER_RAWCOUNT_HEX, PERF_COUNTER_LARGE_RAWCOUNT_HEX:
+		return []ComputedValue{{"", prometheus.GaugeValue, float64(firstValue)}}, nil
+	case PERF_DOUBLE_RAW:
+		return []ComputedValue{{"", prometheus.GaugeValue, math.Float64frombits(uint64(firstValue))}}, nil
+	case PERF_COUNTER_COUNTER, PERF_COUNTER_BULK_COUNT, PERF_SAMPLE_COUNTER, PERF_COUNTER_DELTA, PERF_COUNTER_LARGE_DELTA:
+		return []ComputedValue{{"_total", prometheus.CounterValue, float64(firstValue)}}, nil
+	case PERF_100NSEC_TIMER, PERF_PRECISION_100NS_TIMER, PERF_100NSEC_TIMER_INV,
+		PERF_100NSEC_MULTI_TIMER, PERF_100NSEC_MULTI_TIMER_INV:
+		return []ComputedValue{{"_seconds_total", prometheus.CounterValue, TicksToSeconds(firstValue, ticksPerSecond100ns)}}, nil
+	case PERF_COUNTER_TIMER, PERF_COUNTER_TIMER_INV, PERF_PRECISION_SYSTEM_TIMER,
+		PERF_COUNTER_MULTI_TIMER, PERF_COUNTER_MULTI_TIMER_INV,
+		PERF_OBJ_TIME_TIMER, PERF_PRECISION_OBJECT_TIMER:
+		return []ComputedValue{{"_seconds_total", prometheus.CounterValue, TicksToSeconds(firstValue, frequency)}}, nil
+	case PERF_COUNTER_QUEUELEN_TYPE, PERF_COUNTER_LARGE_QUEUELEN_TYPE, PERF_COUNTER_OBJ_TIME_QUEUELEN_TYPE:
+		return []ComputedValue{{"_total", prometheus.CounterValue, TicksToSeconds(firstValue, frequency)}}, nil
+	case PERF_COUNTER_100NS_QUEUELEN_TYPE:
+		return []ComputedValue{{"_total", prometheus.CounterValue, TicksToSeconds(firstValue, ticksPerSecond100ns)}}, nil
+	case PERF_AVERAGE_TIMER:
+		return []ComputedValue{
+			{"_seconds_sum", prometheus.CounterValue, TicksToSeconds(firstValue, frequency)},
+			{"_seconds_count", prometheus.CounterValue, float64(secondValue)},
+		}, nil
+	case PERF_AVERAGE_BULK, PERF_SAMPLE_FRACTION:
+		return []ComputedValue{
+			{"_sum", prometheus.CounterValue, float64(firstValue)},
+			{"_count", prometheus.CounterValue, float64(secondValue)},
+		}, nil
+	case PERF_RAW_FRACTION, PERF_LARGE_RAW_FRACTION:
+		return []ComputedValue{{"_ratio", prometheus.GaugeValue, Fraction(firstValue, secondValue)}}, nil
+	case PERF_ELAPSED_TIME:
+		return []ComputedValue{{"_timestamp_seconds", prometheus.GaugeValue, ElapsedTimeSeconds(firstValue, frequency)}}, nil
+	case PERF_SAMPLE_BASE, PERF_AVERAGE_BASE, PERF_RAW_BASE, PERF_LARGE_RAW_BASE, PERF_COUNTER_MULTI_BASE,
+		PERF_PRECISION_TIMESTAMP, PERF_COUNTER_NODATA, PERF_COUNTER_TEXT, PERF_COUNTER_HISTOGRAM_TYPE:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCounterType, CounterTypeName(counterType))
+	}
+}
+
+// DerivedValue returns the value a Collector stores for a raw counter value, if it's not the raw value itself.
+// Collectors derive their metrics from the raw values, except for elapsed times and 100ns timers, which are
+// stored as derived by ComputeValues, i.e. as Unix timestamp and in seconds. ok is false for all other counter
+// types and if the value can't be derived, e.g. without time base.
+func DerivedValue(counterType uint32, firstValue, frequency int64) (float64, bool) {
+	switch counterType {
+	case PERF_ELAPSED_TIME, PERF_100NSEC_TIMER, PERF_PRECISION_100NS_TIMER:
+	default:
+		return 0, false
+	}
+
+	values, err := ComputeValues(counterType, firstValue, 0, frequency)
+	if err != nil || len(values) != 1 {
+		return 0, false
+	}
+
+	return values[0].Value, true
+}
+
+// TicksToSeconds converts ticks of a time base with the given frequency to seconds.
+func TicksToSeconds(ticks, frequency int64) float64 {
+	return float64(ticks) / float64(frequency)
+}
+
+// ElapsedTimeSeconds converts the start time of a PERF_ELAPSED_TIME counter, measured in ticks of the
+// object's time base since 1601-01-01, to a Unix timestamp.
+func ElapsedTimeSeconds(value, frequency int64) float64 {
+	return float64(value-WindowsEpoch) / float64(frequency)
+}
+
+// Fraction returns the ratio of a value and its base. It's zero, if the base is zero.
+func Fraction(value, base int64) float64 {
+	if base == 0 {
+		return 0
+	}
+
+	return float64(value) / float64(base)
+}
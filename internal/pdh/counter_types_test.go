@@ -0,0 +1,164 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdh_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// qpcFrequency is a typical frequency of the system performance counter.
+const qpcFrequency = 10_000_000 / 4
+
+func TestComputeValues(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name        string
+		counterType uint32
+		first       int64
+		second      int64
+		frequency   int64
+		expected    []pdh.ComputedValue
+	}{
+		{"raw count", pdh.PERF_COUNTER_RAWCOUNT, 142, 0, 0, []pdh.ComputedValue{
+			{Suffix: "", Type: prometheus.GaugeValue, Value: 142},
+		}},
+		{"large raw count", pdh.PERF_COUNTER_LARGE_RAWCOUNT, 8589934592, 0, 0, []pdh.ComputedValue{
+			{Suffix: "", Type: prometheus.GaugeValue, Value: 8589934592},
+		}},
+		{"raw count hex", pdh.PERF_COUNTER_RAWCOUNT_HEX, 0x10, 0, 0, []pdh.ComputedValue{
+			{Suffix: "", Type: prometheus.GaugeValue, Value: 16},
+		}},
+		{"double raw", pdh.PERF_DOUBLE_RAW, int64(math.Float64bits(36.6)), 0, 0, []pdh.ComputedValue{
+			{Suffix: "", Type: prometheus.GaugeValue, Value: 36.6},
+		}},
+		{"rate", pdh.PERF_COUNTER_COUNTER, 81234567, 1234, 0, []pdh.ComputedValue{
+			{Suffix: "_total", Type: prometheus.CounterValue, Value: 81234567},
+		}},
+		{"bulk count", pdh.PERF_COUNTER_BULK_COUNT, 180388626632, 1234, 0, []pdh.ComputedValue{
+			{Suffix: "_total", Type: prometheus.CounterValue, Value: 180388626632},
+		}},
+		{"delta", pdh.PERF_COUNTER_DELTA, 7, 0, 0, []pdh.ComputedValue{
+			{Suffix: "_total", Type: prometheus.CounterValue, Value: 7},
+		}},
+		{"100ns timer", pdh.PERF_100NSEC_TIMER, 25_000_000, 0, 0, []pdh.ComputedValue{
+			{Suffix: "_seconds_total", Type: prometheus.CounterValue, Value: 2.5},
+		}},
+		{"precision 100ns timer", pdh.PERF_PRECISION_100NS_TIMER, 5_000_000, 133500000000000000, 0, []pdh.ComputedValue{
+			{Suffix: "_seconds_total", Type: prometheus.CounterValue, Value: 0.5},
+		}},
+		{"100ns timer inverse", pdh.PERF_100NSEC_TIMER_INV, 150_000_000, 0, 0, []pdh.ComputedValue{
+			{Suffix: "_seconds_total", Type: prometheus.CounterValue, Value: 15},
+		}},
+		{"system timer", pdh.PERF_COUNTER_TIMER, 5_000_000, 0, qpcFrequency, []pdh.ComputedValue{
+			{Suffix: "_seconds_total", Type: prometheus.CounterValue, Value: 2},
+		}},
+		{"object timer", pdh.PERF_OBJ_TIME_TIMER, 3000, 0, 1000, []pdh.ComputedValue{
+			{Suffix: "_seconds_total", Type: prometheus.CounterValue, Value: 3},
+		}},
+		{"queue length", pdh.PERF_COUNTER_LARGE_QUEUELEN_TYPE, 7_500_000, 0, qpcFrequency, []pdh.ComputedValue{
+			{Suffix: "_total", Type: prometheus.CounterValue, Value: 3},
+		}},
+		{"100ns queue length", pdh.PERF_COUNTER_100NS_QUEUELEN_TYPE, 40_000_000, 0, 0, []pdh.ComputedValue{
+			{Suffix: "_total", Type: prometheus.CounterValue, Value: 4},
+		}},
+		{"average timer", pdh.PERF_AVERAGE_TIMER, 1_250_000, 20, qpcFrequency, []pdh.ComputedValue{
+			{Suffix: "_seconds_sum", Type: prometheus.CounterValue, Value: 0.5},
+			{Suffix: "_seconds_count", Type: prometheus.CounterValue, Value: 20},
+		}},
+		{"average bulk", pdh.PERF_AVERAGE_BULK, 81920, 20, 0, []pdh.ComputedValue{
+			{Suffix: "_sum", Type: prometheus.CounterValue, Value: 81920},
+			{Suffix: "_count", Type: prometheus.CounterValue, Value: 20},
+		}},
+		{"sample fraction", pdh.PERF_SAMPLE_FRACTION, 3, 4, 0, []pdh.ComputedValue{
+			{Suffix: "_sum", Type: prometheus.CounterValue, Value: 3},
+			{Suffix: "_count", Type: prometheus.CounterValue, Value: 4},
+		}},
+		{"raw fraction", pdh.PERF_RAW_FRACTION, 30, 120, 0, []pdh.ComputedValue{
+			{Suffix: "_ratio", Type: prometheus.GaugeValue, Value: 0.25},
+		}},
+		{"raw fraction without base", pdh.PERF_LARGE_RAW_FRACTION, 30, 0, 0, []pdh.ComputedValue{
+			{Suffix: "_ratio", Type: prometheus.GaugeValue, Value: 0},
+		}},
+		{"elapsed time", pdh.PERF_ELAPSED_TIME, 133500000005000000, 0, 10_000_000, []pdh.ComputedValue{
+			{Suffix: "_timestamp_seconds", Type: prometheus.GaugeValue, Value: 1705526400.5},
+		}},
+		{"average base", pdh.PERF_AVERAGE_BASE, 20, 0, 0, nil},
+		{"large raw base", pdh.PERF_LARGE_RAW_BASE, 120, 0, 0, nil},
+		{"precision timestamp", pdh.PERF_PRECISION_TIMESTAMP, 133500000000000000, 0, 0, nil},
+		{"no data", pdh.PERF_COUNTER_NODATA, 0, 0, 0, nil},
+		{"text", pdh.PERF_COUNTER_TEXT, 0, 0, 0, nil},
+	} {
+		values, err := pdh.ComputeValues(tc.counterType, tc.first, tc.second, tc.frequency)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, values, tc.name)
+
+		// The value types match the types exposed by the collectors.
+		if metricType, ok := pdh.SupportedCounterTypes[tc.counterType]; ok && len(values) == 1 {
+			assert.Equal(t, metricType, values[0].Type, tc.name)
+		}
+	}
+}
+
+func TestComputeValuesErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := pdh.ComputeValues(pdh.PERF_AVERAGE_TIMER, 1, 1, 0)
+	require.ErrorIs(t, err, pdh.ErrMissingTimeBase)
+	require.ErrorContains(t, err, "PERF_AVERAGE_TIMER")
+
+	_, err = pdh.ComputeValues(pdh.PERF_ELAPSED_TIME, 1, 0, -1)
+	require.ErrorIs(t, err, pdh.ErrMissingTimeBase)
+
+	_, err = pdh.ComputeValues(0x12345678, 1, 0, 0)
+	require.ErrorIs(t, err, pdh.ErrUnsupportedCounterType)
+	require.ErrorContains(t, err, "0x12345678")
+}
+
+func TestDerivedValue(t *testing.T) {
+	t.Parallel()
+
+	value, ok := pdh.DerivedValue(pdh.PERF_100NSEC_TIMER, 25_000_000, 0)
+	require.True(t, ok)
+	assert.InDelta(t, 2.5, value, 0)
+
+	value, ok = pdh.DerivedValue(pdh.PERF_ELAPSED_TIME, pdh.WindowsEpoch+1_700_000_000*qpcFrequency, qpcFrequency)
+	require.True(t, ok)
+	assert.InDelta(t, 1_700_000_000, value, 0)
+
+	// Collectors derive the metrics of other counter types from the raw values.
+	_, ok = pdh.DerivedValue(pdh.PERF_COUNTER_COUNTER, 42, qpcFrequency)
+	assert.False(t, ok)
+
+	_, ok = pdh.DerivedValue(pdh.PERF_100NSEC_TIMER_INV, 25_000_000, 0)
+	assert.False(t, ok)
+
+	// Elapsed times can't be derived without time base.
+	_, ok = pdh.DerivedValue(pdh.PERF_ELAPSED_TIME, pdh.WindowsEpoch, 0)
+	assert.False(t, ok)
+}
+
+func TestCounterTypeName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "PERF_100NSEC_TIMER", pdh.CounterTypeName(pdh.PERF_100NSEC_TIMER))
+	assert.Equal(t, "PERF_COUNTER_RAWCOUNT_HEX", pdh.CounterTypeName(pdh.PERF_COUNTER_RAWCOUNT_HEX))
+	assert.Equal(t, "0x00000001", pdh.CounterTypeName(1))
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package discovery enumerates performance counter objects, their counters and instances,
+// e.g. to write the configuration of the performancecounter collector.
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrObjectNotFound = errors.New("performance counter object not found")
+
+// Enumerator enumerates the performance counter objects of a host.
+type Enumerator interface {
+	// Objects returns the names of all objects.
+	Objects() ([]string, error)
+	// Object returns the counters and instances of an object. Unknown objects are reported by ErrObjectNotFound.
+	Object(name string) (Object, error)
+}
+
+// Object is a performance counter object with its counters and instances.
+// Objects without instances, like Memory, have no instances.
+type Object struct {
+	Name      string    `json:"name"                yaml:"name"`
+	Counters  []Counter `json:"counters"            yaml:"counters"`
+	Instances []string  `json:"instances,omitempty" yaml:"instances,omitempty"`
+}
+
+// Counter is a counter of an object with its PERF_* counter type.
+type Counter struct {
+	Name string `json:"name" yaml:"name"`
+	Type uint32 `json:"type" yaml:"type"`
+}
+
+// List returns all objects, or only the given object, sorted by name.
+func List(enumerator Enumerator, object string) ([]Object, error) {
+	names := []string{object}
+
+	if object == "" {
+		var err error
+
+		names, err = enumerator.Objects()
+		if err != nil {
+			return nil, fmt.Errorf("failed to enumerate objects: %w", err)
+		}
+
+		names = slices.Clone(names)
+		slices.Sort(names)
+		names = slices.Compact(names)
+	}
+
+	objects := make([]Object, 0, len(names))
+
+	for _, name := range names {
+		obj, err := enumerator.Object(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enumerate object %s: %w", name, err)
+		}
+
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/discovery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// fakeEnumerator returns a fixed set of objects.
+type fakeEnumerator map[string]discovery.Object
+
+func (e fakeEnumerator) Objects() ([]string, error) {
+	names := make([]string, 0, len(e))
+
+	for name := range e {
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func (e fakeEnumerator) Object(name string) (discovery.Object, error) {
+	object, ok := e[name]
+	if !ok {
+		return discovery.Object{}, fmt.Errorf("%w: %s", discovery.ErrObjectNotFound, name)
+	}
+
+	return object, nil
+}
+
+func newFakeEnumerator() fakeEnumerator {
+	return fakeEnumerator{
+		"Processor Information": {
+			Name: "Processor Information",
+			Counters: []discovery.Counter{
+				{Name: "% Processor Time", Type: pdh.PERF_100NSEC_TIMER},
+				{Name: "% Processor Utility", Type: pdh.PERF_AVERAGE_BULK},
+				{Name: "% Processor Utility Base", Type: pdh.PERF_AVERAGE_BASE},
+			},
+			Instances: []string{"0,0", "0,1", "0,_Total", "_Total"},
+		},
+		"Memory": {
+			Name: "Memory",
+			Counters: []discovery.Counter{
+				{Name: "Available Bytes", Type: pdh.PERF_COUNTER_LARGE_RAWCOUNT},
+				{Name: "Cache Faults/sec", Type: pdh.PERF_COUNTER_COUNTER},
+			},
+		},
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	enumerator := newFakeEnumerator()
+
+	objects, err := discovery.List(enumerator, "")
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+	assert.Equal(t, "Memory", objects[0].Name)
+	assert.Equal(t, "Processor Information", objects[1].Name)
+
+	objects, err = discovery.List(enumerator, "Memory")
+	require.NoError(t, err)
+	assert.Equal(t, []discovery.Object{enumerator["Memory"]}, objects)
+
+	_, err = discovery.List(enumerator, "Processor")
+	require.ErrorIs(t, err, discovery.ErrObjectNotFound)
+}
+
+func TestWriteText(t *testing.T) {
+	t.Parallel()
+
+	objects, err := discovery.List(newFakeEnumerator(), "")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	require.NoError(t, discovery.Write(&buf, objects, discovery.FormatText))
+	assert.Equal(t, `Memory
+  Counters: 2
+    Available Bytes   PERF_COUNTER_LARGE_RAWCOUNT
+    Cache Faults/sec  PERF_COUNTER_COUNTER
+
+Processor Information
+  Counters: 3
+    % Processor Time          PERF_100NSEC_TIMER
+    % Processor Utility       PERF_AVERAGE_BULK
+    % Processor Utility Base  PERF_AVERAGE_BASE
+  Instances: 4
+    0,0
+    0,1
+    0,_Total
+    _Total
+`, buf.String())
+}
+
+func TestWriteYAML(t *testing.T) {
+	t.Parallel()
+
+	objects, err := discovery.List(newFakeEnumerator(), "")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+
+	require.NoError(t, discovery.Write(&buf, objects, discovery.FormatYAML))
+	assert.Equal(t, `collector:
+  performancecounter:
+    objects:
+      - name: memory
+        object: Memory
+        counters:
+          - name: Available Bytes
+          - name: Cache Faults/sec
+      - name: processor_information
+        object: Processor Information
+        instances: ['*']
+        counters:
+          - name: '% Processor Time'
+          - name: '% Processor Utility'
+`, buf.String())
+
+	// The snippet is a valid configuration of the performancecounter collector.
+	var config struct {
+		Collector struct {
+			PerformanceCounter struct {
+				Objects []struct {
+					Name      string   `yaml:"name"`
+					Object    string   `yaml:"object"`
+					Instances []string `yaml:"instances"`
+					Counters  []struct {
+						Name string `yaml:"name"`
+					} `yaml:"counters"`
+				} `yaml:"objects"`
+			} `yaml:"performancecounter"`
+		} `yaml:"collector"`
+	}
+
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &config))
+	require.Len(t, config.Collector.PerformanceCounter.Objects, 2)
+	assert.Equal(t, []string{"*"}, config.Collector.PerformanceCounter.Objects[1].Instances)
+	assert.Equal(t, "% Processor Time", config.Collector.PerformanceCounter.Objects[1].Counters[0].Name)
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	require.ErrorContains(t, discovery.Write(&bytes.Buffer{}, nil, "json"), `unknown format "json"`)
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package discovery
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/registry"
+	"golang.org/x/sys/windows"
+)
+
+// registryEnumerator reads objects, counters and counter types with their English names from HKEY_PERFORMANCE_DATA.
+type registryEnumerator struct{}
+
+// NewEnumerator returns an enumerator of the performance counters of the local host.
+//
+// Instance names are expanded by PDH, since only PDH adds the #index suffix to instances with the same name.
+// PDH expects localized names, which are looked up in the name table of the host language.
+// If PDH can't expand the instances, the instance names of the registry are used.
+func NewEnumerator() Enumerator {
+	return registryEnumerator{}
+}
+
+// Objects returns the objects of the "Global" query, i.e. all objects except those Windows marks as costly.
+func (registryEnumerator) Objects() ([]string, error) {
+	objects, err := registry.QueryPerformanceData("Global", "")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(objects))
+
+	for _, object := range objects {
+		if object.Name != "" {
+			names = append(names, object.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// Object accepts the English or localized name of an object, but always returns the English names.
+func (registryEnumerator) Object(name string) (Object, error) {
+	name = registry.LocalNames().English(name)
+
+	index := registry.CounterNameTable.LookupIndex(name)
+	if index == 0 {
+		return Object{}, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+
+	objects, err := registry.QueryPerformanceData(strconv.FormatUint(uint64(index), 10), name)
+	if err != nil {
+		return Object{}, err
+	}
+
+	if len(objects) == 0 {
+		return Object{}, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+
+	object := Object{
+		Name:     objects[0].Name,
+		Counters: make([]Counter, 0, len(objects[0].CounterDefs)),
+	}
+
+	for _, def := range objects[0].CounterDefs {
+		object.Counters = append(object.Counters, Counter{Name: def.Name, Type: def.CounterType})
+	}
+
+	// Objects without instances have a single instance without name.
+	registryInstances := make([]string, 0, len(objects[0].Instances))
+
+	for _, instance := range objects[0].Instances {
+		if instance.Name != "" {
+			registryInstances = append(registryInstances, instance.Name)
+		}
+	}
+
+	if len(registryInstances) == 0 || len(object.Counters) == 0 {
+		return object, nil
+	}
+
+	object.Instances, err = expandInstances(object.Name, object.Counters[0].Name)
+	if err != nil {
+		object.Instances = registryInstances
+	}
+
+	return object, nil
+}
+
+// expandInstances returns the instance names of an object by expanding \object(*)\counter.
+// ExpandWildCardPath only knows the names of the host language.
+func expandInstances(object, counter string) ([]string, error) {
+	names := registry.LocalNames()
+	object = names.Localize(object)
+	counter = names.Localize(counter)
+	path := fmt.Sprintf(`\%s(*)\%s`, object, counter)
+
+	var size uint32
+
+	if ret := pdh.ExpandWildCardPath(path, nil, &size); ret != pdh.MoreData {
+		return nil, fmt.Errorf("ExpandWildCardPath: %w", pdh.NewPdhError(ret))
+	}
+
+	buf := make([]uint16, size)
+
+	if ret := pdh.ExpandWildCardPath(path, &buf[0], &size); ret != pdh.ErrorSuccess {
+		return nil, fmt.Errorf("ExpandWildCardPath: %w", pdh.NewPdhError(ret))
+	}
+
+	prefix := `\` + object + "("
+	suffix := `)\` + counter
+	instances := make([]string, 0)
+
+	// The paths are returned as list of null-terminated strings, which ends with an empty string.
+	for len(buf) > 0 && buf[0] != 0 {
+		end := slices.Index(buf, 0)
+		if end == -1 {
+			end = len(buf)
+		}
+
+		path := windows.UTF16ToString(buf[:end])
+		buf = buf[min(end+1, len(buf)):]
+
+		if strings.HasPrefix(path, prefix) && strings.HasSuffix(path, suffix) {
+			instances = append(instances, strings.TrimSuffix(strings.TrimPrefix(path, prefix), suffix))
+		}
+	}
+
+	return instances, nil
+}
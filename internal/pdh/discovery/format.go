@@ -0,0 +1,141 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FormatText lists the counters and instances of the objects.
+	FormatText = "text"
+	// FormatYAML writes a configuration snippet of the performancecounter collector.
+	FormatYAML = "yaml"
+)
+
+//nolint:gochecknoglobals
+var Formats = []string{FormatText, FormatYAML}
+
+// Write writes the objects in the given format.
+func Write(w io.Writer, objects []Object, format string) error {
+	switch format {
+	case FormatText:
+		return writeText(w, objects)
+	case FormatYAML:
+		return writeSnippet(w, objects)
+	default:
+		return fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+func writeText(w io.Writer, objects []Object) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for i, object := range objects {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		fmt.Fprintln(tw, object.Name)
+		fmt.Fprintf(tw, "  Counters: %d\n", len(object.Counters))
+
+		for _, counter := range object.Counters {
+			fmt.Fprintf(tw, "    %s\t%s\n", counter.Name, pdh.CounterTypeName(counter.Type))
+		}
+
+		if len(object.Instances) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(tw, "  Instances: %d\n", len(object.Instances))
+
+		for _, instance := range object.Instances {
+			fmt.Fprintf(tw, "    %s\n", instance)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// snippetObject mirrors the configuration of an object of the performancecounter collector.
+type snippetObject struct {
+	Name      string           `yaml:"name"`
+	Object    string           `yaml:"object"`
+	Instances []string         `yaml:"instances,omitempty,flow"`
+	Counters  []snippetCounter `yaml:"counters"`
+}
+
+type snippetCounter struct {
+	Name string `yaml:"name"`
+}
+
+type snippet struct {
+	Collector struct {
+		PerformanceCounter struct {
+			Objects []snippetObject `yaml:"objects"`
+		} `yaml:"performancecounter"`
+	} `yaml:"collector"`
+}
+
+// writeSnippet writes the objects as configuration file snippet of the performancecounter collector.
+// Counters, which do not expose a value on their own, like base counters of fractions, are omitted.
+func writeSnippet(w io.Writer, objects []Object) error {
+	var config snippet
+
+	config.Collector.PerformanceCounter.Objects = make([]snippetObject, 0, len(objects))
+
+	for _, object := range objects {
+		snippetObj := snippetObject{
+			Name:     pdh.SanitizeMetricName(object.Name),
+			Object:   object.Name,
+			Counters: make([]snippetCounter, 0, len(object.Counters)),
+		}
+
+		if len(object.Instances) > 0 {
+			snippetObj.Instances = []string{"*"}
+		}
+
+		for _, counter := range object.Counters {
+			if !exposesValue(counter.Type) {
+				continue
+			}
+
+			snippetObj.Counters = append(snippetObj.Counters, snippetCounter{Name: counter.Name})
+		}
+
+		config.Collector.PerformanceCounter.Objects = append(config.Collector.PerformanceCounter.Objects, snippetObj)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(config); err != nil {
+		return fmt.Errorf("failed to encode snippet: %w", err)
+	}
+
+	return encoder.Close()
+}
+
+// exposesValue reports whether values of the counter type are exposed, see pdh.ComputeValues.
+func exposesValue(counterType uint32) bool {
+	values, err := pdh.ComputeValues(counterType, 0, 0, 1)
+
+	return err == nil && len(values) > 0
+}
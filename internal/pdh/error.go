@@ -11,8 +11,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build windows
-
 package pdh
 
 import "errors"
@@ -20,8 +18,22 @@ import "errors"
 var (
 	ErrNoData                           = NewPdhError(NoData)
 	ErrPerformanceCounterNotInitialized = errors.New("performance counter not initialized")
+	// ErrStaleQuery is returned by Collector.Collect, if the counters of the query became invalid,
+	// e.g. since the providing service re-registered its counters. The collector has to be rebuilt.
+	ErrStaleQuery = errors.New("performance counter query is stale")
 )
 
+// isStaleQueryError reports whether err indicates invalid counter handles,
+// which do not recover without adding the counters to a new query.
+func isStaleQueryError(err error) bool {
+	var pdhErr *Error
+
+	return errors.As(err, &pdhErr) && (pdhErr.ErrorCode == CstatusNoObject ||
+		pdhErr.ErrorCode == CstatusNoCounter ||
+		pdhErr.ErrorCode == CstatusItemNotValidated ||
+		pdhErr.ErrorCode == InvalidHandle)
+}
+
 // Error represents error returned from Performance Counters API.
 type Error struct {
 	ErrorCode uint32
@@ -0,0 +1,31 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdh
+
+import "strings"
+
+//nolint:gochecknoglobals
+var metricNameReplacer = strings.NewReplacer(
+	".", "",
+	"%", "",
+	"/", "_",
+	" ", "_",
+	"-", "_",
+)
+
+// SanitizeMetricName converts object and counter names into a metric name, e.g. "Cache Faults/sec" into "cache_faults_sec".
+// It's used for the metric names of the performancecounter collector and of converted performance logs.
+func SanitizeMetricName(name string) string {
+	return strings.Trim(metricNameReplacer.Replace(strings.ToLower(name)), "_")
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package pdh
+
+import "sync"
+
+// NameTranslator translates localized object and counter names to English, as expected by AddEnglishCounter.
+// It is implemented by registry.NameMapping on top of the counter name tables.
+type NameTranslator interface {
+	// English returns the English name of a localized name. Other names are returned as is.
+	English(name string) string
+}
+
+//nolint:gochecknoglobals
+var (
+	nameTranslatorMu sync.RWMutex
+	nameTranslator   NameTranslator = englishNames{}
+)
+
+// SetNameTranslator replaces the translator, which resolves localized names given to NewQuerySource,
+// and returns the previous one. By default, names are expected in English.
+func SetNameTranslator(translator NameTranslator) NameTranslator {
+	nameTranslatorMu.Lock()
+	defer nameTranslatorMu.Unlock()
+
+	previous := nameTranslator
+	nameTranslator = translator
+
+	return previous
+}
+
+// EnglishName returns the English name of an object or counter, see SetNameTranslator.
+func EnglishName(name string) string {
+	nameTranslatorMu.RLock()
+	defer nameTranslatorMu.RUnlock()
+
+	return nameTranslator.English(name)
+}
+
+// englishNames is the translator of English hosts.
+type englishNames struct{}
+
+func (englishNames) English(name string) string {
+	return name
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package pdh
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NewQuerySource is not supported on other platforms. Collectors can only replay recorded counters there,
+// see ReplaySourceFactory.
+func NewQuerySource(string, []string, []string) (Source, error) {
+	return nil, fmt.Errorf("performance counters can only be queried on Windows: %w", errors.ErrUnsupported)
+}
+
+func FormatError(msgID uint32) string {
+	return fmt.Sprintf("(pdhErr=%d)", msgID)
+}
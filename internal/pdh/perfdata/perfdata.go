@@ -0,0 +1,143 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package perfdata selects the backend, which reads the performance counters of a collector.
+package perfdata
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/registry"
+)
+
+const (
+	// BackendPDH reads the counters by the Performance Data Helper API.
+	BackendPDH = "pdh"
+	// BackendRegistry reads the counters from the perflib data blocks of HKEY_PERFORMANCE_DATA.
+	BackendRegistry = "registry"
+	// BackendAuto reads the counters by PDH and falls back to the registry, if PDH does not know the object,
+	// e.g. on hosts with corrupt counter registrations.
+	BackendAuto = "auto"
+)
+
+//nolint:gochecknoglobals
+var (
+	Backends = []string{BackendAuto, BackendPDH, BackendRegistry}
+
+	defaultFactories = Factories{
+		PDH:      newPDHCollector,
+		Registry: newRegistryCollector,
+	}
+)
+
+// Collector is implemented by the collectors of both backends, pdh.Collector and registry.Collector.
+type Collector interface {
+	// Describe returns the explain texts of the counters by counter name, if supported by the backend.
+	Describe() map[string]string
+	// Collect fills dst, a pointer to a slice of structs with perfdata tagged fields, with one element per instance.
+	Collect(dst any) error
+	Close()
+}
+
+// Factory creates a collector for the perfdata tagged fields of valueType.
+type Factory func(object string, instances []string, valueType reflect.Type) (Collector, error)
+
+// Factories are the collector factories of the backends.
+type Factories struct {
+	PDH      Factory
+	Registry Factory
+}
+
+// NewCollector creates a collector for the object with the given backend, see Backends.
+func NewCollector[T any](backend, object string, instances []string) (Collector, error) {
+	return defaultFactories.NewCollector(backend, object, instances, reflect.TypeFor[T]())
+}
+
+// NewCollector creates a collector for the object with the given backend.
+// An empty backend is treated as BackendAuto.
+//
+// Like pdh.NewCollectorWithReflection, a collector may be returned alongside an error,
+// if some counters could not be added. The returned collector is never nil. If no backend could create one,
+// it reports pdh.ErrPerformanceCounterNotInitialized on collect, like a nil *pdh.Collector does.
+func (f Factories) NewCollector(backend, object string, instances []string, valueType reflect.Type) (Collector, error) {
+	collector, err := f.newCollector(backend, object, instances, valueType)
+	if collector == nil {
+		collector = notInitialized{}
+	}
+
+	return collector, err
+}
+
+func (f Factories) newCollector(backend, object string, instances []string, valueType reflect.Type) (Collector, error) {
+	switch backend {
+	case BackendPDH:
+		return f.PDH(object, instances, valueType)
+	case BackendRegistry:
+		return f.Registry(object, instances, valueType)
+	case BackendAuto, "":
+		collector, err := f.PDH(object, instances, valueType)
+		if !errors.Is(err, pdh.NewPdhError(pdh.CstatusNoObject)) {
+			return collector, err
+		}
+
+		if collector != nil {
+			collector.Close()
+		}
+
+		collector, fallbackErr := f.Registry(object, instances, valueType)
+		if fallbackErr != nil {
+			return collector, fmt.Errorf("%w; fallback to registry: %w", err, fallbackErr)
+		}
+
+		return collector, nil
+	default:
+		return nil, fmt.Errorf("unknown performance counter backend %q, expected one of %s", backend, strings.Join(Backends, ", "))
+	}
+}
+
+func newPDHCollector(object string, instances []string, valueType reflect.Type) (Collector, error) {
+	collector, err := pdh.NewCollectorWithReflection(object, instances, valueType)
+	if collector == nil {
+		// Avoid a non-nil interface holding a nil pointer.
+		return nil, err
+	}
+
+	return collector, err
+}
+
+func newRegistryCollector(object string, instances []string, valueType reflect.Type) (Collector, error) {
+	collector, err := registry.NewCollectorWithReflection(registry.PerformanceDataSource{}, object, instances, valueType)
+	if collector == nil {
+		return nil, err
+	}
+
+	return collector, err
+}
+
+// notInitialized stands in for the collector of a failed backend,
+// since collectors are collected and closed even if they failed to build.
+type notInitialized struct{}
+
+func (notInitialized) Describe() map[string]string {
+	return map[string]string{}
+}
+
+func (notInitialized) Collect(any) error {
+	return pdh.ErrPerformanceCounterNotInitialized
+}
+
+func (notInitialized) Close() {}
@@ -0,0 +1,183 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perfdata_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perfdata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type values struct {
+	Name string
+
+	Value float64 `perfdata:"Value"`
+}
+
+type fakeCollector struct {
+	backend string
+	closed  bool
+}
+
+func (c *fakeCollector) Describe() map[string]string { return map[string]string{} }
+func (c *fakeCollector) Collect(any) error           { return nil }
+func (c *fakeCollector) Close()                      { c.closed = true }
+
+// fakeBackend records its calls and returns a collector alongside err, like pdh.NewCollectorWithReflection.
+type fakeBackend struct {
+	name      string
+	err       error
+	collector *fakeCollector
+	calls     int
+}
+
+func (b *fakeBackend) factory(object string, _ []string, valueType reflect.Type) (perfdata.Collector, error) {
+	b.calls++
+
+	if object != "Test" || valueType != reflect.TypeFor[values]() {
+		return nil, errors.New("unexpected arguments")
+	}
+
+	b.collector = &fakeCollector{backend: b.name}
+
+	return b.collector, b.err
+}
+
+func TestNewCollector(t *testing.T) {
+	t.Parallel()
+
+	errNoObject := pdh.NewPdhError(pdh.CstatusNoObject)
+	errNoCounter := pdh.NewPdhError(pdh.CstatusNoCounter)
+	errRegistry := errors.New("object not found in the name table")
+
+	for _, tc := range []struct {
+		name        string
+		backend     string
+		pdhErr      error
+		registryErr error
+
+		expectedBackend string
+		expectedErr     []error
+		pdhClosed       bool
+		pdhCalls        int
+		registryCalls   int
+		unknownBackend  bool
+	}{
+		{
+			name:            "pdh",
+			backend:         perfdata.BackendPDH,
+			pdhErr:          errNoObject,
+			expectedBackend: perfdata.BackendPDH,
+			expectedErr:     []error{errNoObject},
+			pdhCalls:        1,
+		},
+		{
+			name:            "registry",
+			backend:         perfdata.BackendRegistry,
+			expectedBackend: perfdata.BackendRegistry,
+			registryCalls:   1,
+		},
+		{
+			name:            "auto uses pdh",
+			backend:         perfdata.BackendAuto,
+			expectedBackend: perfdata.BackendPDH,
+			pdhCalls:        1,
+		},
+		{
+			name:            "empty backend is auto",
+			backend:         "",
+			pdhErr:          errNoObject,
+			expectedBackend: perfdata.BackendRegistry,
+			pdhClosed:       true,
+			pdhCalls:        1,
+			registryCalls:   1,
+		},
+		{
+			name:            "auto falls back to registry on missing object",
+			backend:         perfdata.BackendAuto,
+			pdhErr:          errNoObject,
+			expectedBackend: perfdata.BackendRegistry,
+			pdhClosed:       true,
+			pdhCalls:        1,
+			registryCalls:   1,
+		},
+		{
+			name:            "auto keeps pdh on missing counter",
+			backend:         perfdata.BackendAuto,
+			pdhErr:          errNoCounter,
+			expectedBackend: perfdata.BackendPDH,
+			expectedErr:     []error{errNoCounter},
+			pdhCalls:        1,
+		},
+		{
+			name:            "auto reports both errors",
+			backend:         perfdata.BackendAuto,
+			pdhErr:          errNoObject,
+			registryErr:     errRegistry,
+			expectedBackend: perfdata.BackendRegistry,
+			expectedErr:     []error{errNoObject, errRegistry},
+			pdhClosed:       true,
+			pdhCalls:        1,
+			registryCalls:   1,
+		},
+		{
+			name:           "unknown backend",
+			backend:        "wmi",
+			unknownBackend: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pdhBackend := &fakeBackend{name: perfdata.BackendPDH, err: tc.pdhErr}
+			registryBackend := &fakeBackend{name: perfdata.BackendRegistry, err: tc.registryErr}
+
+			factories := perfdata.Factories{PDH: pdhBackend.factory, Registry: registryBackend.factory}
+
+			collector, err := factories.NewCollector(tc.backend, "Test", nil, reflect.TypeFor[values]())
+
+			assert.Equal(t, tc.pdhCalls, pdhBackend.calls)
+			assert.Equal(t, tc.registryCalls, registryBackend.calls)
+
+			if tc.unknownBackend {
+				require.ErrorContains(t, err, `unknown performance counter backend "wmi"`)
+				require.NotNil(t, collector)
+				require.ErrorIs(t, collector.Collect(&[]values{}), pdh.ErrPerformanceCounterNotInitialized)
+				collector.Close()
+
+				return
+			}
+
+			if len(tc.expectedErr) == 0 {
+				require.NoError(t, err)
+			}
+
+			for _, expectedErr := range tc.expectedErr {
+				require.ErrorIs(t, err, expectedErr)
+			}
+
+			require.NotNil(t, collector)
+			assert.Equal(t, tc.expectedBackend, collector.(*fakeCollector).backend)
+
+			if tc.pdhCalls > 0 {
+				assert.Equal(t, tc.pdhClosed, pdhBackend.collector.closed)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package perflog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ConvertBLG converts a binary performance log (.blg) into OpenMetrics.
+// The binary format is undocumented, so the log is converted to PDH-CSV by relog.exe first.
+func ConvertBLG(ctx context.Context, path string, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "windows_exporter_perflog")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	csvPath := filepath.Join(dir, "perflog.csv")
+
+	cmd := exec.CommandContext(ctx, "relog.exe", path, "-f", "csv", "-o", csvPath, "-y")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("relog.exe failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return fmt.Errorf("failed to open output of relog.exe: %w", err)
+	}
+
+	defer f.Close()
+
+	return Convert(f, w)
+}
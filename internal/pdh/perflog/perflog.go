@@ -0,0 +1,305 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package perflog converts performance logs written by perfmon, logman or relog into OpenMetrics,
+// so captures of incidents can be backfilled into Prometheus, e.g. by promtool tsdb create-blocks-from openmetrics.
+package perflog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// metricPrefix is the prefix of the metric names generated by the performancecounter collector.
+	metricPrefix = types.Namespace + "_performancecounter"
+
+	utf8BOM = "\ufeff"
+)
+
+//nolint:gochecknoglobals
+var (
+	// headerBiasRegex matches the time zone bias in minutes of a header like "(PDH-CSV 4.0) (W. Europe Standard Time)(-60)".
+	headerBiasRegex = regexp.MustCompile(`\((-?\d+)\)\s*$`)
+
+	timestampLayouts = []string{"01/02/2006 15:04:05.000", "01/02/2006 15:04:05"}
+)
+
+var ErrInvalidHeader = errors.New("not a PDH-CSV or PDH-TSV performance log")
+
+// CounterPath is a counter path of the form \\computer\object(parent/instance#index)\counter.
+// Computer and instance are optional.
+type CounterPath struct {
+	Computer string
+	Object   string
+	Instance string
+	Counter  string
+}
+
+// ParseCounterPath parses a counter path as written in the header of a performance log.
+func ParseCounterPath(path string) (CounterPath, error) {
+	var counterPath CounterPath
+
+	rest := path
+
+	if computer, ok := strings.CutPrefix(rest, `\\`); ok {
+		i := strings.Index(computer, `\`)
+		if i == -1 {
+			return CounterPath{}, fmt.Errorf("invalid counter path %q", path)
+		}
+
+		counterPath.Computer = computer[:i]
+		rest = computer[i:]
+	}
+
+	rest, ok := strings.CutPrefix(rest, `\`)
+	if !ok {
+		return CounterPath{}, fmt.Errorf("invalid counter path %q", path)
+	}
+
+	if open, closing := strings.Index(rest, "("), strings.LastIndex(rest, `)\`); open != -1 && closing > open {
+		counterPath.Object = rest[:open]
+		counterPath.Instance = rest[open+1 : closing]
+		counterPath.Counter = rest[closing+2:]
+	} else {
+		i := strings.Index(rest, `\`)
+		if i == -1 {
+			return CounterPath{}, fmt.Errorf("invalid counter path %q", path)
+		}
+
+		counterPath.Object = rest[:i]
+		counterPath.Counter = rest[i+1:]
+	}
+
+	if counterPath.Object == "" || counterPath.Counter == "" {
+		return CounterPath{}, fmt.Errorf("invalid counter path %q", path)
+	}
+
+	return counterPath, nil
+}
+
+// MetricName returns the metric name of the counter, as generated by the performancecounter collector.
+func (p CounterPath) MetricName() string {
+	return pdh.SanitizeMetricName(fmt.Sprintf("%s_%s_%s", metricPrefix, p.Object, p.Counter))
+}
+
+// series are the samples of a column of the log.
+type series struct {
+	path    CounterPath
+	labels  []*dto.LabelPair
+	metrics []*dto.Metric
+}
+
+// Convert reads a PDH-CSV or PDH-TSV log, as written by relog -f csv or -f tsv, and writes its samples as OpenMetrics.
+//
+// The logs contain formatted values, e.g. rates per second instead of raw counters, so all metrics are gauges.
+// Timestamps are converted to UTC using the time zone bias of the header. Empty values are skipped.
+func Convert(r io.Reader, w io.Writer) error {
+	columns, err := read(r)
+	if err != nil {
+		return err
+	}
+
+	families := map[string]*dto.MetricFamily{}
+
+	for _, column := range columns {
+		if len(column.metrics) == 0 {
+			continue
+		}
+
+		name := column.path.MetricName()
+
+		family, ok := families[name]
+		if !ok {
+			family = &dto.MetricFamily{
+				Name: ptr(name),
+				Help: ptr(fmt.Sprintf("windows_exporter: Performance counter \\%s\\%s converted from a performance log.", column.path.Object, column.path.Counter)),
+				Type: dto.MetricType_GAUGE.Enum(),
+			}
+			families[name] = family
+		}
+
+		family.Metric = append(family.Metric, column.metrics...)
+	}
+
+	for _, name := range slices.Sorted(func(yield func(string) bool) {
+		for name := range families {
+			if !yield(name) {
+				return
+			}
+		}
+	}) {
+		if _, err := expfmt.MetricFamilyToOpenMetrics(w, families[name]); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	if _, err := expfmt.FinalizeOpenMetrics(w); err != nil {
+		return fmt.Errorf("failed to write EOF: %w", err)
+	}
+
+	return nil
+}
+
+// read parses the log into one series per column, ordered by metric name and labels.
+func read(r io.Reader) ([]*series, error) {
+	br := bufio.NewReader(r)
+
+	if bom, _ := br.Peek(len(utf8BOM)); string(bom) == utf8BOM {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+
+	// The delimiter is determined by the first cell of the header.
+	peek, err := br.Peek(len(`"(PDH-TSV`))
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+
+	reader := csv.NewReader(br)
+	reader.ReuseRecord = true
+
+	switch format := strings.TrimPrefix(string(peek), `"`); {
+	case strings.HasPrefix(format, "(PDH-CSV"):
+	case strings.HasPrefix(format, "(PDH-TSV"):
+		reader.Comma = '\t'
+	default:
+		return nil, ErrInvalidHeader
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	header = slices.Clone(header)
+
+	location := time.UTC
+
+	if match := headerBiasRegex.FindStringSubmatch(header[0]); match != nil {
+		bias, _ := strconv.Atoi(match[1])
+		// The bias is the difference between UTC and local time in minutes, i.e. UTC = local time + bias.
+		location = time.FixedZone("", -bias*60)
+	}
+
+	columns := make([]*series, 0, len(header)-1)
+
+	for _, cell := range header[1:] {
+		path, err := ParseCounterPath(cell)
+		if err != nil {
+			return nil, err
+		}
+
+		column := &series{path: path}
+
+		if path.Computer != "" {
+			column.labels = append(column.labels, &dto.LabelPair{Name: ptr("host"), Value: ptr(path.Computer)})
+		}
+
+		if path.Instance != "" {
+			column.labels = append(column.labels, &dto.LabelPair{Name: ptr("instance"), Value: ptr(path.Instance)})
+		}
+
+		columns = append(columns, column)
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sample: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		timestamp, err := parseTimestamp(record[0], location)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		for i, cell := range record[1:] {
+			if i >= len(columns) {
+				break
+			}
+
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+
+			value, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value of %s: %w", line, header[i+1], err)
+			}
+
+			columns[i].metrics = append(columns[i].metrics, &dto.Metric{
+				Label:       columns[i].labels,
+				Gauge:       &dto.Gauge{Value: ptr(value)},
+				TimestampMs: ptr(timestamp.UnixMilli()),
+			})
+		}
+	}
+
+	slices.SortStableFunc(columns, func(a, b *series) int {
+		if c := strings.Compare(a.path.MetricName(), b.path.MetricName()); c != 0 {
+			return c
+		}
+
+		return strings.Compare(labelsString(a.labels), labelsString(b.labels))
+	})
+
+	return columns, nil
+}
+
+func parseTimestamp(value string, location *time.Location) (time.Time, error) {
+	var err error
+
+	for _, layout := range timestampLayouts {
+		var timestamp time.Time
+
+		if timestamp, err = time.ParseInLocation(layout, value, location); err == nil {
+			return timestamp, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: %w", value, err)
+}
+
+func labelsString(labels []*dto.LabelPair) string {
+	var sb strings.Builder
+
+	for _, label := range labels {
+		sb.WriteString(label.GetName() + "=" + label.GetValue() + "\x00")
+	}
+
+	return sb.String()
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package perflog_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh/perflog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCounterPath(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		path     string
+		expected perflog.CounterPath
+		err      bool
+	}{
+		{
+			path:     `\\SRV01\Processor(_Total)\% Processor Time`,
+			expected: perflog.CounterPath{Computer: "SRV01", Object: "Processor", Instance: "_Total", Counter: "% Processor Time"},
+		},
+		{
+			path:     `\\SRV01\Memory\Available MBytes`,
+			expected: perflog.CounterPath{Computer: "SRV01", Object: "Memory", Counter: "Available MBytes"},
+		},
+		{
+			path:     `\Thread(w3wp/12#1)\Context Switches/sec`,
+			expected: perflog.CounterPath{Object: "Thread", Instance: "w3wp/12#1", Counter: "Context Switches/sec"},
+		},
+		{
+			path:     `\\SRV01\Network Interface(Intel(R) Ethernet)\Bytes Total/sec`,
+			expected: perflog.CounterPath{Computer: "SRV01", Object: "Network Interface", Instance: "Intel(R) Ethernet", Counter: "Bytes Total/sec"},
+		},
+		{path: `Processor\% Processor Time`, err: true},
+		{path: `\\SRV01`, err: true},
+		{path: `\Memory`, err: true},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			path, err := perflog.ParseCounterPath(tc.path)
+			if tc.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, path)
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	expected, err := os.ReadFile(filepath.Join("testdata", "incident.om"))
+	require.NoError(t, err)
+
+	for _, name := range []string{"incident.csv", "incident.tsv"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := os.Open(filepath.Join("testdata", name))
+			require.NoError(t, err)
+
+			defer f.Close()
+
+			var buf bytes.Buffer
+
+			require.NoError(t, perflog.Convert(f, &buf))
+			assert.Equal(t, string(expected), buf.String())
+		})
+	}
+}
+
+func TestConvertBOM(t *testing.T) {
+	t.Parallel()
+
+	log := "\ufeff" + `"(PDH-CSV 4.0) (Coordinated Universal Time)(0)","\\SRV01\Memory\Available MBytes"` + "\r\n" +
+		`"01/18/2024 10:00:00","2048"` + "\r\n"
+
+	var buf bytes.Buffer
+
+	require.NoError(t, perflog.Convert(strings.NewReader(log), &buf))
+	assert.Contains(t, buf.String(), `windows_performancecounter_memory_available_mbytes{host="SRV01"} 2048.0 1.705572e+09`)
+}
+
+func TestConvertErrors(t *testing.T) {
+	t.Parallel()
+
+	const header = `"(PDH-CSV 4.0) (Coordinated Universal Time)(0)","\\SRV01\Memory\Available MBytes"` + "\n"
+
+	for _, tc := range []struct {
+		name string
+		log  string
+		err  string
+	}{
+		{name: "empty", log: "", err: perflog.ErrInvalidHeader.Error()},
+		{name: "no header", log: "Time,Value\n", err: perflog.ErrInvalidHeader.Error()},
+		{name: "invalid counter path", log: `"(PDH-CSV 4.0)","Memory"` + "\n", err: `invalid counter path "Memory"`},
+		{name: "invalid timestamp", log: header + `"2024-01-18 10:00:00","2048"` + "\n", err: "line 2: invalid timestamp"},
+		{name: "invalid value", log: header + `"01/18/2024 10:00:00","n/a"` + "\n", err: `line 2: invalid value of \\SRV01\Memory\Available MBytes`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := perflog.Convert(strings.NewReader(tc.log), &bytes.Buffer{})
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package pdh
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// querySource reads the raw counter values from a PDH query.
+type querySource struct {
+	handle   pdhQueryHandle
+	counters []queryCounter
+
+	buf []byte
+}
+
+type queryCounter struct {
+	name        string
+	desc        string
+	counterType uint32
+	frequency   int64
+	instances   map[string]pdhCounterHandle
+}
+
+// NewQuerySource adds the counters of all instances of the object to a new PDH query.
+// Counters, which could not be added, are reported as error alongside the source.
+// Localized names are translated to English, see SetNameTranslator. Samples keep the given counter names.
+func NewQuerySource(object string, counters []string, instances []string) (Source, error) {
+	var handle pdhQueryHandle
+
+	if ret := OpenQuery(0, 0, &handle); ret != ErrorSuccess {
+		return nil, NewPdhError(ret)
+	}
+
+	source := &querySource{
+		handle:   handle,
+		counters: make([]queryCounter, 0, len(counters)),
+		buf:      make([]byte, 1),
+	}
+
+	errs := make([]error, 0, len(counters))
+	englishObject := EnglishName(object)
+
+	for _, counterName := range counters {
+		counter := queryCounter{
+			name:      counterName,
+			instances: make(map[string]pdhCounterHandle, len(instances)),
+		}
+
+		var counterPath string
+
+		for _, instance := range instances {
+			counterPath = formatCounterPath(englishObject, instance, EnglishName(counterName))
+
+			var counterHandle pdhCounterHandle
+
+			if ret := AddEnglishCounter(handle, counterPath, 0, &counterHandle); ret != ErrorSuccess {
+				errs = append(errs, fmt.Errorf("failed to add counter %s: %w", counterPath, NewPdhError(ret)))
+
+				continue
+			}
+
+			counter.instances[instance] = counterHandle
+
+			if counter.counterType != 0 {
+				continue
+			}
+
+			// Get the info with the current buffer size
+			bufLen := uint32(0)
+
+			if ret := GetCounterInfo(counterHandle, 0, &bufLen, nil); ret != MoreData {
+				errs = append(errs, fmt.Errorf("GetCounterInfo: %w", NewPdhError(ret)))
+
+				continue
+			}
+
+			buf := make([]byte, bufLen)
+			if ret := GetCounterInfo(counterHandle, 0, &bufLen, &buf[0]); ret != ErrorSuccess {
+				errs = append(errs, fmt.Errorf("GetCounterInfo: %w", NewPdhError(ret)))
+
+				continue
+			}
+
+			ci := (*CounterInfo)(unsafe.Pointer(&buf[0]))
+			counter.counterType = ci.DwType
+			counter.desc = windows.UTF16PtrToString(ci.SzExplainText)
+
+			if UsesFrequency(counter.counterType) {
+				if ret := GetCounterTimeBase(counterHandle, &counter.frequency); ret != ErrorSuccess && counter.counterType == PERF_ELAPSED_TIME {
+					errs = append(errs, fmt.Errorf("GetCounterTimeBase: %w", NewPdhError(ret)))
+
+					continue
+				}
+			}
+		}
+
+		source.counters = append(source.counters, counter)
+	}
+
+	return source, errors.Join(errs...)
+}
+
+func (s *querySource) Describe() map[string]string {
+	desc := make(map[string]string, len(s.counters))
+
+	for _, counter := range s.counters {
+		desc[counter.name] = counter.desc
+	}
+
+	return desc
+}
+
+func (s *querySource) Collect() ([]Sample, error) {
+	var (
+		itemCount   uint32
+		items       []RawCounterItem
+		bytesNeeded uint32
+	)
+
+	if ret := CollectQueryData(s.handle); ret != ErrorSuccess {
+		return nil, fmt.Errorf("failed to collect query data: %w", NewPdhError(ret))
+	}
+
+	samples := make([]Sample, 0, len(s.counters))
+	stringMap := map[*uint16]string{}
+
+	// staleStatus is the status of the items, which were skipped since their counter is gone.
+	var staleStatus uint32
+
+	for _, counter := range s.counters {
+		for _, instance := range counter.instances {
+			// Get the info with the current buffer size
+			bytesNeeded = uint32(cap(s.buf))
+
+			for {
+				ret := GetRawCounterArray(instance, &bytesNeeded, &itemCount, &s.buf[0])
+
+				if ret == ErrorSuccess {
+					break
+				}
+
+				if err := NewPdhError(ret); ret != MoreData && !isKnownCounterDataError(err) {
+					return nil, fmt.Errorf("GetRawCounterArray: %w", err)
+				}
+
+				if bytesNeeded <= uint32(cap(s.buf)) {
+					return nil, fmt.Errorf("GetRawCounterArray reports buffer too small (%d), but buffer is large enough (%d): %w", uint32(cap(s.buf)), bytesNeeded, NewPdhError(ret))
+				}
+
+				s.buf = make([]byte, bytesNeeded)
+			}
+
+			items = unsafe.Slice((*RawCounterItem)(unsafe.Pointer(&s.buf[0])), itemCount)
+
+			for _, item := range items {
+				if item.RawValue.CStatus != CstatusValidData && item.RawValue.CStatus != CstatusNewData {
+					if isStaleQueryError(NewPdhError(item.RawValue.CStatus)) {
+						staleStatus = item.RawValue.CStatus
+					}
+
+					continue
+				}
+
+				instanceName, ok := stringMap[item.SzName]
+				if !ok {
+					instanceName = windows.UTF16PtrToString(item.SzName)
+					stringMap[item.SzName] = instanceName
+				}
+
+				samples = append(samples, Sample{
+					Counter:     counter.name,
+					Instance:    instanceName,
+					Type:        counter.counterType,
+					FirstValue:  item.RawValue.FirstValue,
+					SecondValue: item.RawValue.SecondValue,
+					Frequency:   counter.frequency,
+				})
+			}
+		}
+	}
+
+	if len(samples) == 0 && staleStatus != 0 {
+		return nil, fmt.Errorf("counter status: %w", NewPdhError(staleStatus))
+	}
+
+	return samples, nil
+}
+
+func (s *querySource) Close() {
+	CloseQuery(s.handle)
+
+	s.handle = 0
+}
+
+func formatCounterPath(object, instance, counterName string) string {
+	var counterPath string
+
+	if instance == InstanceEmpty {
+		counterPath = fmt.Sprintf(`\%s\%s`, object, counterName)
+	} else {
+		counterPath = fmt.Sprintf(`\%s(%s)\%s`, object, instance, counterName)
+	}
+
+	return counterPath
+}
+
+func isKnownCounterDataError(err error) bool {
+	var pdhErr *Error
+
+	return errors.As(err, &pdhErr) && (pdhErr.ErrorCode == InvalidData ||
+		pdhErr.ErrorCode == CalcNegativeDenominator ||
+		pdhErr.ErrorCode == CalcNegativeValue ||
+		pdhErr.ErrorCode == CstatusInvalidData ||
+		pdhErr.ErrorCode == CstatusNoInstance ||
+		pdhErr.ErrorCode == NoData)
+}
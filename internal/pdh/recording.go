@@ -0,0 +1,208 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sync"
+)
+
+//nolint:gochecknoglobals
+var recordingFileNameReplacer = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// OriginSynthetic marks handcrafted fixtures, whose values weren't captured on a host.
+const OriginSynthetic = "synthetic"
+
+// Recording holds the raw counter values of an object, one snapshot per collection.
+type Recording struct {
+	Object string `json:"object"`
+	// Origin describes where the values come from. Recordings of RecordSourceFactory state the platform
+	// of the recording host, handcrafted fixtures are marked as OriginSynthetic.
+	Origin       string            `json:"origin,omitempty"`
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+	Snapshots    [][]Sample        `json:"snapshots"`
+}
+
+// RecordingFile returns the path of the recording of an object in dir.
+func RecordingFile(dir, object string) string {
+	return filepath.Join(dir, recordingFileNameReplacer.ReplaceAllString(object, "_")+".json")
+}
+
+// LoadRecording reads a recording written by RecordSourceFactory.
+func LoadRecording(file string) (*Recording, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var recording Recording
+
+	if err := json.Unmarshal(data, &recording); err != nil {
+		return nil, fmt.Errorf("failed to parse recording %s: %w", file, err)
+	}
+
+	return &recording, nil
+}
+
+// RecordSourceFactory wraps a factory and writes the raw counter values of each collection to a
+// recording in dir. The recording is rewritten after each collection.
+func RecordSourceFactory(dir string, factory SourceFactory) SourceFactory {
+	return func(object string, counters []string, instances []string) (Source, error) {
+		source, err := factory(object, counters, instances)
+		if source == nil {
+			return nil, err
+		}
+
+		return &recordSource{
+			Source: source,
+			file:   RecordingFile(dir, object),
+			recording: Recording{
+				Object:       object,
+				Origin:       "recorded on " + runtime.GOOS + "/" + runtime.GOARCH,
+				Descriptions: source.Describe(),
+			},
+		}, err
+	}
+}
+
+type recordSource struct {
+	Source
+
+	file string
+
+	mu        sync.Mutex
+	recording Recording
+}
+
+func (s *recordSource) Collect() ([]Sample, error) {
+	samples, err := s.Source.Collect()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recording.Snapshots = append(s.recording.Snapshots, samples)
+
+	data, err := json.MarshalIndent(s.recording, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode recording: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.file), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	if err := os.WriteFile(s.file, append(data, '\n'), 0o644); err != nil { //nolint:gosec
+		return nil, fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	return samples, nil
+}
+
+// ReplaySourceFactory replays the recordings in dir. Each collection returns the next snapshot, the last
+// snapshot is repeated. Objects without recording are reported like missing objects, i.e. CstatusNoObject.
+func ReplaySourceFactory(dir string) SourceFactory {
+	return func(object string, counters []string, _ []string) (Source, error) {
+		recording, err := LoadRecording(RecordingFile(dir, object))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, NewPdhError(CstatusNoObject)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return NewReplaySource(recording, counters), nil
+	}
+}
+
+// ReplaySource returns the snapshots of a recording.
+type ReplaySource struct {
+	recording *Recording
+	counters  map[string]struct{}
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewReplaySource replays the samples of the given counters. All counters are replayed, if counters is empty.
+func NewReplaySource(recording *Recording, counters []string) *ReplaySource {
+	source := &ReplaySource{
+		recording: recording,
+		counters:  make(map[string]struct{}, len(counters)),
+	}
+
+	for _, counter := range counters {
+		source.counters[counter] = struct{}{}
+	}
+
+	return source
+}
+
+func (s *ReplaySource) Describe() map[string]string {
+	desc := make(map[string]string, len(s.recording.Descriptions))
+
+	for counter, text := range s.recording.Descriptions {
+		if s.isReplayed(counter) {
+			desc[counter] = text
+		}
+	}
+
+	return desc
+}
+
+func (s *ReplaySource) Collect() ([]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.recording.Snapshots) == 0 {
+		return nil, ErrNoData
+	}
+
+	snapshot := s.recording.Snapshots[s.next]
+
+	if s.next < len(s.recording.Snapshots)-1 {
+		s.next++
+	}
+
+	samples := make([]Sample, 0, len(snapshot))
+
+	for _, sample := range snapshot {
+		if s.isReplayed(sample.Counter) {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples, nil
+}
+
+func (s *ReplaySource) Close() {}
+
+func (s *ReplaySource) isReplayed(counter string) bool {
+	if len(s.counters) == 0 {
+		return true
+	}
+
+	_, ok := s.counters[counter]
+
+	return ok
+}
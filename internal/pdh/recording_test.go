@@ -0,0 +1,146 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdh
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource returns a fixed set of samples per collection.
+type fakeSource struct {
+	snapshots [][]Sample
+	next      int
+	closed    bool
+}
+
+func (s *fakeSource) Describe() map[string]string {
+	return map[string]string{"% Processor Time": "% Processor Time is the percentage of elapsed time that the processor spends to execute a non-Idle thread."}
+}
+
+func (s *fakeSource) Collect() ([]Sample, error) {
+	snapshot := s.snapshots[s.next]
+	s.next++
+
+	return snapshot, nil
+}
+
+func (s *fakeSource) Close() { s.closed = true }
+
+type processorValues struct {
+	Name string
+
+	ProcessorTime              float64 `perfdata:"% Processor Time"`
+	ProcessorPerformance       float64 `perfdata:"% Processor Performance"`
+	ProcessorPerformanceSecond float64 `perfdata:"% Processor Performance,secondvalue"`
+	ElapsedTime                float64 `perfdata:"Elapsed Time"`
+}
+
+func processorSnapshot(processorTime int64) []Sample {
+	return []Sample{
+		{Counter: "% Processor Time", Instance: "0,0", Type: PERF_100NSEC_TIMER, FirstValue: processorTime},
+		{Counter: "% Processor Time", Instance: "0,1", Type: PERF_100NSEC_TIMER, FirstValue: processorTime / 2},
+		{Counter: "% Processor Time", Instance: "_Total", Type: PERF_100NSEC_TIMER, FirstValue: processorTime * 3 / 2},
+		{Counter: "% Processor Performance", Instance: "0,0", Type: PERF_AVERAGE_BULK, FirstValue: 12000, SecondValue: 100},
+		{Counter: "Elapsed Time", Instance: "0,0", Type: PERF_ELAPSED_TIME, FirstValue: 133500000000000000, Frequency: 10000000},
+		{Counter: "Unknown", Instance: "0,0", Type: PERF_COUNTER_RAWCOUNT, FirstValue: 1},
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	live := &fakeSource{snapshots: [][]Sample{processorSnapshot(20000000), processorSnapshot(40000000)}}
+
+	record := RecordSourceFactory(dir, func(object string, counters []string, instances []string) (Source, error) {
+		assert.Equal(t, "Processor Information", object)
+		assert.Equal(t, []string{"% Processor Time", "% Processor Performance", "Elapsed Time"}, counters)
+		assert.Equal(t, InstancesAll, instances)
+
+		return live, nil
+	})
+
+	previous := SetSourceFactory(record)
+
+	collector, err := NewCollector[processorValues]("Processor Information", InstancesAll)
+	require.NoError(t, err)
+
+	var recorded []processorValues
+
+	require.NoError(t, collector.Collect(&recorded))
+	collector.Close()
+
+	assert.True(t, live.closed)
+
+	recording, err := LoadRecording(RecordingFile(dir, "Processor Information"))
+	require.NoError(t, err)
+	assert.Equal(t, "Processor Information", recording.Object)
+	assert.Equal(t, "recorded on "+runtime.GOOS+"/"+runtime.GOARCH, recording.Origin)
+	assert.Len(t, recording.Snapshots, 2)
+	assert.Equal(t, live.Describe(), recording.Descriptions)
+
+	// The replayed collector derives the same values.
+	SetSourceFactory(ReplaySourceFactory(dir))
+
+	defer SetSourceFactory(previous)
+
+	collector, err = NewCollector[processorValues]("Processor Information", InstancesAll)
+	require.NoError(t, err)
+
+	defer collector.Close()
+
+	var replayed []processorValues
+
+	require.NoError(t, collector.Collect(&replayed))
+	assert.Equal(t, recorded, replayed)
+	assert.Equal(t, live.Describe(), collector.Describe())
+
+	require.Len(t, replayed, 2)
+	assert.Equal(t, processorValues{
+		Name:                       "0,0",
+		ProcessorTime:              4,
+		ProcessorPerformance:       12000,
+		ProcessorPerformanceSecond: 100,
+		ElapsedTime:                1705526400,
+	}, replayed[0])
+	assert.Equal(t, "0,1", replayed[1].Name)
+
+	// The last snapshot is repeated.
+	require.NoError(t, collector.Collect(&replayed))
+	assert.Equal(t, recorded, replayed)
+
+	// Objects without recording are reported as missing.
+	_, err = NewCollector[processorValues]("Processor", InstancesAll)
+	require.ErrorIs(t, err, NewPdhError(CstatusNoObject))
+}
+
+func TestReplaySource(t *testing.T) {
+	t.Parallel()
+
+	recording := &Recording{
+		Object:    "Processor Information",
+		Snapshots: [][]Sample{processorSnapshot(20000000)},
+	}
+
+	source := NewReplaySource(recording, []string{"% Processor Time"})
+
+	samples, err := source.Collect()
+	require.NoError(t, err)
+	assert.Len(t, samples, 3)
+
+	_, err = NewReplaySource(&Recording{}, nil).Collect()
+	require.ErrorIs(t, err, ErrNoData)
+}
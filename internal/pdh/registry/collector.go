@@ -3,6 +3,8 @@ package registry
 import (
 	"fmt"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/prometheus-community/windows_exporter/internal/mi"
@@ -10,9 +12,12 @@ import (
 )
 
 type Collector struct {
+	source DataSource
 	object string
 	query  string
 
+	totalCounterRequested bool
+
 	counters       map[string]Counter
 	nameIndexValue int
 }
@@ -28,16 +33,36 @@ type Counter struct {
 	FieldIndexSecondValue int
 }
 
-func NewCollector[T any](object string, _ []string) (*Collector, error) {
-	collector := &Collector{
-		object:         object,
-		query:          MapCounterToIndex(object),
-		nameIndexValue: -1,
-		counters:       make(map[string]Counter),
+func NewCollector[T any](object string, instances []string) (*Collector, error) {
+	return NewCollectorWithSource[T](PerformanceDataSource{}, object, instances)
+}
+
+// NewCollectorWithSource creates a collector, which reads the data blocks from the given source,
+// e.g. a ReplaySource with recorded data blocks.
+func NewCollectorWithSource[T any](source DataSource, object string, instances []string) (*Collector, error) {
+	return NewCollectorWithReflection(source, object, instances, reflect.TypeFor[T]())
+}
+
+// NewCollectorWithReflection creates a collector for the perfdata tagged fields of valueType,
+// like pdh.NewCollectorWithReflection.
+// The object and counters may be given by their English or localized names, see DataSource.LocalNames.
+func NewCollectorWithReflection(source DataSource, object string, instances []string, valueType reflect.Type) (*Collector, error) {
+	names := source.LocalNames()
+	object = names.English(object)
+
+	index := source.NameTable().LookupIndex(object)
+	if index == 0 {
+		return nil, fmt.Errorf("performance counter object %q not found in the name table", object)
 	}
 
-	var values [0]T
-	valueType := reflect.TypeOf(values).Elem()
+	collector := &Collector{
+		source:                source,
+		object:                object,
+		query:                 strconv.Itoa(int(index)),
+		totalCounterRequested: slices.Contains(instances, pdh.InstanceTotal),
+		nameIndexValue:        -1,
+		counters:              make(map[string]Counter),
+	}
 
 	if f, ok := valueType.FieldByName("Name"); ok {
 		if f.Type.Kind() == reflect.String {
@@ -51,6 +76,9 @@ func NewCollector[T any](object string, _ []string) (*Collector, error) {
 			continue
 		}
 
+		isSecondValue := strings.HasSuffix(counterName, ",secondvalue")
+		counterName = names.English(strings.TrimSuffix(counterName, ",secondvalue"))
+
 		var counter Counter
 		if counter, ok = collector.counters[counterName]; !ok {
 			counter = Counter{
@@ -60,9 +88,7 @@ func NewCollector[T any](object string, _ []string) (*Collector, error) {
 			}
 		}
 
-		if strings.HasSuffix(counterName, ",secondvalue") {
-			counterName = strings.TrimSuffix(counterName, ",secondvalue")
-
+		if isSecondValue {
 			counter.FieldIndexSecondValue = f.Index[0]
 		} else {
 			counter.FieldIndexValue = f.Index[0]
@@ -71,9 +97,9 @@ func NewCollector[T any](object string, _ []string) (*Collector, error) {
 		collector.counters[counterName] = counter
 	}
 
-	var collectValues []T
+	collectValues := reflect.New(reflect.SliceOf(valueType)).Elem()
 
-	if err := collector.Collect(&collectValues); err != nil {
+	if err := collector.Collect(collectValues.Addr().Interface()); err != nil {
 		return nil, fmt.Errorf("failed to collect initial data: %w", err)
 	}
 
@@ -99,7 +125,7 @@ func (c *Collector) Collect(data any) error {
 		return mi.ErrInvalidEntityType
 	}
 
-	perfObjects, err := QueryPerformanceData(c.query, c.object)
+	perfObjects, err := queryPerformanceData(c.source, c.query, c.object)
 	if err != nil {
 		return fmt.Errorf("QueryPerformanceData: %w", err)
 	}
@@ -121,7 +147,7 @@ func (c *Collector) Collect(data any) error {
 
 		for _, perfInstance := range perfObject.Instances {
 			instanceName := perfInstance.Name
-			if strings.HasSuffix(instanceName, "_Total") {
+			if strings.HasSuffix(instanceName, pdh.InstanceTotal) && !c.totalCounterRequested {
 				continue
 			}
 
@@ -137,7 +163,8 @@ func (c *Collector) Collect(data any) error {
 			index := dv.Len() - 1
 
 			for _, perfCounter := range perfInstance.Counters {
-				if perfCounter.Def.IsBaseValue && !perfCounter.Def.IsNanosecondCounter {
+				// Base counters are reported as second value of the counter before them.
+				if perfCounter.Def.IsBaseValue {
 					continue
 				}
 
@@ -146,16 +173,11 @@ func (c *Collector) Collect(data any) error {
 					continue
 				}
 
-				switch perfCounter.Def.CounterType {
-				case pdh.PERF_ELAPSED_TIME:
-					dv.Index(index).
-						Field(counter.FieldIndexValue).
-						SetFloat(float64((perfCounter.Value - pdh.WindowsEpoch) / perfObject.Frequency))
-				case pdh.PERF_100NSEC_TIMER, pdh.PERF_PRECISION_100NS_TIMER:
+				if value, ok := pdh.DerivedValue(perfCounter.Def.CounterType, perfCounter.Value, perfObject.Frequency); ok {
 					dv.Index(index).
 						Field(counter.FieldIndexValue).
-						SetFloat(float64(perfCounter.Value) * pdh.TicksToSecondScaleFactor)
-				default:
+						SetFloat(value)
+				} else {
 					if counter.FieldIndexSecondValue != -1 {
 						dv.Index(index).
 							Field(counter.FieldIndexSecondValue).
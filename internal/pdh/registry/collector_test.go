@@ -0,0 +1,146 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type processValues struct {
+	Name string
+
+	PercentProcessorTime float64 `perfdata:"% Processor Time"`
+	ElapsedTime          float64 `perfdata:"Elapsed Time"`
+	IDProcess            float64 `perfdata:"ID Process"`
+	WorkingSet           float64 `perfdata:"Working Set"`
+}
+
+func TestCollectorWithSource(t *testing.T) {
+	t.Parallel()
+
+	source, err := LoadReplaySource(
+		filepath.Join("testdata", "counter_009.bin"),
+		filepath.Join("testdata", "process_1.blob"),
+		filepath.Join("testdata", "process_2.blob"),
+	)
+	require.NoError(t, err)
+
+	// The initial collection consumes the first data block.
+	collector, err := NewCollectorWithSource[processValues](source, "Process", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "230", collector.query)
+
+	var values []processValues
+
+	require.NoError(t, collector.Collect(&values))
+
+	// The _Total instance is skipped.
+	require.Len(t, values, 4)
+	assert.Equal(t, "explorer", values[3].Name)
+	assert.Equal(t, 5012.0, values[3].IDProcess)
+	assert.Equal(t, 104857600.0, values[3].WorkingSet)
+	assert.InDelta(t, 0.3, values[3].PercentProcessorTime, 1e-9)
+	assert.Equal(t, 1705533600.0, values[3].ElapsedTime)
+}
+
+func TestCollectorWithSourceTotal(t *testing.T) {
+	t.Parallel()
+
+	source, err := LoadReplaySource(
+		filepath.Join("testdata", "counter_009.bin"),
+		filepath.Join("testdata", "process_1.blob"),
+		filepath.Join("testdata", "process_2.blob"),
+	)
+	require.NoError(t, err)
+
+	collector, err := NewCollectorWithSource[processValues](source, "Process", []string{"_Total"})
+	require.NoError(t, err)
+
+	var values []processValues
+
+	require.NoError(t, collector.Collect(&values))
+	require.Len(t, values, 5)
+	assert.True(t, slices.ContainsFunc(values, func(v processValues) bool { return v.Name == "_Total" }))
+}
+
+func TestCollectorWithSourceUnknownObject(t *testing.T) {
+	t.Parallel()
+
+	source, err := LoadReplaySource(filepath.Join("testdata", "counter_009.bin"))
+	require.NoError(t, err)
+
+	_, err = NewCollectorWithSource[processValues](source, "Unknown Object", nil)
+	require.ErrorContains(t, err, `performance counter object "Unknown Object" not found`)
+}
+
+type processValuesGerman struct {
+	Name string
+
+	PercentProcessorTime float64 `perfdata:"Prozessorzeit (%)"`
+	IDProcess            float64 `perfdata:"Prozesskennung"`
+	WorkingSet           float64 `perfdata:"Working Set"`
+}
+
+func TestCollectorWithSourceLocalized(t *testing.T) {
+	t.Parallel()
+
+	source, err := LoadReplaySource(
+		filepath.Join("testdata", "counter_009.bin"),
+		filepath.Join("testdata", "process_1.blob"),
+		filepath.Join("testdata", "process_2.blob"),
+	)
+	require.NoError(t, err)
+
+	german, err := os.ReadFile(filepath.Join("testdata", "counter_007.bin"))
+	require.NoError(t, err)
+
+	source.WithLocalNameTable(NewNameTable(german))
+
+	// Localized and English names may be mixed.
+	collector, err := NewCollectorWithSource[processValuesGerman](source, "Prozess", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "230", collector.query)
+
+	var values []processValuesGerman
+
+	require.NoError(t, collector.Collect(&values))
+	require.Len(t, values, 4)
+	assert.Equal(t, "explorer", values[3].Name)
+	assert.Equal(t, 5012.0, values[3].IDProcess)
+	assert.Equal(t, 104857600.0, values[3].WorkingSet)
+	assert.InDelta(t, 0.3, values[3].PercentProcessorTime, 1e-9)
+}
+
+type logicalDiskValues struct {
+	Name string
+
+	FreeSpace        float64 `perfdata:"Free Megabytes"`
+	PercentFreeSpace float64 `perfdata:"% Free Space"`
+	TotalSpace       float64 `perfdata:"% Free Space,secondvalue"`
+}
+
+func TestCollectorWithSourceSecondValue(t *testing.T) {
+	t.Parallel()
+
+	source, err := LoadReplaySource(
+		filepath.Join("testdata", "counter_009.bin"),
+		filepath.Join("testdata", "global.blob"),
+	)
+	require.NoError(t, err)
+
+	collector, err := NewCollectorWithSource[logicalDiskValues](source, "LogicalDisk", nil)
+	require.NoError(t, err)
+
+	var values []logicalDiskValues
+
+	require.NoError(t, collector.Collect(&values))
+
+	// The base counter of the fraction is the second value, like in PDH.
+	require.Equal(t, []logicalDiskValues{
+		{Name: "C:", FreeSpace: 81234, PercentFreeSpace: 81234, TotalSpace: 243197},
+	}, values)
+}
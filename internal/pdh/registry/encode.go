@@ -0,0 +1,200 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"unicode/utf16"
+)
+
+// NameTableEntry is an index and its name in a counter name table.
+type NameTableEntry struct {
+	Index uint32
+	Name  string
+}
+
+// CounterData is the definition of a counter encoded by EncodePerformanceData.
+type CounterData struct {
+	NameIndex   uint32
+	CounterType uint32
+	// Size of the value in bytes, either 4 or 8.
+	Size uint32
+}
+
+// InstanceData is an instance of an object and its counter values, in the order of the counter definitions.
+type InstanceData struct {
+	Name   string
+	Values []uint64
+}
+
+// ObjectData is an object encoded by EncodePerformanceData.
+type ObjectData struct {
+	NameIndex uint32
+	Frequency int64
+	Counters  []CounterData
+	// Instances nil for objects without instances, in which case Values are used.
+	Instances []InstanceData
+	Values    []uint64
+}
+
+/*
+EncodeNameTable Encodes a counter name table, as stored in "Counter <language>".
+
+Together with EncodePerformanceData, it allows to replay performance data of any origin via ReplaySource,
+e.g. the samples of a PDH recording.
+*/
+func EncodeNameTable(names []NameTableEntry) []byte {
+	var buf bytes.Buffer
+
+	for _, name := range names {
+		buf.Write(encodeUTF16(strconv.Itoa(int(name.Index))))
+		buf.Write(encodeUTF16(name.Name))
+	}
+
+	// The table is terminated by an empty string.
+	buf.Write([]byte{0, 0})
+
+	return buf.Bytes()
+}
+
+// EncodePerformanceData Encodes the objects with the layout of HKEY_PERFORMANCE_DATA on 64-bit Windows.
+// It's the inverse of ParsePerformanceData.
+func EncodePerformanceData(objects []ObjectData) []byte {
+	systemName := encodeUTF16("DEV")
+	headerSize := uint32(binary.Size(perfDataBlock{}))
+	headerLength := uint32(len(align8(append(make([]byte, headerSize), systemName...))))
+
+	var body bytes.Buffer
+
+	for _, object := range objects {
+		body.Write(encodeObject(object))
+	}
+
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, bo, perfDataBlock{
+		Signature:        [4]uint16{'P', 'E', 'R', 'F'},
+		LittleEndian:     1,
+		Version:          1,
+		Revision:         1,
+		TotalByteLength:  headerLength + uint32(body.Len()),
+		HeaderLength:     headerLength,
+		NumObjectTypes:   uint32(len(objects)),
+		DefaultObject:    238,
+		SystemTime:       systemTime{Year: 2024, Month: 1, DayOfWeek: 1, Day: 1},
+		PerfTime:         1234567890,
+		PerfFreq:         10000000,
+		PerfTime100nSec:  133500000000000000,
+		SystemNameLength: uint32(len(systemName)),
+		SystemNameOffset: headerSize,
+	})
+
+	buf.Write(systemName)
+
+	return append(align8(buf.Bytes()), body.Bytes()...)
+}
+
+func encodeObject(object ObjectData) []byte {
+	var definitions, data bytes.Buffer
+
+	offset := uint32(8)
+
+	for _, counter := range object.Counters {
+		_ = binary.Write(&definitions, bo, perfCounterDefinition{
+			ByteLength:            uint32(perfCounterDefinitionSize),
+			CounterNameTitleIndex: counter.NameIndex,
+			CounterHelpTitleIndex: counter.NameIndex + 1,
+			DetailLevel:           100,
+			CounterType:           counter.CounterType,
+			CounterSize:           counter.Size,
+			CounterOffset:         offset,
+		})
+
+		offset += counter.Size
+	}
+
+	numInstances := int32(-1)
+
+	if object.Instances == nil {
+		data.Write(encodeCounterBlock(object.Counters, object.Values))
+	} else {
+		numInstances = int32(len(object.Instances))
+
+		for _, instance := range object.Instances {
+			name := encodeUTF16(instance.Name)
+			definition := align8(make([]byte, perfInstanceDefinitionSize))
+			definition = align8(append(definition, name...))
+
+			var header bytes.Buffer
+
+			_ = binary.Write(&header, bo, perfInstanceDefinition{
+				ByteLength:           uint32(len(definition)),
+				ParentObjectInstance: 0,
+				UniqueID:             0xFFFFFFFF,
+				NameOffset:           uint32(perfInstanceDefinitionSize),
+				NameLength:           uint32(len(name)),
+			})
+
+			copy(definition, header.Bytes())
+
+			data.Write(definition)
+			data.Write(encodeCounterBlock(object.Counters, instance.Values))
+		}
+	}
+
+	definitionLength := uint32(perfObjectTypeSize) + uint32(definitions.Len())
+
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, bo, perfObjectType{
+		TotalByteLength:      definitionLength + uint32(data.Len()),
+		DefinitionLength:     definitionLength,
+		HeaderLength:         uint32(perfObjectTypeSize),
+		ObjectNameTitleIndex: object.NameIndex,
+		ObjectHelpTitleIndex: object.NameIndex + 1,
+		DetailLevel:          100,
+		NumCounters:          uint32(len(object.Counters)),
+		NumInstances:         numInstances,
+		PerfTime:             1234567890,
+		PerfFreq:             object.Frequency,
+	})
+
+	buf.Write(definitions.Bytes())
+	buf.Write(data.Bytes())
+
+	return buf.Bytes()
+}
+
+func encodeCounterBlock(counters []CounterData, values []uint64) []byte {
+	block := make([]byte, 8)
+
+	for i, counter := range counters {
+		switch counter.Size {
+		case 8:
+			block = bo.AppendUint64(block, values[i])
+		default:
+			block = bo.AppendUint32(block, uint32(values[i]))
+		}
+	}
+
+	block = align8(block)
+	bo.PutUint32(block, uint32(len(block)))
+
+	return block
+}
+
+func encodeUTF16(s string) []byte {
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, bo, append(utf16.Encode([]rune(s)), 0))
+
+	return buf.Bytes()
+}
+
+func align8(b []byte) []byte {
+	for len(b)%8 != 0 {
+		b = append(b, 0)
+	}
+
+	return b
+}
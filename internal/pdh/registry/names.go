@@ -0,0 +1,133 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// EnglishLanguage is the language code of the English name table, which is installed on every host.
+const EnglishLanguage = "009"
+
+// NameMapping maps the English names of objects and counters to the names of another language and back.
+// Both name tables share the indices, e.g. 230 is "Process" in the English and "Prozess" in the German table.
+type NameMapping struct {
+	english   *NameTable
+	localized *NameTable
+}
+
+func NewNameMapping(english, localized *NameTable) *NameMapping {
+	return &NameMapping{
+		english:   english,
+		localized: localized,
+	}
+}
+
+// English returns the English name of a localized name.
+// English names, as well as names unknown to both tables, are returned as is.
+func (m *NameMapping) English(name string) string {
+	if m.english.LookupIndex(name) != 0 {
+		return name
+	}
+
+	index := m.localized.LookupIndex(name)
+	if index == 0 {
+		return name
+	}
+
+	if english := m.english.LookupString(index); english != "" {
+		return english
+	}
+
+	return name
+}
+
+// Localize returns the localized name of an English name.
+// Names, which are not translated to the language, e.g. names of third party objects, are returned as is.
+func (m *NameMapping) Localize(name string) string {
+	index := m.english.LookupIndex(name)
+	if index == 0 {
+		return name
+	}
+
+	if localized := m.localized.LookupString(index); localized != "" {
+		return localized
+	}
+
+	return name
+}
+
+// NameTables loads the counter name table of each language once, on the first lookup.
+type NameTables struct {
+	load func(language string) ([]byte, error)
+
+	mu     sync.Mutex
+	tables map[string]*NameTable
+}
+
+// NewNameTables creates a cache of name tables, which are loaded by load, e.g. from "Counter 007" for German.
+func NewNameTables(load func(language string) ([]byte, error)) *NameTables {
+	return &NameTables{
+		load:   load,
+		tables: make(map[string]*NameTable),
+	}
+}
+
+// Table returns the name table of a language, given as hexadecimal language code, e.g. "007" or "7" for German.
+// The table of an invalid language code is empty, see NameTable.Err.
+func (t *NameTables) Table(language string) *NameTable {
+	language, err := normalizeLanguage(language)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if table, ok := t.tables[language]; ok {
+		return table
+	}
+
+	table := &NameTable{
+		load: func() ([]byte, error) {
+			if err != nil {
+				return nil, err
+			}
+
+			return t.load(language)
+		},
+	}
+
+	t.tables[language] = table
+
+	return table
+}
+
+// Mapping returns the mapping of the English names to the names of a language.
+func (t *NameTables) Mapping(language string) *NameMapping {
+	return NewNameMapping(t.Table(EnglishLanguage), t.Table(language))
+}
+
+// normalizeLanguage formats a language code like the perflib registry keys, i.e. as three uppercase hex digits.
+func normalizeLanguage(language string) (string, error) {
+	code, err := strconv.ParseUint(language, 16, 16)
+	if err != nil {
+		return language, fmt.Errorf("invalid language code %q: %w", language, err)
+	}
+
+	return formatLanguage(code), nil
+}
+
+func formatLanguage(code uint64) string {
+	return fmt.Sprintf("%03X", code)
+}
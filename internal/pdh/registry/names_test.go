@@ -0,0 +1,244 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLocalizedNames Synthetic excerpts of localized counter name tables by language code. They are written by hand
+// after the translations of Windows, not captured on localized hosts, see TestRecordedLocalizedNames.
+// Like on real hosts, not every name is translated, e.g. the French table lacks the WSMan object.
+//
+//nolint:gochecknoglobals
+var testLocalizedNames = map[string][]NameTableEntry{
+	// German
+	"007": {
+		{1, "1847"},
+		{2, "System"},
+		{6, "Prozessorzeit (%)"},
+		{180, "Arbeitsseiten"},
+		{200, "Zeit (%)"},
+		{230, "Prozess"},
+		{234, "Physikalischer Datenträger"},
+		{684, "Verstrichene Zeit"},
+		{784, "Prozesskennung"},
+		{1420, "Mittlere Bytes/Übertragung"},
+		{1421, "Mittlere Bytes/Übertragung (Basis)"},
+		{4320, "WSMan-Kontingentstatistik"},
+		{4322, "Anforderungen insgesamt/Sekunde"},
+		{4334, "Prozess-ID"},
+		{4600, "Netzwerk-QoS-Richtlinie"},
+		{4602, "Übertragene Pakete"},
+		{4604, "Übertragene Pakete/s"},
+	},
+	// Spanish
+	"00A": {
+		{1, "1847"},
+		{2, "Sistema"},
+		{6, "% de tiempo de procesador"},
+		{180, "Espacio de trabajo"},
+		{200, "% de tiempo de disco"},
+		{230, "Proceso"},
+		{234, "Disco físico"},
+		{684, "Tiempo transcurrido"},
+		{784, "Id. de proceso"},
+		{1420, "Promedio de bytes de disco/transferencia"},
+		{4600, "Directiva de QoS de red"},
+		{4602, "Paquetes transmitidos"},
+		{4604, "Paquetes transmitidos/s"},
+	},
+	// French
+	"00C": {
+		{1, "1847"},
+		{2, "Système"},
+		{6, "% temps processeur"},
+		{180, "Plage de travail"},
+		{200, "% temps disque"},
+		{230, "Processus"},
+		{234, "Disque physique"},
+		{684, "Temps écoulé"},
+		{784, "ID de processus"},
+		{1420, "Moyenne disque octets/transfert"},
+		{1421, "Moyenne disque octets/transfert (base)"},
+		{4600, "Stratégie QoS réseau"},
+		{4602, "Paquets transmis"},
+		{4604, "Paquets transmis/s"},
+	},
+}
+
+func buildLocalizedNameTable(language string) []byte {
+	return EncodeNameTable(testLocalizedNames[language])
+}
+
+// testLoads Counts the loads of each name table.
+type testLoads struct {
+	mu    sync.Mutex
+	loads map[string]int
+}
+
+func (l *testLoads) get() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return maps.Clone(l.loads)
+}
+
+// testNameTables Name tables, which load the synthetic tables from testdata, like they are queried from "Counter <language>".
+func testNameTables(t testing.TB) (*NameTables, *testLoads) {
+	t.Helper()
+
+	loads := &testLoads{loads: make(map[string]int)}
+
+	return NewNameTables(func(language string) ([]byte, error) {
+		loads.mu.Lock()
+		loads.loads[language]++
+		loads.mu.Unlock()
+
+		if language == EnglishLanguage {
+			return readTestdata(t, "counter_009.bin", buildNameTable), nil
+		}
+
+		if _, ok := testLocalizedNames[language]; !ok {
+			return nil, fmt.Errorf("language %s: %w", language, os.ErrNotExist)
+		}
+
+		return readTestdata(t, fmt.Sprintf("counter_%s.bin", language), func() []byte {
+			return buildLocalizedNameTable(language)
+		}), nil
+	}), loads
+}
+
+func TestLocalizedTestdata(t *testing.T) {
+	t.Parallel()
+
+	for language := range testLocalizedNames {
+		data, err := os.ReadFile(filepath.Join("testdata", fmt.Sprintf("counter_%s.bin", language)))
+		require.NoError(t, err)
+		assert.Equal(t, buildLocalizedNameTable(language), data, language)
+	}
+}
+
+func TestNameMapping(t *testing.T) {
+	t.Parallel()
+
+	tables, _ := testNameTables(t)
+
+	for _, tc := range []struct {
+		language  string
+		english   string
+		localized string
+	}{
+		{"007", "Process", "Prozess"},
+		{"007", "% Processor Time", "Prozessorzeit (%)"},
+		{"007", "Avg. Disk Bytes/Transfer", "Mittlere Bytes/Übertragung"},
+		{"007", "WSMan Quota Statistics", "WSMan-Kontingentstatistik"},
+		{"00A", "Process", "Proceso"},
+		{"00A", "PhysicalDisk", "Disco físico"},
+		{"00C", "Process", "Processus"},
+		{"00C", "Packets transmitted/sec", "Paquets transmis/s"},
+		// Names, which are equal in both languages.
+		{"007", "System", "System"},
+		// Names, which are not translated.
+		{"00C", "WSMan Quota Statistics", "WSMan Quota Statistics"},
+		{"00A", "Avg. Disk Bytes/Transfer Base", "Avg. Disk Bytes/Transfer Base"},
+		// Names, which are unknown to both tables, e.g. of objects registered after the tables were loaded.
+		{"007", "Unknown", "Unknown"},
+		// English hosts.
+		{EnglishLanguage, "Process", "Process"},
+	} {
+		t.Run(tc.language+"/"+tc.english, func(t *testing.T) {
+			t.Parallel()
+
+			names := tables.Mapping(tc.language)
+
+			assert.Equal(t, tc.localized, names.Localize(tc.english))
+			assert.Equal(t, tc.english, names.English(tc.localized))
+			// English names are accepted on localized hosts as well.
+			assert.Equal(t, tc.english, names.English(tc.english))
+		})
+	}
+}
+
+func TestNameTables(t *testing.T) {
+	t.Parallel()
+
+	tables, loads := testNameTables(t)
+
+	german := tables.Table("007")
+	assert.Same(t, german, tables.Table("7"))
+	assert.Same(t, german, tables.Table("0007"))
+	assert.Same(t, tables.Table("00c"), tables.Table("00C"))
+
+	// Tables are loaded once, on the first lookup.
+	assert.Empty(t, loads.get())
+	assert.Equal(t, "Prozess", german.LookupString(230))
+	assert.Equal(t, uint32(230), tables.Table("7").LookupIndex("Prozess"))
+	assert.Equal(t, "Prozess", tables.Mapping("007").Localize("Process"))
+	assert.Equal(t, map[string]int{"007": 1, EnglishLanguage: 1}, loads.get())
+	require.NoError(t, german.Err())
+
+	// Languages, which are not installed, leave all names as they are.
+	italian := tables.Mapping("010")
+	assert.Equal(t, "Process", italian.Localize("Process"))
+	assert.Equal(t, "Processo", italian.English("Processo"))
+	require.ErrorIs(t, tables.Table("010").Err(), os.ErrNotExist)
+
+	invalid := tables.Table("German")
+	require.ErrorContains(t, invalid.Err(), `invalid language code "German"`)
+	assert.Zero(t, invalid.LookupIndex("Prozess"))
+	assert.NotContains(t, loads.get(), "German")
+}
+
+func TestNameTableLoadError(t *testing.T) {
+	t.Parallel()
+
+	errLoad := errors.New("access denied")
+	names := &NameTable{load: func() ([]byte, error) { return nil, errLoad }}
+
+	require.ErrorIs(t, names.Err(), errLoad)
+	assert.Empty(t, names.LookupString(230))
+	assert.Zero(t, names.LookupIndex("Process"))
+}
+
+// TestRecordedLocalizedNames maps the objects of the data block captured on a localized host, if any,
+// to the language of the host and back. See TestRecordPerformanceData.
+func TestRecordedLocalizedNames(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob(filepath.Join(recordedDir, "counter_*.bin"))
+	require.NoError(t, err)
+
+	files = slices.DeleteFunc(files, func(file string) bool {
+		return filepath.Base(file) == "counter_"+EnglishLanguage+".bin"
+	})
+
+	if len(files) == 0 {
+		t.Skip("no captured localized name tables in " + recordedDir + ", see TestRecordPerformanceData")
+	}
+
+	source, err := LoadReplaySource(filepath.Join(recordedDir, "counter_"+EnglishLanguage+".bin"), filepath.Join(recordedDir, "global.blob"))
+	require.NoError(t, err)
+
+	objects, err := queryPerformanceData(source, "Global", "")
+	require.NoError(t, err)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		mapping := NewNameMapping(source.NameTable(), NewNameTable(data))
+
+		for _, object := range objects {
+			assert.Equal(t, object.Name, mapping.English(mapping.Localize(object.Name)), file)
+		}
+	}
+}
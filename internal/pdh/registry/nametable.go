@@ -7,22 +7,11 @@ import (
 	"sync"
 )
 
-// CounterNameTable Initialize global name tables
-// profiling, add option to disable name tables if necessary
-// Not sure if we should resolve the names at all or just have the caller do it on demand
-// (for many use cases the index is sufficient)
-//
-//nolint:gochecknoglobals
-var CounterNameTable = *QueryNameTable("Counter 009")
-
-func (p *perfObjectType) LookupName() string {
-	return CounterNameTable.LookupString(p.ObjectNameTitleIndex)
-}
-
 type NameTable struct {
 	once sync.Once
 
-	name string
+	load func() ([]byte, error)
+	err  error
 
 	table struct {
 		index  map[uint32]string
@@ -42,11 +31,21 @@ func (t *NameTable) LookupIndex(str string) uint32 {
 	return t.table.string[str]
 }
 
-// QueryNameTable Query a perflib name table from the v1. Specify the type and the language
-// code (i.e. "Counter 009" or "Help 009") for English language.
-func QueryNameTable(tableName string) *NameTable {
+// Err returns the error, which occurred while loading the table, e.g. since its language is not installed.
+// A table, which failed to load, is empty.
+func (t *NameTable) Err() error {
+	t.initialize()
+
+	return t.err
+}
+
+// NewNameTable Create a name table from the raw data of a perflib name table, i.e. alternating
+// null-terminated UTF16 indices and names as returned for "Counter 009".
+func NewNameTable(data []byte) *NameTable {
 	return &NameTable{
-		name: tableName,
+		load: func() ([]byte, error) {
+			return data, nil
+		},
 	}
 }
 
@@ -55,9 +54,11 @@ func (t *NameTable) initialize() {
 		t.table.index = make(map[uint32]string)
 		t.table.string = make(map[string]uint32)
 
-		buffer, err := queryRawData(t.name)
+		buffer, err := t.load()
 		if err != nil {
-			panic(err)
+			t.err = fmt.Errorf("failed to load name table: %w", err)
+
+			return
 		}
 
 		r := bytes.NewReader(buffer)
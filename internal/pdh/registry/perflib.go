@@ -111,17 +111,10 @@ Data for some of the objects is also available through WMI:
 */
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
-	"strings"
-	"time"
-	"unsafe"
-
-	"github.com/prometheus-community/windows_exporter/internal/pdh"
-	"golang.org/x/sys/windows"
 )
 
 // There's a LittleEndian field in the PERF header - we ought to check it.
@@ -129,6 +122,18 @@ import (
 //nolint:gochecknoglobals
 var bo = binary.LittleEndian
 
+// ErrInvalidSignature is returned for data blocks, which don't start with the "PERF" signature.
+var ErrInvalidSignature = errors.New("invalid performance data block signature")
+
+// Sizes of the raw structures, used to reject counts which exceed the size of the data block.
+//
+//nolint:gochecknoglobals
+var (
+	perfObjectTypeSize         = int64(binary.Size(perfObjectType{}))
+	perfCounterDefinitionSize  = int64(binary.Size(perfCounterDefinition{}))
+	perfInstanceDefinitionSize = int64(binary.Size(perfInstanceDefinition{}))
+)
+
 // PerfObject Top-level performance object (like "Process").
 type PerfObject struct {
 	Name string
@@ -168,9 +173,13 @@ type PerfCounterDef struct {
 	IsBaseValue bool
 	// PERF_TIMER_100NS
 	IsNanosecondCounter bool
-	HasSecondValue      bool
+	// HasSecondValue is set for counters followed by a base counter, e.g. PERF_RAW_FRACTION followed by
+	// PERF_RAW_BASE. Like PDH, the value of the base counter is reported as second value of the counter.
+	HasSecondValue bool
 
 	rawData *perfCounterDefinition
+	// baseIndex is the index of the base counter in the counter definitions, if HasSecondValue is set.
+	baseIndex int
 }
 
 type PerfCounter struct {
@@ -179,109 +188,23 @@ type PerfCounter struct {
 	SecondValue int64
 }
 
-//nolint:gochecknoglobals
-var (
-	bufLenGlobal = uint32(400000)
-	bufLenCostly = uint32(2000000)
-)
-
-// queryRawData Queries the performance counter buffer using RegQueryValueEx, returning raw bytes. See:
-// https://msdn.microsoft.com/de-de/library/windows/desktop/aa373219(v=vs.85).aspx
-func queryRawData(query string) ([]byte, error) {
-	var (
-		valType uint32
-		buffer  []byte
-		bufLen  uint32
-	)
-
-	switch query {
-	case "Global":
-		bufLen = bufLenGlobal
-	case "Costly":
-		bufLen = bufLenCostly
-	default:
-		// depends on the number of values requested
-		// need make an educated guess
-		numCounters := len(strings.Split(query, " "))
-		bufLen = uint32(150000 * numCounters)
-	}
-
-	buffer = make([]byte, bufLen)
-
-	name, err := windows.UTF16PtrFromString(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode query string: %w", err)
-	}
-
-	for {
-		bufLen := uint32(len(buffer))
-
-		err := windows.RegQueryValueEx(
-			windows.HKEY_PERFORMANCE_DATA,
-			name,
-			nil,
-			&valType,
-			(*byte)(unsafe.Pointer(&buffer[0])),
-			&bufLen)
-
-		switch {
-		case errors.Is(err, error(windows.ERROR_MORE_DATA)):
-			newBuffer := make([]byte, len(buffer)+16384)
-			copy(newBuffer, buffer)
-			buffer = newBuffer
-
-			continue
-		case errors.Is(err, error(windows.ERROR_BUSY)):
-			time.Sleep(50 * time.Millisecond)
-
-			continue
-		case err != nil:
-			var errNo windows.Errno
-			if errors.As(err, &errNo) {
-				return nil, fmt.Errorf("ReqQueryValueEx failed: %w errno %d", err, uint(errNo))
-			}
-
-			return nil, err
-		}
-
-		buffer = buffer[:bufLen]
-
-		switch query {
-		case "Global":
-			if bufLen > bufLenGlobal {
-				bufLenGlobal = bufLen
-			}
-		case "Costly":
-			if bufLen > bufLenCostly {
-				bufLenCostly = bufLen
-			}
-		}
-
-		return buffer, nil
-	}
-}
-
 /*
-QueryPerformanceData Query all performance counters that match a given query.
+ParsePerformanceData Parse a raw performance data block, as returned by HKEY_PERFORMANCE_DATA.
 
-The query can be any of the following:
+Object and counter names are resolved using the given name table. If objectName is not empty,
+only the first object with this name is returned.
 
-- "Global" (all performance counters except those Windows marked as costly)
-
-- "Costly" (only the costly ones)
-
-- One or more object indices, separated by spaces ("238 2 5")
-
-Many objects have dependencies - if you query one of them, you often get back
-more than you asked for.
+The parser does not depend on any Windows API, so recorded data blocks can be parsed on any OS.
 */
-func QueryPerformanceData(query string, counterName string) ([]*PerfObject, error) {
-	buffer, err := queryRawData(query)
+func ParsePerformanceData(r io.ReadSeeker, names *NameTable, objectName string) ([]*PerfObject, error) {
+	size, err := r.Seek(0, io.SeekEnd)
 	if err != nil {
 		return nil, err
 	}
 
-	r := bytes.NewReader(buffer)
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
 
 	// Read global header
 
@@ -289,24 +212,27 @@ func QueryPerformanceData(query string, counterName string) ([]*PerfObject, erro
 
 	err = header.BinaryReadFrom(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read performance data block for %q with: %w", query, err)
+		return nil, fmt.Errorf("failed to read performance data block: %w", err)
 	}
 
 	// Check for "PERF" signature
 	if header.Signature != [4]uint16{80, 69, 82, 70} {
-		panic("Invalid performance block header")
+		return nil, ErrInvalidSignature
 	}
 
 	// Parse the performance data
 
+	if int64(header.NumObjectTypes)*perfObjectTypeSize > size {
+		return nil, fmt.Errorf("number of objects %d exceeds the data block size", header.NumObjectTypes)
+	}
+
 	numObjects := int(header.NumObjectTypes)
-	numFilteredObjects := 0
 
-	objects := make([]*PerfObject, numObjects)
+	objects := make([]*PerfObject, 0, numObjects)
 
 	objOffset := int64(header.HeaderLength)
 
-	for i := range numObjects {
+	for range numObjects {
 		_, err := r.Seek(objOffset, io.SeekStart)
 		if err != nil {
 			return nil, err
@@ -319,14 +245,19 @@ func QueryPerformanceData(query string, counterName string) ([]*PerfObject, erro
 			return nil, err
 		}
 
-		perfCounterName := obj.LookupName()
+		perfCounterName := names.LookupString(obj.ObjectNameTitleIndex)
 
-		if counterName != "" && perfCounterName != counterName {
+		if objectName != "" && perfCounterName != objectName {
 			objOffset += int64(obj.TotalByteLength)
 
 			continue
 		}
 
+		if int64(obj.NumCounters)*perfCounterDefinitionSize > size ||
+			int64(obj.NumInstances)*perfInstanceDefinitionSize > size {
+			return nil, fmt.Errorf("number of counters or instances of object %d exceeds the data block size", obj.ObjectNameTitleIndex)
+		}
+
 		numCounterDefs := int(obj.NumCounters)
 		numInstances := int(obj.NumInstances)
 
@@ -340,7 +271,7 @@ func QueryPerformanceData(query string, counterName string) ([]*PerfObject, erro
 		instances := make([]*PerfInstance, numInstances)
 		counterDefs := make([]*PerfCounterDef, numCounterDefs)
 
-		objects[i] = &PerfObject{
+		object := &PerfObject{
 			Name:        perfCounterName,
 			NameIndex:   uint(obj.ObjectNameTitleIndex),
 			Instances:   instances,
@@ -358,27 +289,24 @@ func QueryPerformanceData(query string, counterName string) ([]*PerfObject, erro
 			}
 
 			counterDefs[i] = &PerfCounterDef{
-				Name:      def.LookupName(),
+				Name:      names.LookupString(def.CounterNameTitleIndex),
 				NameIndex: uint(def.CounterNameTitleIndex),
 				rawData:   def,
 
 				CounterType: def.CounterType,
 
 				IsCounter:           def.CounterType&0x400 == 0x400,
-				IsBaseValue:         def.CounterType&0x00030000 == 0x00030000,
+				IsBaseValue:         def.CounterType&perfCounterSubtypeMask == perfCounterBase,
 				IsNanosecondCounter: def.CounterType&0x00100000 == 0x00100000,
-				HasSecondValue:      def.CounterType == pdh.PERF_AVERAGE_BULK,
 			}
 		}
 
+		linkBaseCounters(counterDefs)
+
 		if obj.NumInstances <= 0 { //nolint:nestif
 			blockOffset := objOffset + int64(obj.DefinitionLength)
 
-			if _, err := r.Seek(blockOffset, io.SeekStart); err != nil {
-				return nil, err
-			}
-
-			_, counters, err := parseCounterBlock(buffer, r, blockOffset, counterDefs)
+			_, counters, err := parseCounterBlock(r, blockOffset, counterDefs)
 			if err != nil {
 				return nil, err
 			}
@@ -403,10 +331,14 @@ func QueryPerformanceData(query string, counterName string) ([]*PerfObject, erro
 					return nil, err
 				}
 
+				if int64(inst.NameLength) > size {
+					return nil, fmt.Errorf("instance name length %d exceeds the data block size", inst.NameLength)
+				}
+
 				name, _ := readUTF16StringAtPos(r, instOffset+int64(inst.NameOffset), inst.NameLength)
 				pos := instOffset + int64(inst.ByteLength)
 
-				offset, counters, err := parseCounterBlock(buffer, r, pos, counterDefs)
+				offset, counters, err := parseCounterBlock(r, pos, counterDefs)
 				if err != nil {
 					return nil, err
 				}
@@ -421,19 +353,39 @@ func QueryPerformanceData(query string, counterName string) ([]*PerfObject, erro
 			}
 		}
 
-		if counterName != "" {
-			return objects[i : i+1], nil
+		objects = append(objects, object)
+
+		if objectName != "" {
+			return objects, nil
 		}
 
 		// Next perfObjectType
 		objOffset += int64(obj.TotalByteLength)
-		numFilteredObjects++
 	}
 
-	return objects[:numFilteredObjects], nil
+	return objects, nil
 }
 
-func parseCounterBlock(b []byte, r io.ReadSeeker, pos int64, defs []*PerfCounterDef) (int64, []*PerfCounter, error) {
+// The subtype of a counter type, see PERF_COUNTER_VALUE to PERF_COUNTER_PRECISION in winperf.h.
+const (
+	perfCounterSubtypeMask = 0x00070000
+	perfCounterBase        = 0x00030000
+)
+
+// linkBaseCounters Assigns each base counter to the counter defined right before it, which perflib requires
+// for fractions, averages and precision timers.
+func linkBaseCounters(defs []*PerfCounterDef) {
+	for i, def := range defs {
+		if def.IsBaseValue || i+1 >= len(defs) || !defs[i+1].IsBaseValue {
+			continue
+		}
+
+		def.HasSecondValue = true
+		def.baseIndex = i + 1
+	}
+}
+
+func parseCounterBlock(r io.ReadSeeker, pos int64, defs []*PerfCounterDef) (int64, []*PerfCounter, error) {
 	_, err := r.Seek(pos, io.SeekStart)
 	if err != nil {
 		return 0, nil, err
@@ -450,24 +402,28 @@ func parseCounterBlock(b []byte, r io.ReadSeeker, pos int64, defs []*PerfCounter
 
 	for i, def := range defs {
 		valueOffset := pos + int64(def.rawData.CounterOffset)
-		value := convertCounterValue(def.rawData, b, valueOffset)
-		secondValue := int64(0)
 
-		if def.HasSecondValue {
-			secondValue = convertCounterValue(def.rawData, b, valueOffset+8)
+		value, err := readCounterValue(r, def.rawData, valueOffset)
+		if err != nil {
+			return 0, nil, err
 		}
 
 		counters[i] = &PerfCounter{
-			Value:       value,
-			Def:         def,
-			SecondValue: secondValue,
+			Value: value,
+			Def:   def,
+		}
+	}
+
+	for _, counter := range counters {
+		if counter.Def.HasSecondValue {
+			counter.SecondValue = counters[counter.Def.baseIndex].Value
 		}
 	}
 
 	return int64(block.ByteLength), counters, nil
 }
 
-func convertCounterValue(counterDef *perfCounterDefinition, buffer []byte, valueOffset int64) int64 {
+func readCounterValue(r io.ReadSeeker, counterDef *perfCounterDefinition, valueOffset int64) (int64, error) {
 	/*
 		We can safely ignore the type since we're not interested in anything except the raw value.
 		We also ignore all of the other attributes (timestamp, presentation, multi counter values...)
@@ -482,12 +438,26 @@ func convertCounterValue(counterDef *perfCounterDefinition, buffer []byte, value
 			272696576	64bit rate
 
 	*/
+	if _, err := r.Seek(valueOffset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
 	switch counterDef.CounterSize {
-	case 4:
-		return int64(bo.Uint32(buffer[valueOffset:(valueOffset + 4)]))
 	case 8:
-		return int64(bo.Uint64(buffer[valueOffset:(valueOffset + 8)]))
+		var value uint64
+
+		if err := binary.Read(r, bo, &value); err != nil {
+			return 0, fmt.Errorf("failed to read counter value at offset %d: %w", valueOffset, err)
+		}
+
+		return int64(value), nil
 	default:
-		return int64(bo.Uint32(buffer[valueOffset:(valueOffset + 4)]))
+		var value uint32
+
+		if err := binary.Read(r, bo, &value); err != nil {
+			return 0, fmt.Errorf("failed to read counter value at offset %d: %w", valueOffset, err)
+		}
+
+		return int64(value), nil
 	}
 }
@@ -1,11 +1,357 @@
 package registry
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func BenchmarkQueryPerformanceData(b *testing.B) {
-	for n := 0; n < b.N; n++ {
-		_, _ = QueryPerformanceData("Global", "")
+//nolint:gochecknoglobals
+var update = flag.Bool("update", false, "regenerate the synthetic data blocks in testdata")
+
+// testNames Excerpt of the English counter name table.
+//
+//nolint:gochecknoglobals
+var testNames = []NameTableEntry{
+	{1, "1847"},
+	{2, "System"},
+	{6, "% Processor Time"},
+	{180, "Working Set"},
+	{200, "% Disk Time"},
+	{230, "Process"},
+	{234, "PhysicalDisk"},
+	{236, "LogicalDisk"},
+	{408, "% Free Space"},
+	{409, "% Free Space Base"},
+	{410, "Free Megabytes"},
+	{684, "Elapsed Time"},
+	{784, "ID Process"},
+	{1420, "Avg. Disk Bytes/Transfer"},
+	{1421, "Avg. Disk Bytes/Transfer Base"},
+	{4320, "WSMan Quota Statistics"},
+	{4322, "Total Requests/Second"},
+	{4334, "Process ID"},
+	{4600, "Network QoS Policy"},
+	{4602, "Packets transmitted"},
+	{4604, "Packets transmitted/sec"},
+}
+
+//nolint:gochecknoglobals
+var processCounters = []CounterData{
+	{6, pdh.PERF_100NSEC_TIMER, 8},
+	{684, pdh.PERF_ELAPSED_TIME, 8},
+	{784, pdh.PERF_COUNTER_RAWCOUNT, 4},
+	{180, pdh.PERF_COUNTER_LARGE_RAWCOUNT, 8},
+}
+
+// testBlocks Data blocks stored in testdata. They are synthetic, i.e. generated with the layout of
+// HKEY_PERFORMANCE_DATA on 64-bit Windows instead of captured on a host. Run the tests with -update to
+// regenerate them. Captures of real hosts are tested by TestRecordedPerformanceData.
+//
+//nolint:gochecknoglobals
+var testBlocks = map[string][]ObjectData{
+	"process_1.blob": {
+		{230, 10000000, processCounters, []InstanceData{
+			{"Idle", []uint64{1500000000, 133500000000000000, 0, 8192}},
+			{"System", []uint64{25000000, 133500000000000000, 4, 155648}},
+			{"svchost", []uint64{1200000, 133500036000000000, 1428, 12582912}},
+			{"_Total", []uint64{1526200000, 0, 0, 12746752}},
+		}, nil},
+	},
+	"process_2.blob": {
+		{230, 10000000, processCounters, []InstanceData{
+			{"Idle", []uint64{1600000000, 133500000000000000, 0, 8192}},
+			{"System", []uint64{26000000, 133500000000000000, 4, 159744}},
+			{"svchost", []uint64{1400000, 133500036000000000, 1428, 13631488}},
+			{"explorer", []uint64{3000000, 133500072000000000, 5012, 104857600}},
+			{"_Total", []uint64{1630400000, 0, 0, 118657024}},
+		}, nil},
+	},
+	"global.blob": {
+		{4320, 10000000, []CounterData{
+			{4322, pdh.PERF_COUNTER_COUNTER, 4},
+			{4334, pdh.PERF_COUNTER_RAWCOUNT, 4},
+		}, []InstanceData{
+			{"WinRMService", []uint64{59, 928}},
+		}, nil},
+		{4600, 10000000, []CounterData{
+			{4602, pdh.PERF_COUNTER_LARGE_RAWCOUNT, 8},
+			{4604, pdh.PERF_COUNTER_BULK_COUNT, 8},
+		}, nil, []uint64{1744, 180388626632}},
+		{234, 10000000, []CounterData{
+			{200, pdh.PERF_PRECISION_100NS_TIMER, 8},
+			{1420, pdh.PERF_AVERAGE_BULK, 8},
+			{1421, pdh.PERF_AVERAGE_BASE, 4},
+		}, []InstanceData{
+			{"0 C:", []uint64{52000000, 81920, 20}},
+			{"_Total", []uint64{52000000, 81920, 20}},
+		}, nil},
+		{236, 10000000, []CounterData{
+			{408, pdh.PERF_RAW_FRACTION, 4},
+			{409, pdh.PERF_RAW_BASE, 4},
+			{410, pdh.PERF_COUNTER_RAWCOUNT, 4},
+		}, []InstanceData{
+			{"C:", []uint64{81234, 243197, 81234}},
+		}, nil},
+	},
+}
+
+func buildNameTable() []byte {
+	return EncodeNameTable(testNames)
+}
+
+// readTestdata Reads a file from testdata, which is regenerated if -update is set.
+func readTestdata(t testing.TB, name string, generate func() []byte) []byte {
+	t.Helper()
+
+	file := filepath.Join("testdata", name)
+
+	if *update {
+		require.NoError(t, os.WriteFile(file, generate(), 0o600))
+	}
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	return data
+}
+
+func testNameTable(t testing.TB) *NameTable {
+	t.Helper()
+
+	return NewNameTable(readTestdata(t, "counter_009.bin", buildNameTable))
+}
+
+func testBlock(t testing.TB, name string) []byte {
+	t.Helper()
+
+	return readTestdata(t, name, func() []byte {
+		return EncodePerformanceData(testBlocks[name])
+	})
+}
+
+func TestTestdata(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, buildNameTable(), testNameTable(t).mustLoad(t))
+
+	for name, objects := range testBlocks {
+		assert.Equal(t, EncodePerformanceData(objects), testBlock(t, name), name)
+	}
+}
+
+func (t *NameTable) mustLoad(tb testing.TB) []byte {
+	tb.Helper()
+
+	data, err := t.load()
+	require.NoError(tb, err)
+
+	return data
+}
+
+func TestNameTable(t *testing.T) {
+	t.Parallel()
+
+	names := testNameTable(t)
+
+	assert.Equal(t, "Process", names.LookupString(230))
+	assert.Equal(t, uint32(4320), names.LookupIndex("WSMan Quota Statistics"))
+	assert.Empty(t, names.LookupString(3))
+	assert.Zero(t, names.LookupIndex("Unknown"))
+
+	// Truncated tables are read up to the last complete entry.
+	data := buildNameTable()
+	names = NewNameTable(data[:len(data)-7])
+
+	assert.Equal(t, "Network QoS Policy", names.LookupString(4600))
+	assert.Empty(t, names.LookupString(4604))
+}
+
+func TestParsePerformanceData(t *testing.T) {
+	t.Parallel()
+
+	names := testNameTable(t)
+
+	objects, err := ParsePerformanceData(bytes.NewReader(testBlock(t, "global.blob")), names, "")
+	require.NoError(t, err)
+	require.Len(t, objects, 4)
+
+	wsman := objects[0]
+	assert.Equal(t, "WSMan Quota Statistics", wsman.Name)
+	assert.Equal(t, uint(4320), wsman.NameIndex)
+	assert.Equal(t, int64(10000000), wsman.Frequency)
+	require.Len(t, wsman.Instances, 1)
+	assert.Equal(t, "WinRMService", wsman.Instances[0].Name)
+	require.Len(t, wsman.Instances[0].Counters, 2)
+	assert.Equal(t, "Total Requests/Second", wsman.Instances[0].Counters[0].Def.Name)
+	assert.True(t, wsman.Instances[0].Counters[0].Def.IsCounter)
+	assert.Equal(t, int64(59), wsman.Instances[0].Counters[0].Value)
+	assert.Equal(t, "Process ID", wsman.Instances[0].Counters[1].Def.Name)
+	assert.False(t, wsman.Instances[0].Counters[1].Def.IsCounter)
+	assert.Equal(t, int64(928), wsman.Instances[0].Counters[1].Value)
+
+	// Objects without instances have a single instance with an empty name.
+	qos := objects[1]
+	assert.Equal(t, "Network QoS Policy", qos.Name)
+	require.Len(t, qos.Instances, 1)
+	assert.Empty(t, qos.Instances[0].Name)
+	assert.Equal(t, int64(1744), qos.Instances[0].Counters[0].Value)
+	assert.Equal(t, int64(180388626632), qos.Instances[0].Counters[1].Value)
+
+	disk := objects[2]
+	assert.Equal(t, "PhysicalDisk", disk.Name)
+	require.Len(t, disk.Instances, 2)
+	assert.Equal(t, "0 C:", disk.Instances[0].Name)
+
+	diskTime := disk.Instances[0].Counters[0]
+	assert.True(t, diskTime.Def.IsNanosecondCounter)
+	assert.False(t, diskTime.Def.IsBaseValue)
+	assert.False(t, diskTime.Def.HasSecondValue)
+	assert.Equal(t, int64(52000000), diskTime.Value)
+
+	// Counters followed by a base counter carry its value as second value, like PERF_AVERAGE_BULK ...
+	bytesPerTransfer := disk.Instances[0].Counters[1]
+	assert.True(t, bytesPerTransfer.Def.HasSecondValue)
+	assert.Equal(t, int64(81920), bytesPerTransfer.Value)
+	assert.Equal(t, int64(20), bytesPerTransfer.SecondValue)
+	assert.True(t, disk.Instances[0].Counters[2].Def.IsBaseValue)
+	assert.False(t, disk.Instances[0].Counters[2].Def.HasSecondValue)
+
+	// ... and PERF_RAW_FRACTION.
+	logicalDisk := objects[3]
+	assert.Equal(t, "LogicalDisk", logicalDisk.Name)
+
+	freeSpace := logicalDisk.Instances[0].Counters[0]
+	assert.Equal(t, "% Free Space", freeSpace.Def.Name)
+	assert.True(t, freeSpace.Def.HasSecondValue)
+	assert.Equal(t, int64(81234), freeSpace.Value)
+	assert.Equal(t, int64(243197), freeSpace.SecondValue)
+	assert.False(t, logicalDisk.Instances[0].Counters[2].Def.HasSecondValue)
+
+	objects, err = ParsePerformanceData(bytes.NewReader(testBlock(t, "global.blob")), names, "PhysicalDisk")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "PhysicalDisk", objects[0].Name)
+
+	objects, err = ParsePerformanceData(bytes.NewReader(testBlock(t, "global.blob")), names, "Process")
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestParsePerformanceDataErrors(t *testing.T) {
+	t.Parallel()
+
+	names := testNameTable(t)
+	block := testBlock(t, "process_1.blob")
+
+	_, err := ParsePerformanceData(bytes.NewReader(block[:40]), names, "")
+	require.ErrorContains(t, err, "failed to read performance data block")
+
+	invalid := bytes.Clone(block)
+	invalid[0] = 'X'
+
+	_, err = ParsePerformanceData(bytes.NewReader(invalid), names, "")
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = ParsePerformanceData(bytes.NewReader(block[:len(block)-16]), names, "")
+	require.Error(t, err)
+
+	// The number of objects must fit into the data block.
+	invalid = bytes.Clone(block)
+	bo.PutUint32(invalid[28:], 0xFFFFFFFF)
+
+	_, err = ParsePerformanceData(bytes.NewReader(invalid), names, "")
+	require.ErrorContains(t, err, "exceeds the data block size")
+}
+
+func TestReplaySource(t *testing.T) {
+	t.Parallel()
+
+	source, err := LoadReplaySource(
+		filepath.Join("testdata", "counter_009.bin"),
+		filepath.Join("testdata", "process_1.blob"),
+		filepath.Join("testdata", "process_2.blob"),
+	)
+	require.NoError(t, err)
+
+	// The last data block is repeated.
+	for _, expected := range []int{4, 5, 5} {
+		objects, err := queryPerformanceData(source, "230", "Process")
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+		assert.Len(t, objects[0].Instances, expected)
+	}
+
+	_, err = queryPerformanceData(NewReplaySource(source.NameTable()), "230", "Process")
+	require.Error(t, err)
+}
+
+func FuzzParsePerformanceData(f *testing.F) {
+	names := testNameTable(f)
+
+	for name := range testBlocks {
+		f.Add(testBlock(f, name))
 	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		objects, err := ParsePerformanceData(bytes.NewReader(data), names, "")
+		if err != nil {
+			return
+		}
+
+		for _, object := range objects {
+			require.NotEmpty(t, object.Instances)
+
+			for _, instance := range object.Instances {
+				require.Len(t, instance.Counters, len(object.CounterDefs))
+			}
+		}
+	})
+}
+
+// recordedDir holds the name tables and data blocks captured on a host by TestRecordPerformanceData.
+const recordedDir = "testdata/recorded"
+
+// TestRecordedPerformanceData parses the data blocks captured on a real host, if any. Unlike the synthetic
+// blocks of testBlocks, they contain the objects and counter layouts of an actual Windows installation.
+func TestRecordedPerformanceData(t *testing.T) {
+	t.Parallel()
+
+	source, err := LoadReplaySource(filepath.Join(recordedDir, "counter_009.bin"), filepath.Join(recordedDir, "global.blob"))
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("no captured data blocks in " + recordedDir + ", see TestRecordPerformanceData")
+	}
+
+	require.NoError(t, err)
+
+	objects, err := queryPerformanceData(source, "Global", "")
+	require.NoError(t, err)
+	require.NotEmpty(t, objects)
+
+	for _, object := range objects {
+		assert.NotEmpty(t, object.Name, "object %d", object.NameIndex)
+	}
+
+	// The System object is present on every host.
+	type systemValues struct {
+		Processes    float64 `perfdata:"Processes"`
+		SystemUpTime float64 `perfdata:"System Up Time"`
+	}
+
+	collector, err := NewCollectorWithSource[systemValues](source, "System", nil)
+	require.NoError(t, err)
+
+	var values []systemValues
+
+	require.NoError(t, collector.Collect(&values))
+	require.Len(t, values, 1)
+	assert.Positive(t, values[0].Processes)
+	assert.Positive(t, values[0].SystemUpTime)
 }
@@ -0,0 +1,135 @@
+//go:build windows
+
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+//nolint:gochecknoglobals
+var (
+	bufLenGlobal = uint32(400000)
+	bufLenCostly = uint32(2000000)
+)
+
+// PerformanceDataSource queries the live data blocks from HKEY_PERFORMANCE_DATA.
+type PerformanceDataSource struct{}
+
+func (PerformanceDataSource) Query(query string) (io.ReadSeeker, error) {
+	buffer, err := queryRawData(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(buffer), nil
+}
+
+func (PerformanceDataSource) NameTable() *NameTable {
+	return &CounterNameTable
+}
+
+func (PerformanceDataSource) LocalNames() *NameMapping {
+	return LocalNames()
+}
+
+// queryRawData Queries the performance counter buffer using RegQueryValueEx, returning raw bytes. See:
+// https://msdn.microsoft.com/de-de/library/windows/desktop/aa373219(v=vs.85).aspx
+func queryRawData(query string) ([]byte, error) {
+	var (
+		valType uint32
+		buffer  []byte
+		bufLen  uint32
+	)
+
+	switch query {
+	case "Global":
+		bufLen = bufLenGlobal
+	case "Costly":
+		bufLen = bufLenCostly
+	default:
+		// depends on the number of values requested
+		// need make an educated guess
+		numCounters := len(strings.Split(query, " "))
+		bufLen = uint32(150000 * numCounters)
+	}
+
+	buffer = make([]byte, bufLen)
+
+	name, err := windows.UTF16PtrFromString(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query string: %w", err)
+	}
+
+	for {
+		bufLen := uint32(len(buffer))
+
+		err := windows.RegQueryValueEx(
+			windows.HKEY_PERFORMANCE_DATA,
+			name,
+			nil,
+			&valType,
+			(*byte)(unsafe.Pointer(&buffer[0])),
+			&bufLen)
+
+		switch {
+		case errors.Is(err, error(windows.ERROR_MORE_DATA)):
+			newBuffer := make([]byte, len(buffer)+16384)
+			copy(newBuffer, buffer)
+			buffer = newBuffer
+
+			continue
+		case errors.Is(err, error(windows.ERROR_BUSY)):
+			time.Sleep(50 * time.Millisecond)
+
+			continue
+		case err != nil:
+			var errNo windows.Errno
+			if errors.As(err, &errNo) {
+				return nil, fmt.Errorf("ReqQueryValueEx failed: %w errno %d", err, uint(errNo))
+			}
+
+			return nil, err
+		}
+
+		buffer = buffer[:bufLen]
+
+		switch query {
+		case "Global":
+			if bufLen > bufLenGlobal {
+				bufLenGlobal = bufLen
+			}
+		case "Costly":
+			if bufLen > bufLenCostly {
+				bufLenCostly = bufLen
+			}
+		}
+
+		return buffer, nil
+	}
+}
+
+/*
+QueryPerformanceData Query all performance counters that match a given query.
+
+The query can be any of the following:
+
+- "Global" (all performance counters except those Windows marked as costly)
+
+- "Costly" (only the costly ones)
+
+- One or more object indices, separated by spaces ("238 2 5")
+
+Many objects have dependencies - if you query one of them, you often get back
+more than you asked for.
+*/
+func QueryPerformanceData(query string, counterName string) ([]*PerfObject, error) {
+	return queryPerformanceData(PerformanceDataSource{}, query, counterName)
+}
@@ -0,0 +1,38 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PerformanceDataSource is not supported on other platforms. Collectors can only replay recorded
+// data blocks there, see ReplaySource.
+type PerformanceDataSource struct{}
+
+func (PerformanceDataSource) Query(string) (io.ReadSeeker, error) {
+	return nil, fmt.Errorf("HKEY_PERFORMANCE_DATA can only be queried on Windows: %w", errors.ErrUnsupported)
+}
+
+func (PerformanceDataSource) NameTable() *NameTable {
+	return NewNameTable(nil)
+}
+
+func (PerformanceDataSource) LocalNames() *NameMapping {
+	return NewNameMapping(NewNameTable(nil), NewNameTable(nil))
+}
@@ -0,0 +1,49 @@
+//go:build windows
+
+package registry
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:gochecknoglobals
+var record = flag.Bool("registry.record", false, "capture the name tables and a Global data block of this host to testdata/recorded")
+
+func BenchmarkQueryPerformanceData(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		_, _ = QueryPerformanceData("Global", "")
+	}
+}
+
+// TestRecordPerformanceData captures the English name table and a Global data block of this host for
+// TestRecordedPerformanceData, if -registry.record is set. On localized hosts, the name table of the
+// system UI language is captured as well. The blocks contain the computer name and instance names,
+// e.g. of processes, so review them before committing.
+func TestRecordPerformanceData(t *testing.T) {
+	if !*record {
+		t.Skip("run with -registry.record to capture the data blocks of this host")
+	}
+
+	queries := map[string]string{
+		"counter_009.bin": "Counter " + EnglishLanguage,
+		"global.blob":     "Global",
+	}
+
+	if language := localLanguage(); language != EnglishLanguage {
+		queries["counter_"+language+".bin"] = "Counter " + language
+	}
+
+	require.NoError(t, os.MkdirAll(recordedDir, 0o755))
+
+	for file, query := range queries {
+		data, err := queryRawData(query)
+		require.NoError(t, err, query)
+
+		require.NoError(t, os.WriteFile(filepath.Join(recordedDir, file), data, 0o600))
+	}
+}
@@ -3,8 +3,6 @@ package registry
 import (
 	"encoding/binary"
 	"io"
-
-	"golang.org/x/sys/windows"
 )
 
 /*
@@ -37,7 +35,7 @@ type perfDataBlock struct {
 	HeaderLength     uint32
 	NumObjectTypes   uint32
 	DefaultObject    int32
-	SystemTime       windows.Systemtime
+	SystemTime       systemTime
 	_                uint32 // unknown field
 	PerfTime         int64
 	PerfFreq         int64
@@ -46,6 +44,18 @@ type perfDataBlock struct {
 	SystemNameOffset uint32
 }
 
+// systemTime Layout of the SYSTEMTIME struct, see windows.Systemtime.
+type systemTime struct {
+	Year         uint16
+	Month        uint16
+	DayOfWeek    uint16
+	Day          uint16
+	Hour         uint16
+	Minute       uint16
+	Second       uint16
+	Milliseconds uint16
+}
+
 func (p *perfDataBlock) BinaryReadFrom(r io.Reader) error {
 	return binary.Read(r, bo, p)
 }
@@ -126,10 +136,6 @@ func (p *perfCounterDefinition) BinaryReadFrom(r io.Reader) error {
 	return binary.Read(r, bo, p)
 }
 
-func (p *perfCounterDefinition) LookupName() string {
-	return CounterNameTable.LookupString(p.CounterNameTitleIndex)
-}
-
 /*
 perfCounterBlock
 See: https://msdn.microsoft.com/en-us/library/windows/desktop/aa373147(v=vs.85).aspx
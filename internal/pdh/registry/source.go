@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// DataSource provides raw performance data blocks and the name table to resolve their indices.
+// PerformanceDataSource queries HKEY_PERFORMANCE_DATA, ReplaySource replays recorded data blocks.
+type DataSource interface {
+	// Query returns the data block for a query, see QueryPerformanceData.
+	Query(query string) (io.ReadSeeker, error)
+	// NameTable returns the counter name table used to resolve object and counter names.
+	NameTable() *NameTable
+	// LocalNames returns the mapping of the English names to the language of the host,
+	// which resolves localized object and counter names.
+	LocalNames() *NameMapping
+}
+
+func queryPerformanceData(source DataSource, query string, counterName string) ([]*PerfObject, error) {
+	r, err := source.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := ParsePerformanceData(r, source.NameTable(), counterName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse performance data for %q with: %w", query, err)
+	}
+
+	return objects, nil
+}
+
+// ReplaySource replays recorded data blocks, e.g. to test collectors on any OS.
+// Each query returns the next data block, the last one is repeated. The query itself is ignored.
+type ReplaySource struct {
+	names      *NameTable
+	localNames *NameMapping
+
+	mu     sync.Mutex
+	blocks [][]byte
+	next   int
+}
+
+func NewReplaySource(names *NameTable, blocks ...[]byte) *ReplaySource {
+	return &ReplaySource{
+		names:      names,
+		localNames: NewNameMapping(names, names),
+		blocks:     blocks,
+	}
+}
+
+// WithLocalNameTable sets the name table of the language of the recorded host, e.g. to replay a German host.
+func (s *ReplaySource) WithLocalNameTable(localized *NameTable) *ReplaySource {
+	s.localNames = NewNameMapping(s.names, localized)
+
+	return s
+}
+
+// LoadReplaySource loads a counter name table and recorded data blocks from files.
+func LoadReplaySource(nameTableFile string, blockFiles ...string) (*ReplaySource, error) {
+	data, err := os.ReadFile(nameTableFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read name table: %w", err)
+	}
+
+	blocks := make([][]byte, 0, len(blockFiles))
+
+	for _, file := range blockFiles {
+		block, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data block: %w", err)
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return NewReplaySource(NewNameTable(data), blocks...), nil
+}
+
+func (s *ReplaySource) Query(string) (io.ReadSeeker, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.blocks) == 0 {
+		return nil, errors.New("no recorded data blocks")
+	}
+
+	block := s.blocks[s.next]
+
+	if s.next < len(s.blocks)-1 {
+		s.next++
+	}
+
+	return bytes.NewReader(block), nil
+}
+
+func (s *ReplaySource) NameTable() *NameTable {
+	return s.names
+}
+
+func (s *ReplaySource) LocalNames() *NameMapping {
+	return s.localNames
+}
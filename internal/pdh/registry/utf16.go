@@ -3,8 +3,7 @@ package registry
 import (
 	"encoding/binary"
 	"io"
-
-	"golang.org/x/sys/windows"
+	"unicode/utf16"
 )
 
 // readUTF16StringAtPos Read an unterminated UTF16 string at a given position, specifying its length.
@@ -21,18 +20,18 @@ func readUTF16StringAtPos(r io.ReadSeeker, absPos int64, length uint32) (string,
 		return "", err
 	}
 
-	return windows.UTF16ToString(value), nil
+	return utf16ToString(value), nil
 }
 
 // readUTF16String Reads a null-terminated UTF16 string at the current offset.
 func readUTF16String(r io.Reader) (string, error) {
-	var err error
-
 	b := make([]byte, 2)
 	out := make([]uint16, 0, 100)
 
-	for i := 0; err == nil; i += 2 {
-		_, err = r.Read(b)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err
+		}
 
 		if b[0] == 0 && b[1] == 0 {
 			break
@@ -41,9 +40,18 @@ func readUTF16String(r io.Reader) (string, error) {
 		out = append(out, bo.Uint16(b))
 	}
 
-	if err != nil {
-		return "", err
+	return utf16ToString(out), nil
+}
+
+// utf16ToString Decodes UTF16 up to the first null character, like windows.UTF16ToString.
+func utf16ToString(s []uint16) string {
+	for i, v := range s {
+		if v == 0 {
+			s = s[:i]
+
+			break
+		}
 	}
 
-	return windows.UTF16ToString(out), nil
+	return string(utf16.Decode(s))
 }
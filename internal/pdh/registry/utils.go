@@ -1,9 +1,73 @@
+//go:build windows
+
 package registry
 
 import (
 	"strconv"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// CounterNameTable Initialize global name tables
+// profiling, add option to disable name tables if necessary
+// Not sure if we should resolve the names at all or just have the caller do it on demand
+// (for many use cases the index is sufficient)
+//
+//nolint:gochecknoglobals
+var CounterNameTable = *QueryNameTable("Counter 009")
+
+//nolint:gochecknoglobals
+var (
+	counterNameTables = &NameTables{
+		load: func(language string) ([]byte, error) {
+			return queryRawData("Counter " + language)
+		},
+		tables: map[string]*NameTable{
+			EnglishLanguage: &CounterNameTable,
+		},
+	}
+
+	localLanguage = sync.OnceValue(queryLocalLanguage)
 )
 
+// QueryNameTable Query a perflib name table from the v1. Specify the type and the language
+// code (i.e. "Counter 009" or "Help 009") for English language.
+func QueryNameTable(tableName string) *NameTable {
+	return &NameTable{
+		load: func() ([]byte, error) {
+			return queryRawData(tableName)
+		},
+	}
+}
+
+// CounterNameTables returns the cached counter name tables of the installed languages.
+func CounterNameTables() *NameTables {
+	return counterNameTables
+}
+
+// LocalNames maps the English object and counter names to the language of the host and back.
+// On English hosts, all names are returned as is.
+func LocalNames() *NameMapping {
+	return counterNameTables.Mapping(localLanguage())
+}
+
+// queryLocalLanguage returns the language code of the system UI language, which perflib uses to
+// localize the names, e.g. "007" for German. The primary language of a LANGID are its lower 10 bits.
+func queryLocalLanguage() string {
+	languages, err := windows.GetSystemPreferredUILanguages(windows.MUI_LANGUAGE_ID)
+	if err != nil || len(languages) == 0 {
+		return EnglishLanguage
+	}
+
+	langID, err := strconv.ParseUint(languages[0], 16, 16)
+	if err != nil {
+		return EnglishLanguage
+	}
+
+	return formatLanguage(langID & 0x3ff)
+}
+
 func MapCounterToIndex(name string) string {
 	return strconv.Itoa(int(CounterNameTable.LookupIndex(name)))
 }
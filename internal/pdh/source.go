@@ -0,0 +1,65 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdh
+
+import "sync"
+
+// Sample is the raw value of a counter instance, as returned by GetRawCounterArray.
+type Sample struct {
+	Counter     string `json:"counter"`
+	Instance    string `json:"instance"`
+	Type        uint32 `json:"type"`
+	FirstValue  int64  `json:"first_value"`
+	SecondValue int64  `json:"second_value"`
+	// Frequency is the time base of counter types, which are measured in ticks, see UsesFrequency.
+	Frequency int64 `json:"frequency,omitempty"`
+}
+
+// Source provides the raw counter values of a performance counter object to a Collector.
+type Source interface {
+	// Describe returns the explain texts of the counters by counter name.
+	Describe() map[string]string
+	// Collect returns the raw values of all counter instances with valid data.
+	Collect() ([]Sample, error)
+	Close()
+}
+
+// SourceFactory creates the source for the counters and instances of an object.
+// A source may be returned alongside an error, if some counters could not be added.
+type SourceFactory func(object string, counters []string, instances []string) (Source, error)
+
+//nolint:gochecknoglobals
+var (
+	sourceFactoryMu sync.RWMutex
+	sourceFactory   SourceFactory = NewQuerySource
+)
+
+// SetSourceFactory replaces the factory used by NewCollector and returns the previous one.
+// It allows tests to replay recorded counter values, see ReplaySourceFactory.
+func SetSourceFactory(factory SourceFactory) SourceFactory {
+	sourceFactoryMu.Lock()
+	defer sourceFactoryMu.Unlock()
+
+	previous := sourceFactory
+	sourceFactory = factory
+
+	return previous
+}
+
+func newSource(object string, counters []string, instances []string) (Source, error) {
+	sourceFactoryMu.RLock()
+	defer sourceFactoryMu.RUnlock()
+
+	return sourceFactory(object, counters, instances)
+}
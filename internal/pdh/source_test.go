@@ -0,0 +1,130 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pdh_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type threads struct {
+	Name        string
+	ThreadCount float64 `perfdata:"Thread Count"`
+}
+
+// scriptedSource returns the results of results in order and repeats the last one.
+type scriptedSource struct {
+	results []scriptedResult
+	next    int
+}
+
+type scriptedResult struct {
+	samples []pdh.Sample
+	err     error
+}
+
+func (s *scriptedSource) Describe() map[string]string { return map[string]string{} }
+
+func (s *scriptedSource) Collect() ([]pdh.Sample, error) {
+	result := s.results[min(s.next, len(s.results)-1)]
+	s.next++
+
+	return result.samples, result.err
+}
+
+func (s *scriptedSource) Close() {}
+
+func TestCollectorStaleQuery(t *testing.T) {
+	samples := []pdh.Sample{
+		{Counter: "Thread Count", Instance: "w3wp", Type: pdh.PERF_COUNTER_RAWCOUNT, FirstValue: 12},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		results []scriptedResult
+		// errs are the expected errors of the collections after the initial one.
+		errs []error
+	}{
+		{
+			name: "invalid handle",
+			results: []scriptedResult{
+				{samples: samples},
+				{err: pdh.NewPdhError(pdh.InvalidHandle)},
+			},
+			errs: []error{pdh.ErrStaleQuery},
+		},
+		{
+			name: "counter status",
+			results: []scriptedResult{
+				{samples: samples},
+				{err: pdh.NewPdhError(pdh.CstatusNoObject)},
+			},
+			errs: []error{pdh.ErrStaleQuery},
+		},
+		{
+			name: "other errors are not stale",
+			results: []scriptedResult{
+				{samples: samples},
+				{err: errors.New("access denied")},
+			},
+			errs: []error{nil},
+		},
+		{
+			// Objects may have no instances for a while, which is not a stale query.
+			name: "no instances",
+			results: []scriptedResult{
+				{samples: samples},
+				{},
+			},
+			errs: []error{pdh.ErrNoData, pdh.ErrNoData, pdh.ErrNoData, pdh.ErrNoData, nil},
+		},
+		{
+			name: "never collected data",
+			results: []scriptedResult{
+				{},
+			},
+			errs: []error{pdh.ErrNoData, pdh.ErrNoData, pdh.ErrNoData, nil},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			previous := pdh.SetSourceFactory(func(string, []string, []string) (pdh.Source, error) {
+				return &scriptedSource{results: tc.results}, nil
+			})
+			defer pdh.SetSourceFactory(previous)
+
+			collector, err := pdh.NewCollector[threads]("Process", pdh.InstancesAll)
+			require.NoError(t, err)
+
+			defer collector.Close()
+
+			var data []threads
+
+			for i, expected := range tc.errs {
+				err := collector.Collect(&data)
+
+				if expected == nil {
+					assert.NotErrorIs(t, err, pdh.ErrStaleQuery, "collection %d", i)
+
+					continue
+				}
+
+				require.ErrorIs(t, err, expected, "collection %d", i)
+			}
+		})
+	}
+}
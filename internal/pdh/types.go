@@ -15,21 +15,7 @@
 
 package pdh
 
-import (
-	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/sys/windows"
-)
-
-const (
-	InstanceEmpty = "------"
-	InstanceTotal = "_Total"
-)
-
-type CounterValue struct {
-	Type        prometheus.ValueType
-	FirstValue  float64
-	SecondValue float64
-}
+import "golang.org/x/sys/windows"
 
 // FmtCounterValueDouble is a union specialization for double values.
 type FmtCounterValueDouble struct {
@@ -0,0 +1,42 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package probe
+
+import (
+	"github.com/prometheus-community/windows_exporter/internal/collector/cpu_info"
+	"github.com/prometheus-community/windows_exporter/internal/collector/diskdrive"
+	"github.com/prometheus-community/windows_exporter/internal/collector/fsrmquota"
+	"github.com/prometheus-community/windows_exporter/internal/collector/mscluster"
+	"github.com/prometheus-community/windows_exporter/internal/collector/netframework"
+	"github.com/prometheus-community/windows_exporter/internal/collector/printer"
+	"github.com/prometheus-community/windows_exporter/pkg/collector"
+)
+
+// Builder creates a new instance of a collector with its default configuration.
+type Builder func() collector.Collector
+
+// Builders are the collectors, which solely rely on the MI session and therefore support remote targets.
+// Collectors based on performance counters or local Windows APIs are not supported.
+//
+//nolint:gochecknoglobals
+var Builders = map[string]Builder{
+	cpu_info.Name:     func() collector.Collector { return cpu_info.New(nil) },
+	diskdrive.Name:    func() collector.Collector { return diskdrive.New(nil) },
+	fsrmquota.Name:    func() collector.Collector { return fsrmquota.New(nil) },
+	mscluster.Name:    func() collector.Collector { return mscluster.New(nil) },
+	netframework.Name: func() collector.Collector { return netframework.New(nil) },
+	printer.Name:      func() collector.Collector { return printer.New(nil) },
+}
@@ -0,0 +1,332 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultTimeout = 10 * time.Second
+
+//nolint:gochecknoglobals
+var authenticationTypes = map[string]string{
+	"default":   mi.AuthTypeDefault,
+	"negotiate": mi.AuthTypeNegoWithCred,
+	"kerberos":  mi.AuthTypeKerberos,
+	"ntlm":      mi.AuthTypeNTLM,
+	"basic":     mi.AuthTypeBasic,
+	"digest":    mi.AuthTypeDigest,
+	"credssp":   mi.AuthTypeCredSSP,
+}
+
+// Config is the configuration of the probe endpoint.
+type Config struct {
+	Modules map[string]*Module `yaml:"modules"`
+}
+
+// Module describes how a target is probed.
+type Module struct {
+	// Collectors are the MI based collectors run against the target. See [Builders].
+	Collectors []string `yaml:"collectors"`
+	// Protocol is either winrm or dcom.
+	Protocol string `yaml:"protocol"`
+	// Transport is either http or https. Only used by the winrm protocol.
+	Transport string `yaml:"transport"`
+	// Port overrides the default port of the winrm protocol.
+	Port uint32 `yaml:"port"`
+	// Timeout is the timeout of the connection to the target.
+	Timeout time.Duration `yaml:"timeout"`
+	// Authentication is one of default, negotiate, kerberos, ntlm, basic, digest or credssp.
+	Authentication string `yaml:"authentication"`
+	// Domain, Username and Password are the credentials used to authenticate at the target.
+	// Without a username, the identity of the exporter is used.
+	Domain       string `yaml:"domain"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	// AllowedTargets are the targets, which can be probed with the module. Entries are either glob patterns
+	// matching the host name or networks in CIDR notation. The list is required, since the credentials of the module,
+	// or the identity of the exporter, are sent to the target. "*" allows all targets.
+	AllowedTargets []string `yaml:"allowed_targets"`
+}
+
+// LoadConfig reads and validates the configuration file. Unknown fields are rejected.
+func LoadConfig(file string) (*Config, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read probe config file: %w", err)
+	}
+
+	config := &Config{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	if err = decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to parse probe config file: %w", err)
+	}
+
+	if err = config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid probe config file: %w", err)
+	}
+
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Modules) == 0 {
+		return errors.New("at least one module is required")
+	}
+
+	errs := make([]error, 0, len(c.Modules))
+
+	for name, module := range c.Modules {
+		if module == nil {
+			errs = append(errs, fmt.Errorf("module %s: empty module", name))
+
+			continue
+		}
+
+		if err := module.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("module %s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Module returns the module with the given name.
+func (c *Config) Module(name string) (*Module, error) {
+	module, ok := c.Modules[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown module %q", name)
+	}
+
+	return module, nil
+}
+
+// validate checks the module and applies the defaults.
+func (m *Module) validate() error {
+	var errs []error
+
+	if len(m.Collectors) == 0 {
+		errs = append(errs, errors.New("at least one collector is required"))
+	}
+
+	for _, name := range m.Collectors {
+		if _, ok := Builders[name]; !ok {
+			errs = append(errs, fmt.Errorf("collector %s does not support remote targets", name))
+		}
+	}
+
+	m.Protocol = strings.ToLower(m.Protocol)
+
+	switch m.Protocol {
+	case "":
+		m.Protocol = "winrm"
+	case "winrm", "dcom":
+	default:
+		errs = append(errs, fmt.Errorf("unknown protocol %q, must be one of winrm, dcom", m.Protocol))
+	}
+
+	m.Transport = strings.ToLower(m.Transport)
+
+	switch {
+	case m.Transport == "":
+	case m.Protocol != "winrm":
+		errs = append(errs, errors.New("transport is only supported by the winrm protocol"))
+	case m.Transport != "http" && m.Transport != "https":
+		errs = append(errs, fmt.Errorf("unknown transport %q, must be one of http, https", m.Transport))
+	}
+
+	if m.Port != 0 && (m.Protocol != "winrm" || m.Port > 65535) {
+		errs = append(errs, fmt.Errorf("invalid port %d, only ports of the winrm protocol can be set", m.Port))
+	}
+
+	if m.Timeout <= 0 {
+		m.Timeout = DefaultTimeout
+	}
+
+	m.Authentication = strings.ToLower(m.Authentication)
+	if m.Authentication == "" {
+		m.Authentication = "default"
+	}
+
+	if _, ok := authenticationTypes[m.Authentication]; !ok {
+		errs = append(errs, fmt.Errorf("unknown authentication %q", m.Authentication))
+	}
+
+	if m.Domain == "" {
+		// Accept DOMAIN\user as well.
+		if domain, username, ok := strings.Cut(m.Username, `\`); ok {
+			m.Domain, m.Username = domain, username
+		}
+	}
+
+	if m.Password != "" && m.PasswordFile != "" {
+		errs = append(errs, errors.New("at most one of password and password_file must be set"))
+	}
+
+	if m.Username == "" && (m.Password != "" || m.PasswordFile != "") {
+		errs = append(errs, errors.New("password requires a username"))
+	}
+
+	if len(m.AllowedTargets) == 0 {
+		if m.Username != "" {
+			errs = append(errs, errors.New("allowed_targets is required, since the credentials of the module are sent to the target"))
+		} else {
+			errs = append(errs, errors.New("allowed_targets is required, since the identity of the exporter is sent to the target"))
+		}
+	}
+
+	for _, pattern := range m.AllowedTargets {
+		if strings.Contains(pattern, "/") {
+			if _, err := netip.ParsePrefix(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("invalid allowed target %q: %w", pattern, err))
+			}
+
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid allowed target %q: %w", pattern, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// MIProtocol returns the MI protocol handler of the module.
+func (m *Module) MIProtocol() string {
+	if m.Protocol == "dcom" {
+		return mi.ProtocolDCOM
+	}
+
+	return mi.ProtocolWinRM
+}
+
+// MIAuthenticationType returns the MI authentication type of the module.
+func (m *Module) MIAuthenticationType() string {
+	return authenticationTypes[m.Authentication]
+}
+
+// GetPassword returns the password of the module. The password file is read on each call, so it can be rotated
+// without a restart.
+func (m *Module) GetPassword() (string, error) {
+	if m.PasswordFile == "" {
+		return m.Password, nil
+	}
+
+	content, err := os.ReadFile(m.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password file: %w", err)
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// ResolveTarget validates the target parameter of a probe and returns the destination passed to MI.
+// Targets are host names, IPv4 or IPv6 addresses. Ports, schemes and paths are rejected, since they are
+// configured by the module. Host names are lowercased and IPv6 addresses are enclosed in brackets for WinRM.
+func (m *Module) ResolveTarget(target string) (string, error) {
+	if target == "" {
+		return "", errors.New("target parameter is missing")
+	}
+
+	host := strings.ToLower(target)
+
+	var addr netip.Addr
+
+	if inner, ok := strings.CutPrefix(host, "["); ok {
+		inner, ok = strings.CutSuffix(inner, "]")
+		if !ok {
+			return "", fmt.Errorf("invalid target %q", target)
+		}
+
+		host = inner
+	}
+
+	if parsed, err := netip.ParseAddr(host); err == nil {
+		if parsed.Zone() != "" {
+			return "", fmt.Errorf("invalid target %q: zones are not supported", target)
+		}
+
+		addr = parsed.Unmap()
+		host = addr.String()
+	} else if !isHostname(host) {
+		return "", fmt.Errorf("invalid target %q: must be a host name or an IP address", target)
+	}
+
+	if !m.targetAllowed(host, addr) {
+		return "", fmt.Errorf("target %q is not allowed by the module", target)
+	}
+
+	if addr.Is6() && m.Protocol == "winrm" {
+		return "[" + host + "]", nil
+	}
+
+	return host, nil
+}
+
+// targetAllowed reports whether the target matches one of the allowed targets. Without allowed targets,
+// all targets are denied.
+func (m *Module) targetAllowed(host string, addr netip.Addr) bool {
+	return slices.ContainsFunc(m.AllowedTargets, func(pattern string) bool {
+		if strings.Contains(pattern, "/") {
+			prefix, err := netip.ParsePrefix(pattern)
+
+			return err == nil && addr.IsValid() && prefix.Contains(addr)
+		}
+
+		matched, _ := path.Match(strings.ToLower(pattern), host)
+
+		return matched
+	})
+}
+
+// isHostname reports whether host is a valid DNS or NetBIOS host name.
+func isHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+
+	if host == "" || len(host) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
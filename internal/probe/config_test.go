@@ -0,0 +1,173 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "probe.yml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+
+	return file
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("secret\r\n"), 0o600))
+
+	config, err := LoadConfig(writeConfig(t, `
+modules:
+  default:
+    collectors: [cpu_info, diskdrive]
+    allowed_targets: ["*"]
+  cluster:
+    collectors: [mscluster]
+    protocol: WinRM
+    transport: https
+    port: 5986
+    timeout: 30s
+    authentication: kerberos
+    username: CORP\svc-monitoring
+    password_file: `+passwordFile+`
+    allowed_targets: ["*.corp.example.com", "10.0.0.0/8"]
+  legacy:
+    collectors: [printer]
+    protocol: dcom
+    allowed_targets: ["192.168.0.0/16"]
+`))
+	require.NoError(t, err)
+
+	module, err := config.Module("default")
+	require.NoError(t, err)
+	assert.Equal(t, "winrm", module.Protocol)
+	assert.Equal(t, mi.ProtocolWinRM, module.MIProtocol())
+	assert.Equal(t, DefaultTimeout, module.Timeout)
+	assert.Equal(t, mi.AuthTypeDefault, module.MIAuthenticationType())
+
+	module, err = config.Module("cluster")
+	require.NoError(t, err)
+	assert.Equal(t, "winrm", module.Protocol)
+	assert.Equal(t, "https", module.Transport)
+	assert.Equal(t, uint32(5986), module.Port)
+	assert.Equal(t, 30*time.Second, module.Timeout)
+	assert.Equal(t, mi.AuthTypeKerberos, module.MIAuthenticationType())
+	assert.Equal(t, "CORP", module.Domain)
+	assert.Equal(t, "svc-monitoring", module.Username)
+
+	password, err := module.GetPassword()
+	require.NoError(t, err)
+	assert.Equal(t, "secret", password)
+
+	// The password file is re-read on each probe.
+	require.NoError(t, os.WriteFile(passwordFile, []byte("rotated"), 0o600))
+
+	password, err = module.GetPassword()
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", password)
+
+	module, err = config.Module("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, mi.ProtocolDCOM, module.MIProtocol())
+
+	_, err = config.Module("unknown")
+	require.ErrorContains(t, err, `unknown module "unknown"`)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"no modules", "modules: {}\n", "at least one module is required"},
+		{"unknown field", "modules:\n  default:\n    collectors: [cpu_info]\n    hostname: foo\n", "field hostname not found"},
+		{"no allowed targets", "modules:\n  default:\n    collectors: [cpu_info]\n", "allowed_targets is required, since the identity of the exporter is sent to the target"},
+		{"credentials without allowed targets", "modules:\n  default:\n    collectors: [cpu_info]\n    authentication: basic\n    username: monitoring\n    password: secret\n", "allowed_targets is required, since the credentials of the module are sent to the target"},
+		{"no collectors", "modules:\n  default:\n    protocol: winrm\n", "at least one collector is required"},
+		{"local collector", "modules:\n  default:\n    collectors: [cpu]\n", "collector cpu does not support remote targets"},
+		{"unknown protocol", "modules:\n  default:\n    collectors: [cpu_info]\n    protocol: ssh\n", `unknown protocol "ssh"`},
+		{"dcom transport", "modules:\n  default:\n    collectors: [cpu_info]\n    protocol: dcom\n    transport: https\n", "transport is only supported by the winrm protocol"},
+		{"unknown authentication", "modules:\n  default:\n    collectors: [cpu_info]\n    authentication: oauth\n", `unknown authentication "oauth"`},
+		{"password without username", "modules:\n  default:\n    collectors: [cpu_info]\n    password: secret\n", "password requires a username"},
+		{"invalid allowed target", "modules:\n  default:\n    collectors: [cpu_info]\n    allowed_targets: [\"10.0.0.0/33\"]\n", `invalid allowed target "10.0.0.0/33"`},
+	} {
+		_, err := LoadConfig(writeConfig(t, tc.content))
+		require.ErrorContains(t, err, tc.err, tc.name)
+	}
+}
+
+func TestResolveTarget(t *testing.T) {
+	t.Parallel()
+
+	winrm := &Module{Protocol: "winrm", AllowedTargets: []string{"*.corp.example.com", "sql-??", "10.0.0.0/8", "2001:db8::/32"}}
+	dcom := &Module{Protocol: "dcom", AllowedTargets: []string{"*"}}
+	denied := &Module{Protocol: "winrm"}
+
+	for _, tc := range []struct {
+		name        string
+		module      *Module
+		target      string
+		destination string
+		err         string
+	}{
+		{"host name", winrm, "SRV01.corp.example.com", "srv01.corp.example.com", ""},
+		{"netbios name", winrm, "SQL-01", "sql-01", ""},
+		{"IPv4", winrm, "10.1.2.3", "10.1.2.3", ""},
+		{"IPv6", winrm, "2001:db8::1", "[2001:db8::1]", ""},
+		{"bracketed IPv6", winrm, "[2001:db8::1]", "[2001:db8::1]", ""},
+		{"IPv6 via dcom", dcom, "[2001:db8::1]", "2001:db8::1", ""},
+		{"IPv4-mapped IPv6", winrm, "::ffff:10.1.2.3", "10.1.2.3", ""},
+		{"any host name", dcom, "anything", "anything", ""},
+		{"no allow list", denied, "srv01", "", "is not allowed by the module"},
+		{"no allow list IP", denied, "10.1.2.3", "", "is not allowed by the module"},
+		{"missing", winrm, "", "", "target parameter is missing"},
+		{"not allowed host", winrm, "srv01.example.com", "", "is not allowed by the module"},
+		{"not allowed IP", winrm, "192.168.1.1", "", "is not allowed by the module"},
+		{"port", winrm, "srv01.corp.example.com:5985", "", "must be a host name or an IP address"},
+		{"URL", winrm, "http://srv01.corp.example.com", "", "must be a host name or an IP address"},
+		{"path", dcom, "srv01/root", "", "must be a host name or an IP address"},
+		{"UNC path", dcom, `\\srv01`, "", "must be a host name or an IP address"},
+		{"whitespace", dcom, "srv 01", "", "must be a host name or an IP address"},
+		{"leading hyphen", dcom, "-srv01", "", "must be a host name or an IP address"},
+		{"zone", dcom, "fe80::1%eth0", "", "zones are not supported"},
+		{"unterminated bracket", dcom, "[2001:db8::1", "", "invalid target"},
+	} {
+		destination, err := tc.module.ResolveTarget(tc.target)
+		if tc.err != "" {
+			require.ErrorContains(t, err, tc.err, tc.name)
+
+			continue
+		}
+
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.destination, destination, tc.name)
+	}
+}
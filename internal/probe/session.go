@@ -0,0 +1,151 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+)
+
+// Session is a connection to a target, which is shared by the collectors of a probe.
+type Session interface {
+	// MISession returns the session passed to the collectors.
+	MISession() *mi.Session
+	// Close closes the connection to the target.
+	Close() error
+}
+
+// SessionFactory opens sessions to targets.
+type SessionFactory interface {
+	// NewSession opens a session to the destination returned by [Module.ResolveTarget].
+	NewSession(destination string, module *Module) (Session, error)
+}
+
+// MISessionFactory opens MI sessions to remote targets.
+type MISessionFactory struct {
+	// mu guards app against being closed while sessions are opened.
+	mu  sync.RWMutex
+	app *mi.Application
+}
+
+// NewMISessionFactory initializes the MI application used for all remote sessions.
+func NewMISessionFactory() (*MISessionFactory, error) {
+	app, err := mi.Application_Initialize()
+	if err != nil {
+		return nil, fmt.Errorf("error from initialize MI application: %w", err)
+	}
+
+	return &MISessionFactory{app: app}, nil
+}
+
+// NewSession opens a session to the destination using the protocol and credentials of the module.
+// The connection is tested before the session is returned.
+func (f *MISessionFactory) NewSession(destination string, module *Module) (Session, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.app == nil {
+		return nil, mi.ErrNotInitialized
+	}
+
+	destinationOptions, err := f.app.NewDestinationOptions()
+	if err != nil {
+		return nil, fmt.Errorf("error from create NewDestinationOptions: %w", err)
+	}
+
+	defer func() {
+		_ = destinationOptions.Delete()
+	}()
+
+	if err = destinationOptions.SetLocale(mi.LocaleEnglish); err != nil {
+		return nil, fmt.Errorf("error from set locale: %w", err)
+	}
+
+	if err = destinationOptions.SetTimeout(module.Timeout); err != nil {
+		return nil, fmt.Errorf("error from set timeout: %w", err)
+	}
+
+	if module.Transport != "" {
+		if err = destinationOptions.SetTransport(strings.ToUpper(module.Transport)); err != nil {
+			return nil, fmt.Errorf("error from set transport: %w", err)
+		}
+	}
+
+	if module.Port != 0 {
+		if err = destinationOptions.SetPort(module.Port); err != nil {
+			return nil, fmt.Errorf("error from set port: %w", err)
+		}
+	}
+
+	if module.Username != "" {
+		password, err := module.GetPassword()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = destinationOptions.AddCredentials(module.MIAuthenticationType(), module.Domain, module.Username, password); err != nil {
+			return nil, fmt.Errorf("error from add credentials: %w", err)
+		}
+	}
+
+	session, err := f.app.NewRemoteSession(module.MIProtocol(), destination, destinationOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error from create NewSession: %w", err)
+	}
+
+	if err = session.TestConnection(); err != nil {
+		_ = session.Close()
+
+		return nil, fmt.Errorf("error from test connection: %w", err)
+	}
+
+	return miSession{session}, nil
+}
+
+// Close closes the MI application. Sessions must be closed before.
+func (f *MISessionFactory) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.app == nil {
+		return nil
+	}
+
+	err := f.app.Close()
+	f.app = nil
+
+	if err != nil && !errors.Is(err, mi.ErrNotInitialized) {
+		return fmt.Errorf("error from close MI application: %w", err)
+	}
+
+	return nil
+}
+
+type miSession struct {
+	session *mi.Session
+}
+
+func (s miSession) MISession() *mi.Session {
+	return s.session
+}
+
+func (s miSession) Close() error {
+	return s.session.Close()
+}
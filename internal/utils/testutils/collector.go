@@ -0,0 +1,202 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/pkg/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:gochecknoglobals
+var (
+	recordFlag = flag.Bool("pdh.record", false, "Record the performance counters of the collector to testdata/pdh and update the golden file.")
+	updateFlag = flag.Bool("update", false, "Update the golden file of collectors with recorded performance counters.")
+)
+
+const (
+	// recordingDir holds the performance counter recordings of a collector package.
+	recordingDir = "testdata/pdh"
+	// goldenFile holds the metrics of the collector derived from the recordings.
+	goldenFile = "testdata/metrics.prom"
+)
+
+// TestCollector builds the collector and collects once.
+//
+// If the package has performance counter data in testdata/pdh, the data is replayed instead of querying
+// the live counters, and the collected metrics are compared with testdata/metrics.prom. The data is either
+// recorded on a host or a synthetic fixture, see pdh.Recording.Origin.
+// Replayed collectors are built without an MI session, so they run on any OS. Live collections require
+// Windows and are skipped elsewhere. Run the test with -pdh.record on a Windows host to record the counters,
+// or with -update to regenerate the golden file from the recordings.
+func TestCollector[C collector.Collector, V interface{}](t *testing.T, fn func(*V) C, conf *V) {
+	t.Helper()
+
+	var (
+		metrics   []prometheus.Metric
+		err       error
+		miSession *mi.Session
+	)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err = os.Stat(recordingDir)
+	replay := err == nil && !*recordFlag
+
+	switch {
+	case replay:
+		logSyntheticFixtures(t)
+
+		previous := pdh.SetSourceFactory(pdh.ReplaySourceFactory(recordingDir))
+
+		t.Cleanup(func() {
+			pdh.SetSourceFactory(previous)
+		})
+	default:
+		miSession = newMISession(t)
+
+		if *recordFlag {
+			previous := pdh.SetSourceFactory(pdh.RecordSourceFactory(recordingDir, pdh.NewQuerySource))
+
+			t.Cleanup(func() {
+				pdh.SetSourceFactory(previous)
+			})
+		}
+	}
+
+	c := fn(conf)
+	ch := make(chan prometheus.Metric, 10000)
+
+	t.Cleanup(func() {
+		require.NoError(t, c.Close())
+	})
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for metric := range ch {
+			metrics = append(metrics, metric)
+		}
+	}()
+
+	err = c.Build(logger, miSession)
+
+	switch {
+	case err == nil:
+	case replay:
+		require.NoError(t, err)
+	case isUnsupportedBuildError(err):
+	default:
+		require.NoError(t, err)
+	}
+
+	if !replay {
+		time.Sleep(1 * time.Second)
+	}
+
+	err = c.Collect(ch)
+
+	switch {
+	case replay:
+		require.NoError(t, err)
+	case isUnsupportedCollectError(err):
+		t.Skip("collector not supported on this system")
+	default:
+		require.NoError(t, err)
+	}
+
+	close(ch)
+
+	wg.Wait()
+
+	if replay || *recordFlag {
+		compareGoldenFile(t, metrics)
+	}
+}
+
+// logSyntheticFixtures logs the files in testdata/pdh, which weren't recorded on a host.
+func logSyntheticFixtures(t *testing.T) {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(recordingDir, "*.json"))
+	require.NoError(t, err)
+
+	for _, file := range files {
+		recording, err := pdh.LoadRecording(file)
+		require.NoError(t, err)
+
+		if recording.Origin == pdh.OriginSynthetic {
+			t.Logf("%s is a synthetic fixture, not a recording of a real host", filepath.ToSlash(file))
+		}
+	}
+}
+
+// compareGoldenFile compares the metrics with testdata/metrics.prom. The file is written instead,
+// if the counters are recorded or -update is set.
+func compareGoldenFile(t *testing.T, metrics []prometheus.Metric) {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metricsCollector(metrics))
+
+	if *recordFlag || *updateFlag {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+
+		for _, family := range families {
+			_, err = expfmt.MetricFamilyToText(&buf, family)
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(goldenFile), 0o755))
+		require.NoError(t, os.WriteFile(goldenFile, buf.Bytes(), 0o644)) //nolint:gosec
+	}
+
+	golden, err := os.Open(goldenFile)
+	require.NoError(t, err)
+
+	defer golden.Close()
+
+	require.NoError(t, testutil.GatherAndCompare(registry, golden))
+}
+
+// metricsCollector is an unchecked collector, which sends the collected metrics.
+type metricsCollector []prometheus.Metric
+
+func (metricsCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (m metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, metric := range m {
+		ch <- metric
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package testutils
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus-community/windows_exporter/internal/pdh/registry"
+	"github.com/stretchr/testify/require"
+)
+
+// parityFrequency is the time base of the generated counter values.
+const parityFrequency = 10_000_000
+
+/*
+TestBackendParity collects the perfdata tagged fields of V with the pdh and the registry backend from the same
+raw counter values, and requires both backends to return the same values.
+
+A value is generated for each counter and instance. counterTypes sets the type of the counters, others are
+PERF_COUNTER_LARGE_RAWCOUNT. Fractions, averages and precision timers get a base counter, which PDH reports
+as second value. If instances is nil, the object has no instances, otherwise the instances "0", "1" and "_Total".
+
+The test replaces the PDH source factory, so it must not run in parallel with other collector tests.
+*/
+func TestBackendParity[V any](t *testing.T, object string, instances []string, counterTypes map[string]uint32) {
+	t.Helper()
+
+	valueType := reflect.TypeFor[V]()
+	counters := parityCounters(valueType)
+
+	instanceNames := []string{""}
+	if instances != nil {
+		instanceNames = []string{"0", "1", pdh.InstanceTotal}
+	}
+
+	names := []registry.NameTableEntry{{Index: 2, Name: object}}
+	objectData := registry.ObjectData{NameIndex: 2, Frequency: parityFrequency}
+	snapshot := make([]pdh.Sample, 0, len(counters)*len(instanceNames))
+	values := make([][]uint64, len(instanceNames))
+
+	for i, counter := range counters {
+		counterType, ok := counterTypes[counter]
+		if !ok {
+			counterType = pdh.PERF_COUNTER_LARGE_RAWCOUNT
+		}
+
+		index := uint32(4 + 4*i)
+		baseType, hasBase := baseCounterType(counterType)
+
+		names = append(names, registry.NameTableEntry{Index: index, Name: counter})
+		objectData.Counters = append(objectData.Counters, registry.CounterData{NameIndex: index, CounterType: counterType, Size: 8})
+
+		if hasBase {
+			names = append(names, registry.NameTableEntry{Index: index + 2, Name: counter + " Base"})
+			objectData.Counters = append(objectData.Counters, registry.CounterData{NameIndex: index + 2, CounterType: baseType, Size: 8})
+		}
+
+		for j, instance := range instanceNames {
+			firstValue := int64(1_000_000*(j+1) + 1_000*(i+1))
+			if counterType == pdh.PERF_ELAPSED_TIME {
+				firstValue += pdh.WindowsEpoch + 1_700_000_000*parityFrequency
+			}
+
+			secondValue := int64(0)
+			if hasBase {
+				secondValue = 2*firstValue + 1
+			}
+
+			values[j] = append(values[j], uint64(firstValue))
+			if hasBase {
+				values[j] = append(values[j], uint64(secondValue))
+			}
+
+			snapshot = append(snapshot, pdh.Sample{
+				Counter:     counter,
+				Instance:    instance,
+				Type:        counterType,
+				FirstValue:  firstValue,
+				SecondValue: secondValue,
+				Frequency:   parityFrequency,
+			})
+		}
+	}
+
+	if instances == nil {
+		objectData.Values = values[0]
+	} else {
+		for j, instance := range instanceNames {
+			objectData.Instances = append(objectData.Instances, registry.InstanceData{Name: instance, Values: values[j]})
+		}
+	}
+
+	recording := &pdh.Recording{Object: object, Snapshots: [][]pdh.Sample{snapshot}}
+	previous := pdh.SetSourceFactory(func(_ string, counters []string, _ []string) (pdh.Source, error) {
+		return pdh.NewReplaySource(recording, counters), nil
+	})
+
+	t.Cleanup(func() {
+		pdh.SetSourceFactory(previous)
+	})
+
+	pdhCollector, err := pdh.NewCollectorWithReflection(object, instances, valueType)
+	require.NoError(t, err)
+
+	defer pdhCollector.Close()
+
+	var pdhValues []V
+
+	require.NoError(t, pdhCollector.Collect(&pdhValues))
+
+	source := registry.NewReplaySource(
+		registry.NewNameTable(registry.EncodeNameTable(names)),
+		registry.EncodePerformanceData([]registry.ObjectData{objectData}),
+	)
+
+	registryCollector, err := registry.NewCollectorWithReflection(source, object, instances, valueType)
+	require.NoError(t, err)
+
+	defer registryCollector.Close()
+
+	var registryValues []V
+
+	require.NoError(t, registryCollector.Collect(&registryValues))
+
+	require.NotEmpty(t, pdhValues)
+	require.Equal(t, pdhValues, registryValues)
+}
+
+// parityCounters returns the counter names of the perfdata tagged fields of valueType.
+func parityCounters(valueType reflect.Type) []string {
+	counters := make([]string, 0, valueType.NumField())
+
+	for _, f := range reflect.VisibleFields(valueType) {
+		counter, ok := f.Tag.Lookup("perfdata")
+		if !ok {
+			continue
+		}
+
+		counter = strings.TrimSuffix(counter, ",secondvalue")
+
+		if !slices.Contains(counters, counter) {
+			counters = append(counters, counter)
+		}
+	}
+
+	return counters
+}
+
+// baseCounterType returns the type of the base counter, which follows a counter of the given type.
+func baseCounterType(counterType uint32) (uint32, bool) {
+	switch counterType {
+	case pdh.PERF_AVERAGE_BULK, pdh.PERF_AVERAGE_TIMER:
+		return pdh.PERF_AVERAGE_BASE, true
+	case pdh.PERF_RAW_FRACTION:
+		return pdh.PERF_RAW_BASE, true
+	case pdh.PERF_LARGE_RAW_FRACTION:
+		return pdh.PERF_LARGE_RAW_BASE, true
+	case pdh.PERF_SAMPLE_FRACTION:
+		return pdh.PERF_SAMPLE_BASE, true
+	case pdh.PERF_PRECISION_SYSTEM_TIMER, pdh.PERF_PRECISION_100NS_TIMER, pdh.PERF_PRECISION_OBJECT_TIMER:
+		return pdh.PERF_PRECISION_TIMESTAMP, true
+	case pdh.PERF_COUNTER_MULTI_TIMER, pdh.PERF_100NSEC_MULTI_TIMER,
+		pdh.PERF_COUNTER_MULTI_TIMER_INV, pdh.PERF_100NSEC_MULTI_TIMER_INV:
+		return pdh.PERF_COUNTER_MULTI_BASE, true
+	default:
+		return 0, false
+	}
+}
@@ -20,9 +20,7 @@ import (
 	"io"
 	"log/slog"
 	"os"
-	"sync"
 	"testing"
-	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus-community/windows_exporter/internal/collector/update"
@@ -62,18 +60,10 @@ func FuncBenchmarkCollector[C collector.Collector](b *testing.B, name string, co
 	}
 }
 
-func TestCollector[C collector.Collector, V interface{}](t *testing.T, fn func(*V) C, conf *V) {
+// newMISession creates the MI session for the live collection of a collector. It's closed once the test finished.
+func newMISession(t *testing.T) *mi.Session {
 	t.Helper()
 
-	var (
-		metrics []prometheus.Metric
-		err     error
-	)
-
-	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	c := fn(conf)
-	ch := make(chan prometheus.Metric, 10000)
-
 	miApp, err := mi.Application_Initialize()
 	require.NoError(t, err)
 
@@ -81,53 +71,29 @@ func TestCollector[C collector.Collector, V interface{}](t *testing.T, fn func(*
 	require.NoError(t, err)
 
 	t.Cleanup(func() {
-		require.NoError(t, c.Close())
 		require.NoError(t, miSession.Close())
 		require.NoError(t, miApp.Close())
 	})
 
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
-
-		for metric := range ch {
-			metrics = append(metrics, metric)
-		}
-	}()
-
-	err = c.Build(logger, miSession)
-
-	switch {
-	case err == nil:
-	case errors.Is(err, mi.MI_RESULT_INVALID_NAMESPACE),
-		errors.Is(err, pdh.NewPdhError(pdh.CstatusNoCounter)),
-		errors.Is(err, pdh.NewPdhError(pdh.CstatusNoObject)),
-		errors.Is(err, update.ErrUpdateServiceDisabled),
-		errors.Is(err, os.ErrNotExist):
-	default:
-		require.NoError(t, err)
-	}
-
-	time.Sleep(1 * time.Second)
+	return miSession
+}
 
-	err = c.Collect(ch)
+// isUnsupportedBuildError reports whether a live collector failed to build, since the host doesn't provide its source.
+func isUnsupportedBuildError(err error) bool {
+	return errors.Is(err, mi.MI_RESULT_INVALID_NAMESPACE) ||
+		errors.Is(err, pdh.NewPdhError(pdh.CstatusNoCounter)) ||
+		errors.Is(err, pdh.NewPdhError(pdh.CstatusNoObject)) ||
+		errors.Is(err, update.ErrUpdateServiceDisabled) ||
+		errors.Is(err, os.ErrNotExist)
+}
 
-	switch {
+// isUnsupportedCollectError reports whether a live collector failed to collect, since the host doesn't support it.
+func isUnsupportedCollectError(err error) bool {
 	// container collector
-	case errors.Is(err, windows.Errno(2151088411)),
-		errors.Is(err, pdh.ErrPerformanceCounterNotInitialized),
-		errors.Is(err, pdh.ErrNoData),
-		errors.Is(err, mi.MI_RESULT_INVALID_NAMESPACE),
-		errors.Is(err, mi.MI_RESULT_INVALID_QUERY),
-		errors.Is(err, update.ErrNoUpdates):
-		t.Skip("collector not supported on this system")
-	default:
-		require.NoError(t, err)
-	}
-
-	close(ch)
-
-	wg.Wait()
+	return errors.Is(err, windows.Errno(2151088411)) ||
+		errors.Is(err, pdh.ErrPerformanceCounterNotInitialized) ||
+		errors.Is(err, pdh.ErrNoData) ||
+		errors.Is(err, mi.MI_RESULT_INVALID_NAMESPACE) ||
+		errors.Is(err, mi.MI_RESULT_INVALID_QUERY) ||
+		errors.Is(err, update.ErrNoUpdates)
 }
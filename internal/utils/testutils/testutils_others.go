@@ -0,0 +1,38 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package testutils
+
+import (
+	"testing"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+)
+
+// newMISession skips the test, since collectors can only be collected live on Windows.
+func newMISession(t *testing.T) *mi.Session {
+	t.Helper()
+	t.Skip("live collection requires Windows, only collectors with performance counter data in testdata/pdh are replayed")
+
+	return nil
+}
+
+func isUnsupportedBuildError(error) bool {
+	return false
+}
+
+func isUnsupportedCollectError(error) bool {
+	return false
+}
@@ -32,6 +32,8 @@ import (
 type collectorStatus struct {
 	name       string
 	statusCode collectorStatusCode
+	timeout    time.Duration
+	numMetrics int64
 }
 
 type collectorStatusCode int
@@ -40,8 +42,16 @@ const (
 	pending collectorStatusCode = iota
 	success
 	failed
+	// skipped means the collection was not started, since the previous collection is still in flight.
+	skipped
 )
 
+// latencyBuckets are the classic buckets of the latency histograms.
+// Scrapers supporting native histograms get a higher resolution.
+//
+//nolint:gochecknoglobals
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
 func (c *Collection) collectAll(ch chan<- prometheus.Metric, logger *slog.Logger, maxScrapeDuration time.Duration) {
 	collectorStartTime := time.Now()
 
@@ -59,9 +69,14 @@ func (c *Collection) collectAll(ch chan<- prometheus.Metric, logger *slog.Logger
 		go func(name string, metricsCollector Collector) {
 			defer wg.Done()
 
+			timeout := c.collectorTimeout(name, maxScrapeDuration)
+			statusCode, numMetrics := c.collectCollector(ch, logger, name, metricsCollector, timeout)
+
 			collectorStatusCh <- collectorStatus{
 				name:       name,
-				statusCode: c.collectCollector(ch, logger, name, metricsCollector, maxScrapeDuration),
+				statusCode: statusCode,
+				timeout:    timeout,
+				numMetrics: numMetrics,
 			}
 		}(name, metricsCollector)
 	}
@@ -73,9 +88,17 @@ func (c *Collection) collectAll(ch chan<- prometheus.Metric, logger *slog.Logger
 	close(collectorStatusCh)
 
 	for status := range collectorStatusCh {
-		var successValue, timeoutValue float64
-		if status.statusCode == pending {
+		var successValue, timeoutValue, partialValue, inflightValue float64
+		if status.statusCode == pending || status.statusCode == skipped {
 			timeoutValue = 1.0
+
+			if status.numMetrics > 0 {
+				partialValue = 1.0
+			}
+		}
+
+		if c.stats.isInflight(status.name) {
+			inflightValue = 1.0
 		}
 
 		if status.statusCode == success {
@@ -95,23 +118,64 @@ func (c *Collection) collectAll(ch chan<- prometheus.Metric, logger *slog.Logger
 			timeoutValue,
 			status.name,
 		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.collectorPartialDesc,
+			prometheus.GaugeValue,
+			partialValue,
+			status.name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.collectorInflightDesc,
+			prometheus.GaugeValue,
+			inflightValue,
+			status.name,
+		)
+
+		ch <- prometheus.MustNewConstMetric(
+			c.collectorTimeoutDesc,
+			prometheus.GaugeValue,
+			status.timeout.Seconds(),
+			status.name,
+		)
+
+		// Only expose the series of the requested collectors.
+		ch <- c.collectorLatency.WithLabelValues(status.name).(prometheus.Metric)
+		ch <- c.collectorErrors.WithLabelValues(status.name)
+		ch <- c.collectorTimeouts.WithLabelValues(status.name)
+		ch <- c.collectorRebuilds.WithLabelValues(status.name)
 	}
 
+	scrapeDuration := time.Since(collectorStartTime)
+
 	ch <- prometheus.MustNewConstMetric(
 		c.scrapeDurationDesc,
 		prometheus.GaugeValue,
-		time.Since(collectorStartTime).Seconds(),
+		scrapeDuration.Seconds(),
 	)
+
+	c.scrapeLatency.Observe(scrapeDuration.Seconds())
+
+	ch <- c.scrapeLatency
 }
 
-func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.Logger, name string, collector Collector, maxScrapeDuration time.Duration) collectorStatusCode {
+func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.Logger, name string, collector Collector, maxScrapeDuration time.Duration) (collectorStatusCode, int64) {
 	var (
 		err        error
-		numMetrics int
+		numMetrics atomic.Int64
 		duration   time.Duration
 		timeout    atomic.Bool
 	)
 
+	// Do not start another collection while a timed out collection is still running.
+	// Otherwise, hanging collectors would pile up goroutines on every scrape.
+	if !c.stats.tryStart(name) {
+		logger.LogAttrs(context.Background(), slog.LevelWarn, fmt.Sprintf("collector %s skipped, since the previous collection is still running", name))
+
+		return skipped, 0
+	}
+
 	// bufCh is a buffer channel to store the metrics
 	// This is needed because once timeout is reached, the prometheus registry channel is closed.
 	bufCh := make(chan prometheus.Metric, 1000)
@@ -129,10 +193,17 @@ func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.
 				)
 			}
 
+			c.stats.finish(name)
 			close(bufCh)
 		}()
 
-		errCh <- collector.Collect(bufCh)
+		if err := c.rebuildCollector(logger, name, collector); err != nil {
+			errCh <- err
+
+			return
+		}
+
+		errCh <- NewContextCollector(collector).CollectContext(ctx, bufCh)
 	}()
 
 	wg := sync.WaitGroup{}
@@ -161,7 +232,7 @@ func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.
 				if !timeout.Load() {
 					ch <- m
 
-					numMetrics++
+					numMetrics.Add(1)
 				}
 			}
 		}
@@ -174,6 +245,8 @@ func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.
 	case err = <-errCh:
 		wg.Wait() // Wait for the buffer channel to be closed and empty
 
+		c.rebuilds.observe(name, time.Now(), err)
+
 		duration = time.Since(t)
 		ch <- prometheus.MustNewConstMetric(
 			c.collectorScrapeDurationDesc,
@@ -181,6 +254,9 @@ func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.
 			duration.Seconds(),
 			name,
 		)
+
+		c.collectorLatency.WithLabelValues(name).Observe(duration.Seconds())
+		c.stats.setLastDuration(name, duration)
 	case <-ctx.Done():
 		timeout.Store(true)
 
@@ -192,7 +268,10 @@ func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.
 			name,
 		)
 
-		logger.LogAttrs(ctx, slog.LevelWarn, fmt.Sprintf("collector %s timeouted after %s, resulting in %d metrics", name, maxScrapeDuration, numMetrics))
+		c.collectorLatency.WithLabelValues(name).Observe(duration.Seconds())
+		c.stats.setLastDuration(name, duration)
+
+		logger.LogAttrs(ctx, slog.LevelWarn, fmt.Sprintf("collector %s timeouted after %s, resulting in %d metrics", name, maxScrapeDuration, numMetrics.Load()))
 
 		go func() {
 			// Drain channel in case of premature return to not leak a goroutine.
@@ -201,7 +280,9 @@ func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.
 			}
 		}()
 
-		return pending
+		c.collectorTimeouts.WithLabelValues(name).Inc()
+
+		return pending, numMetrics.Load()
 	}
 
 	if err != nil && !errors.Is(err, pdh.ErrNoData) && !errors.Is(err, types.ErrNoData) {
@@ -210,14 +291,16 @@ func (c *Collection) collectCollector(ch chan<- prometheus.Metric, logger *slog.
 		}
 
 		logger.LogAttrs(ctx, slog.LevelWarn,
-			fmt.Sprintf("collector %s failed after %s, resulting in %d metrics", name, duration, numMetrics),
+			fmt.Sprintf("collector %s failed after %s, resulting in %d metrics", name, duration, numMetrics.Load()),
 			slog.Any("err", err),
 		)
 
-		return failed
+		c.collectorErrors.WithLabelValues(name).Inc()
+
+		return failed, numMetrics.Load()
 	}
 
-	logger.LogAttrs(ctx, slog.LevelDebug, fmt.Sprintf("collector %s succeeded after %s, resulting in %d metrics", name, duration, numMetrics))
+	logger.LogAttrs(ctx, slog.LevelDebug, fmt.Sprintf("collector %s succeeded after %s, resulting in %d metrics", name, duration, numMetrics.Load()))
 
-	return success
+	return success, numMetrics.Load()
 }
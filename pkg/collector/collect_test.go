@@ -0,0 +1,345 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCollector is a collector with a controlled delay and result.
+// It exposes one metric before and one metric after the delay.
+type fakeCollector struct {
+	name      string
+	delay     time.Duration
+	err       error
+	desc      *prometheus.Desc
+	afterDesc *prometheus.Desc
+}
+
+func newFakeCollector(name string, delay time.Duration, err error) *fakeCollector {
+	return &fakeCollector{
+		name:      name,
+		delay:     delay,
+		err:       err,
+		desc:      prometheus.NewDesc("windows_fake_"+name, "Fake metric.", nil, nil),
+		afterDesc: prometheus.NewDesc("windows_fake_"+name+"_after_delay", "Fake metric exposed after the delay.", nil, nil),
+	}
+}
+
+func (c *fakeCollector) GetName() string { return c.name }
+
+func (c *fakeCollector) Build(*slog.Logger, *mi.Session) error { return nil }
+
+func (c *fakeCollector) Close() error { return nil }
+
+func (c *fakeCollector) Collect(ch chan<- prometheus.Metric) error {
+	return c.CollectContext(context.Background(), ch)
+}
+
+func (c *fakeCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) error {
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.delay):
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.afterDesc, prometheus.GaugeValue, 1)
+
+	return c.err
+}
+
+// hangingCollector is a collector which ignores timeouts and blocks until release is closed.
+type hangingCollector struct {
+	release chan struct{}
+}
+
+func (c *hangingCollector) GetName() string { return "hanging" }
+
+func (c *hangingCollector) Build(*slog.Logger, *mi.Session) error { return nil }
+
+func (c *hangingCollector) Close() error { return nil }
+
+func (c *hangingCollector) Collect(chan<- prometheus.Metric) error {
+	<-c.release
+
+	return nil
+}
+
+func gatherCollection(t *testing.T, collection *Collection, maxScrapeDuration time.Duration, collectors ...string) *prometheus.Registry {
+	t.Helper()
+
+	handler, err := collection.NewHandler(maxScrapeDuration, slog.New(slog.NewTextHandler(io.Discard, nil)), collectors)
+	require.NoError(t, err)
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(handler))
+
+	return reg
+}
+
+// waitIdle waits until no collection of the collection is in flight anymore.
+func waitIdle(t *testing.T, collection *Collection) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		for name := range collection.collectors {
+			if collection.stats.isInflight(name) {
+				return false
+			}
+		}
+
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCollectorLatencyAndFailureMetrics(t *testing.T) {
+	t.Parallel()
+
+	collection := New(Map{
+		"ok":     newFakeCollector("ok", 0, nil),
+		"failed": newFakeCollector("failed", 0, errors.New("failed")),
+		"slow":   newFakeCollector("slow", time.Second, nil),
+	})
+
+	for range 2 {
+		reg := gatherCollection(t, collection, 200*time.Millisecond)
+
+		_, err := reg.Gather()
+		require.NoError(t, err)
+
+		waitIdle(t, collection)
+	}
+
+	expected := `
+# HELP windows_exporter_collector_errors_total windows_exporter: Total number of failed collections.
+# TYPE windows_exporter_collector_errors_total counter
+windows_exporter_collector_errors_total{collector="failed"} 3
+windows_exporter_collector_errors_total{collector="ok"} 0
+windows_exporter_collector_errors_total{collector="slow"} 0
+# HELP windows_exporter_collector_timeouts_total windows_exporter: Total number of timed out collections.
+# TYPE windows_exporter_collector_timeouts_total counter
+windows_exporter_collector_timeouts_total{collector="failed"} 0
+windows_exporter_collector_timeouts_total{collector="ok"} 0
+windows_exporter_collector_timeouts_total{collector="slow"} 3
+`
+
+	reg := gatherCollection(t, collection, 200*time.Millisecond)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"windows_exporter_collector_errors_total",
+		"windows_exporter_collector_timeouts_total",
+	))
+
+	waitIdle(t, collection)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	var histograms int
+
+	for _, family := range families {
+		if !slices.Contains([]string{"windows_exporter_collector_latency_seconds", "windows_exporter_scrape_latency_seconds"}, family.GetName()) {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			histograms++
+
+			// The current scrape is already accounted for.
+			assert.Equal(t, uint64(4), metric.GetHistogram().GetSampleCount(), family.GetName())
+			assert.NotNil(t, metric.GetHistogram().Schema, "native histogram of %s", family.GetName())
+		}
+	}
+
+	assert.Equal(t, 4, histograms)
+
+	// Only the series of the requested collectors are exposed.
+	reg = gatherCollection(t, collection, 200*time.Millisecond, "ok")
+	count, err := testutil.GatherAndCount(reg, "windows_exporter_collector_latency_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestCollectorTimeoutOverride(t *testing.T) {
+	t.Parallel()
+
+	collection := New(Map{
+		"fast": newFakeCollector("fast", 0, nil),
+		"slow": newFakeCollector("slow", time.Second, nil),
+	})
+
+	require.NoError(t, collection.SetTimeoutOptions(TimeoutOptions{
+		Collectors: map[string]time.Duration{"slow": 100 * time.Millisecond},
+	}))
+
+	expected := `
+# HELP windows_exporter_collector_partial windows_exporter: Whether the collector timed out after exposing a part of its metrics.
+# TYPE windows_exporter_collector_partial gauge
+windows_exporter_collector_partial{collector="fast"} 0
+windows_exporter_collector_partial{collector="slow"} 1
+# HELP windows_exporter_collector_timeout windows_exporter: Whether the collector timed out.
+# TYPE windows_exporter_collector_timeout gauge
+windows_exporter_collector_timeout{collector="fast"} 0
+windows_exporter_collector_timeout{collector="slow"} 1
+# HELP windows_exporter_collector_timeout_seconds windows_exporter: Timeout applied to the collector.
+# TYPE windows_exporter_collector_timeout_seconds gauge
+windows_exporter_collector_timeout_seconds{collector="fast"} 5
+windows_exporter_collector_timeout_seconds{collector="slow"} 0.1
+# HELP windows_fake_slow Fake metric.
+# TYPE windows_fake_slow gauge
+windows_fake_slow 1
+`
+
+	start := time.Now()
+
+	require.NoError(t, testutil.GatherAndCompare(gatherCollection(t, collection, 5*time.Second), strings.NewReader(expected),
+		"windows_exporter_collector_partial",
+		"windows_exporter_collector_timeout",
+		"windows_exporter_collector_timeout_seconds",
+		"windows_fake_slow",
+		"windows_fake_slow_after_delay",
+	))
+
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCollectorTimeoutBudget(t *testing.T) {
+	t.Parallel()
+
+	collection := New(Map{
+		"fast": newFakeCollector("fast", 0, nil),
+		"slow": newFakeCollector("slow", 700*time.Millisecond, nil),
+	})
+
+	require.NoError(t, collection.SetTimeoutOptions(TimeoutOptions{Budget: 0.5}))
+
+	// The first scrape has no previous durations, all collectors get the full timeout.
+	expected := `
+# HELP windows_exporter_collector_success windows_exporter: Whether the collector was successful.
+# TYPE windows_exporter_collector_success gauge
+windows_exporter_collector_success{collector="fast"} 1
+windows_exporter_collector_success{collector="slow"} 1
+# HELP windows_exporter_collector_timeout_seconds windows_exporter: Timeout applied to the collector.
+# TYPE windows_exporter_collector_timeout_seconds gauge
+windows_exporter_collector_timeout_seconds{collector="fast"} 2
+windows_exporter_collector_timeout_seconds{collector="slow"} 2
+`
+	require.NoError(t, testutil.GatherAndCompare(gatherCollection(t, collection, 2*time.Second), strings.NewReader(expected),
+		"windows_exporter_collector_success",
+		"windows_exporter_collector_timeout_seconds",
+	))
+
+	waitIdle(t, collection)
+
+	// The slow collector exceeded the budget before and is cut off early, fast collectors are not affected.
+	expected = `
+# HELP windows_exporter_collector_partial windows_exporter: Whether the collector timed out after exposing a part of its metrics.
+# TYPE windows_exporter_collector_partial gauge
+windows_exporter_collector_partial{collector="fast"} 0
+windows_exporter_collector_partial{collector="slow"} 1
+# HELP windows_exporter_collector_success windows_exporter: Whether the collector was successful.
+# TYPE windows_exporter_collector_success gauge
+windows_exporter_collector_success{collector="fast"} 1
+windows_exporter_collector_success{collector="slow"} 0
+# HELP windows_exporter_collector_timeout_seconds windows_exporter: Timeout applied to the collector.
+# TYPE windows_exporter_collector_timeout_seconds gauge
+windows_exporter_collector_timeout_seconds{collector="fast"} 1
+windows_exporter_collector_timeout_seconds{collector="slow"} 0.5
+`
+	require.NoError(t, testutil.GatherAndCompare(gatherCollection(t, collection, time.Second), strings.NewReader(expected),
+		"windows_exporter_collector_partial",
+		"windows_exporter_collector_success",
+		"windows_exporter_collector_timeout_seconds",
+	))
+}
+
+func TestSetTimeoutOptions(t *testing.T) {
+	t.Parallel()
+
+	collection := New(Map{"fast": newFakeCollector("fast", 0, nil)})
+
+	require.NoError(t, collection.SetTimeoutOptions(TimeoutOptions{
+		Collectors: map[string]time.Duration{"fast": time.Second},
+		Budget:     0.8,
+	}))
+
+	err := collection.SetTimeoutOptions(TimeoutOptions{
+		Collectors: map[string]time.Duration{"unknown": time.Second, "fast": -time.Second},
+		Budget:     1.5,
+	})
+	require.ErrorContains(t, err, "timeout of unknown collector unknown")
+	require.ErrorContains(t, err, "timeout of collector fast must not be negative")
+	require.ErrorContains(t, err, "timeout budget must be between 0 and 1")
+}
+
+//nolint:paralleltest // counts goroutines of the process
+func TestHangingCollectorDoesNotLeakGoroutines(t *testing.T) {
+	hanging := &hangingCollector{release: make(chan struct{})}
+	collection := New(Map{
+		"hanging": hanging,
+		"slow":    newFakeCollector("slow", time.Hour, nil),
+	})
+
+	expected := `
+# HELP windows_exporter_collector_inflight windows_exporter: Whether a collection of the collector is still running, e.g. after a timeout. No new collection is started while a collection is in flight.
+# TYPE windows_exporter_collector_inflight gauge
+windows_exporter_collector_inflight{collector="hanging"} 1
+windows_exporter_collector_inflight{collector="slow"} 0
+# HELP windows_exporter_collector_timeout windows_exporter: Whether the collector timed out.
+# TYPE windows_exporter_collector_timeout gauge
+windows_exporter_collector_timeout{collector="hanging"} 1
+windows_exporter_collector_timeout{collector="slow"} 1
+`
+
+	require.NoError(t, testutil.GatherAndCompare(gatherCollection(t, collection, 20*time.Millisecond), strings.NewReader(expected),
+		"windows_exporter_collector_inflight",
+		"windows_exporter_collector_timeout",
+	))
+
+	// The context aware collector returns once the context is canceled.
+	require.Eventually(t, func() bool {
+		return !collection.stats.isInflight("slow")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	goroutines := runtime.NumGoroutine()
+
+	for range 20 {
+		_, err := gatherCollection(t, collection, 20*time.Millisecond).Gather()
+		require.NoError(t, err)
+	}
+
+	// Allow some slack for goroutines of the runtime and other tests.
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= goroutines+2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(hanging.release)
+
+	waitIdle(t, collection)
+}
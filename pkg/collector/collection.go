@@ -20,8 +20,6 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"maps"
-	"slices"
 	"sync"
 	gotime "time"
 
@@ -39,6 +37,7 @@ import (
 	"github.com/prometheus-community/windows_exporter/internal/collector/diskdrive"
 	"github.com/prometheus-community/windows_exporter/internal/collector/dns"
 	"github.com/prometheus-community/windows_exporter/internal/collector/exchange"
+	"github.com/prometheus-community/windows_exporter/internal/collector/exec"
 	"github.com/prometheus-community/windows_exporter/internal/collector/filetime"
 	"github.com/prometheus-community/windows_exporter/internal/collector/fsrmquota"
 	"github.com/prometheus-community/windows_exporter/internal/collector/hyperv"
@@ -76,19 +75,44 @@ import (
 	"github.com/prometheus-community/windows_exporter/internal/collector/vmware"
 	"github.com/prometheus-community/windows_exporter/internal/mi"
 	"github.com/prometheus-community/windows_exporter/internal/pdh"
-	"github.com/prometheus-community/windows_exporter/internal/types"
-	"github.com/prometheus/client_golang/prometheus"
 )
 
 // NewWithFlags To be called by the exporter for collector initialization before running kingpin.Parse.
 func NewWithFlags(app *kingpin.Application) *Collection {
 	collectors := map[string]Collector{}
+	timeouts := map[string]*gotime.Duration{}
 
 	for name, builder := range BuildersWithFlags {
 		collectors[name] = builder(app)
+		timeouts[name] = app.Flag(
+			"collector."+name+".timeout",
+			"Timeout of the "+name+" collector. The scrape timeout is always an upper bound. 0 means the scrape timeout is used.",
+		).Default("0s").Duration()
 	}
 
-	return New(collectors)
+	timeoutBudget := app.Flag(
+		"scrape.timeout-budget",
+		"Share of the scrape timeout collectors, which were slower than this share before, may use. 0 disables the budget mode.",
+	).Default("0").Float64()
+
+	collection := New(collectors)
+
+	app.Action(func(*kingpin.ParseContext) error {
+		options := TimeoutOptions{
+			Collectors: make(map[string]gotime.Duration),
+			Budget:     *timeoutBudget,
+		}
+
+		for name, timeout := range timeouts {
+			if *timeout != 0 {
+				options.Collectors[name] = *timeout
+			}
+		}
+
+		return collection.SetTimeoutOptions(options)
+	})
+
+	return collection
 }
 
 // NewWithConfig To be called by the external libraries for collector initialization without running [kingpin.Parse].
@@ -109,6 +133,7 @@ func NewWithConfig(config Config) *Collection {
 	collectors[diskdrive.Name] = diskdrive.New(&config.DiskDrive)
 	collectors[dns.Name] = dns.New(&config.DNS)
 	collectors[exchange.Name] = exchange.New(&config.Exchange)
+	collectors[exec.Name] = exec.New(&config.Exec)
 	collectors[filetime.Name] = filetime.New(&config.Filetime)
 	collectors[fsrmquota.Name] = fsrmquota.New(&config.Fsrmquota)
 	collectors[hyperv.Name] = hyperv.New(&config.HyperV)
@@ -148,59 +173,11 @@ func NewWithConfig(config Config) *Collection {
 	return New(collectors)
 }
 
-// New To be called by the external libraries for collector initialization.
-func New(collectors Map) *Collection {
-	return &Collection{
-		collectors:    collectors,
-		concurrencyCh: make(chan struct{}, 1),
-		scrapeDurationDesc: prometheus.NewDesc(
-			prometheus.BuildFQName(types.Namespace, "exporter", "scrape_duration_seconds"),
-			"windows_exporter: Total scrape duration.",
-			nil,
-			nil,
-		),
-		collectorScrapeDurationDesc: prometheus.NewDesc(
-			prometheus.BuildFQName(types.Namespace, "exporter", "collector_duration_seconds"),
-			"windows_exporter: Duration of a collection.",
-			[]string{"collector"},
-			nil,
-		),
-		collectorScrapeSuccessDesc: prometheus.NewDesc(
-			prometheus.BuildFQName(types.Namespace, "exporter", "collector_success"),
-			"windows_exporter: Whether the collector was successful.",
-			[]string{"collector"},
-			nil,
-		),
-		collectorScrapeTimeoutDesc: prometheus.NewDesc(
-			prometheus.BuildFQName(types.Namespace, "exporter", "collector_timeout"),
-			"windows_exporter: Whether the collector timed out.",
-			[]string{"collector"},
-			nil,
-		),
-	}
-}
-
-// Enable removes all collectors that not enabledCollectors.
-func (c *Collection) Enable(enabledCollectors []string) error {
-	for _, name := range enabledCollectors {
-		if _, ok := c.collectors[name]; !ok {
-			return fmt.Errorf("unknown collector %s", name)
-		}
-	}
-
-	for name := range c.collectors {
-		if !slices.Contains(enabledCollectors, name) {
-			delete(c.collectors, name)
-		}
-	}
-
-	return nil
-}
-
 // Build To be called by the exporter for collector initialization.
 // Instead, fail fast, it will try to build all collectors and return all errors.
 // errors are joined with errors.Join.
 func (c *Collection) Build(logger *slog.Logger) error {
+	c.logger = logger
 	c.startTime = gotime.Now()
 
 	err := c.initMI()
@@ -294,27 +271,3 @@ func (c *Collection) initMI() error {
 
 	return nil
 }
-
-// WithCollectors To be called by the exporter for collector initialization.
-func (c *Collection) WithCollectors(collectors []string) (*Collection, error) {
-	metricCollectors := &Collection{
-		miSession:                   c.miSession,
-		startTime:                   c.startTime,
-		concurrencyCh:               c.concurrencyCh,
-		scrapeDurationDesc:          c.scrapeDurationDesc,
-		collectorScrapeDurationDesc: c.collectorScrapeDurationDesc,
-		collectorScrapeSuccessDesc:  c.collectorScrapeSuccessDesc,
-		collectorScrapeTimeoutDesc:  c.collectorScrapeTimeoutDesc,
-		collectors:                  maps.Clone(c.collectors),
-	}
-
-	if err := metricCollectors.Enable(collectors); err != nil {
-		return nil, err
-	}
-
-	return metricCollectors, nil
-}
-
-func (c *Collection) GetStartTime() gotime.Time {
-	return c.startTime
-}
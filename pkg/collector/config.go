@@ -29,6 +29,7 @@ import (
 	"github.com/prometheus-community/windows_exporter/internal/collector/diskdrive"
 	"github.com/prometheus-community/windows_exporter/internal/collector/dns"
 	"github.com/prometheus-community/windows_exporter/internal/collector/exchange"
+	"github.com/prometheus-community/windows_exporter/internal/collector/exec"
 	"github.com/prometheus-community/windows_exporter/internal/collector/filetime"
 	"github.com/prometheus-community/windows_exporter/internal/collector/fsrmquota"
 	"github.com/prometheus-community/windows_exporter/internal/collector/hyperv"
@@ -80,6 +81,7 @@ type Config struct {
 	DiskDrive          diskdrive.Config          `yaml:"disk_drive"`
 	DNS                dns.Config                `yaml:"dns"`
 	Exchange           exchange.Config           `yaml:"exchange"`
+	Exec               exec.Config               `yaml:"exec"`
 	Filetime           filetime.Config           `yaml:"filetime"`
 	Fsrmquota          fsrmquota.Config          `yaml:"fsrmquota"`
 	HyperV             hyperv.Config             `yaml:"hyper_v"`
@@ -135,6 +137,7 @@ var ConfigDefaults = Config{
 	DiskDrive:          diskdrive.ConfigDefaults,
 	DNS:                dns.ConfigDefaults,
 	Exchange:           exchange.ConfigDefaults,
+	Exec:               exec.ConfigDefaults,
 	Filetime:           filetime.ConfigDefaults,
 	Fsrmquota:          fsrmquota.ConfigDefaults,
 	HyperV:             hyperv.ConfigDefaults,
@@ -33,6 +33,7 @@ import (
 	"github.com/prometheus-community/windows_exporter/internal/collector/diskdrive"
 	"github.com/prometheus-community/windows_exporter/internal/collector/dns"
 	"github.com/prometheus-community/windows_exporter/internal/collector/exchange"
+	"github.com/prometheus-community/windows_exporter/internal/collector/exec"
 	"github.com/prometheus-community/windows_exporter/internal/collector/filetime"
 	"github.com/prometheus-community/windows_exporter/internal/collector/fsrmquota"
 	"github.com/prometheus-community/windows_exporter/internal/collector/hyperv"
@@ -91,6 +92,7 @@ var BuildersWithFlags = map[string]BuilderWithFlags[Collector]{
 	diskdrive.Name:          NewBuilderWithFlags(diskdrive.NewWithFlags),
 	dns.Name:                NewBuilderWithFlags(dns.NewWithFlags),
 	exchange.Name:           NewBuilderWithFlags(exchange.NewWithFlags),
+	exec.Name:               NewBuilderWithFlags(exec.NewWithFlags),
 	filetime.Name:           NewBuilderWithFlags(filetime.NewWithFlags),
 	fsrmquota.Name:          NewBuilderWithFlags(fsrmquota.NewWithFlags),
 	hyperv.Name:             NewBuilderWithFlags(hyperv.NewWithFlags),
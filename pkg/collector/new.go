@@ -0,0 +1,170 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	gotime "time"
+
+	"github.com/prometheus-community/windows_exporter/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// New To be called by the external libraries for collector initialization.
+func New(collectors Map) *Collection {
+	return &Collection{
+		collectors:    collectors,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		concurrencyCh: make(chan struct{}, 1),
+		scrapeDurationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "exporter", "scrape_duration_seconds"),
+			"windows_exporter: Total scrape duration.",
+			nil,
+			nil,
+		),
+		collectorScrapeDurationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "exporter", "collector_duration_seconds"),
+			"windows_exporter: Duration of a collection.",
+			[]string{"collector"},
+			nil,
+		),
+		collectorScrapeSuccessDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "exporter", "collector_success"),
+			"windows_exporter: Whether the collector was successful.",
+			[]string{"collector"},
+			nil,
+		),
+		collectorScrapeTimeoutDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "exporter", "collector_timeout"),
+			"windows_exporter: Whether the collector timed out.",
+			[]string{"collector"},
+			nil,
+		),
+		collectorTimeoutDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "exporter", "collector_timeout_seconds"),
+			"windows_exporter: Timeout applied to the collector.",
+			[]string{"collector"},
+			nil,
+		),
+		collectorPartialDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "exporter", "collector_partial"),
+			"windows_exporter: Whether the collector timed out after exposing a part of its metrics.",
+			[]string{"collector"},
+			nil,
+		),
+		collectorInflightDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(types.Namespace, "exporter", "collector_inflight"),
+			"windows_exporter: Whether a collection of the collector is still running, e.g. after a timeout. No new collection is started while a collection is in flight.",
+			[]string{"collector"},
+			nil,
+		),
+		stats:    newCollectorStats(),
+		rebuilds: newRebuildTracker(),
+		scrapeLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:                       types.Namespace,
+			Subsystem:                       "exporter",
+			Name:                            "scrape_latency_seconds",
+			Help:                            "windows_exporter: Histogram of total scrape durations.",
+			Buckets:                         latencyBuckets,
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: gotime.Hour,
+		}),
+		collectorLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:                       types.Namespace,
+			Subsystem:                       "exporter",
+			Name:                            "collector_latency_seconds",
+			Help:                            "windows_exporter: Histogram of collection durations.",
+			Buckets:                         latencyBuckets,
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: gotime.Hour,
+		}, []string{"collector"}),
+		collectorErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "collector_errors_total",
+			Help:      "windows_exporter: Total number of failed collections.",
+		}, []string{"collector"}),
+		collectorTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "collector_timeouts_total",
+			Help:      "windows_exporter: Total number of timed out collections.",
+		}, []string{"collector"}),
+		collectorRebuilds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: types.Namespace,
+			Subsystem: "exporter",
+			Name:      "collector_rebuilds_total",
+			Help:      "windows_exporter: Total number of attempts to rebuild a collector, whose performance counters became stale.",
+		}, []string{"collector"}),
+	}
+}
+
+// Enable removes all collectors that not enabledCollectors.
+func (c *Collection) Enable(enabledCollectors []string) error {
+	for _, name := range enabledCollectors {
+		if _, ok := c.collectors[name]; !ok {
+			return fmt.Errorf("unknown collector %s", name)
+		}
+	}
+
+	for name := range c.collectors {
+		if !slices.Contains(enabledCollectors, name) {
+			delete(c.collectors, name)
+		}
+	}
+
+	return nil
+}
+
+// WithCollectors To be called by the exporter for collector initialization.
+func (c *Collection) WithCollectors(collectors []string) (*Collection, error) {
+	metricCollectors := &Collection{
+		logger:                      c.logger,
+		miSession:                   c.miSession,
+		startTime:                   c.startTime,
+		concurrencyCh:               c.concurrencyCh,
+		scrapeDurationDesc:          c.scrapeDurationDesc,
+		collectorScrapeDurationDesc: c.collectorScrapeDurationDesc,
+		collectorScrapeSuccessDesc:  c.collectorScrapeSuccessDesc,
+		collectorScrapeTimeoutDesc:  c.collectorScrapeTimeoutDesc,
+		collectorTimeoutDesc:        c.collectorTimeoutDesc,
+		collectorPartialDesc:        c.collectorPartialDesc,
+		collectorInflightDesc:       c.collectorInflightDesc,
+		timeoutOptions:              c.timeoutOptions,
+		stats:                       c.stats,
+		rebuilds:                    c.rebuilds,
+		scrapeLatency:               c.scrapeLatency,
+		collectorLatency:            c.collectorLatency,
+		collectorErrors:             c.collectorErrors,
+		collectorTimeouts:           c.collectorTimeouts,
+		collectorRebuilds:           c.collectorRebuilds,
+		collectors:                  maps.Clone(c.collectors),
+	}
+
+	if err := metricCollectors.Enable(collectors); err != nil {
+		return nil, err
+	}
+
+	return metricCollectors, nil
+}
+
+func (c *Collection) GetStartTime() gotime.Time {
+	return c.startTime
+}
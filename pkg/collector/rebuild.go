@@ -0,0 +1,182 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+)
+
+const (
+	// rebuildMinBackoff is the delay after the first rebuild, which did not recover the collector.
+	// The delay doubles with every further attempt up to rebuildMaxBackoff.
+	rebuildMinBackoff = 15 * time.Second
+	rebuildMaxBackoff = 10 * time.Minute
+)
+
+// needsRebuild reports whether a collection failed, since the performance counters of the collector are stale
+// or were never initialized, e.g. since the service providing them was installed or restarted after the exporter.
+func needsRebuild(err error) bool {
+	return errors.Is(err, pdh.ErrStaleQuery) || errors.Is(err, pdh.ErrPerformanceCounterNotInitialized)
+}
+
+// rebuildState is the retry state machine of a collector:
+//
+//	healthy --stale collection--> pending --rebuild failed--> pending
+//	   ^                             |
+//	   +------ rebuild succeeded ----+
+//
+// Rebuilds are attempted on the next collection, which is due. The first rebuild is due immediately,
+// every further one without a successful collection in between is delayed by an exponential backoff.
+type rebuildState struct {
+	pending bool
+	// attempts is the number of rebuilds since the last successful collection.
+	attempts int
+	next     time.Time
+}
+
+// stale marks the collector as to be rebuilt.
+func (s *rebuildState) stale(now time.Time) {
+	if s.pending {
+		return
+	}
+
+	s.pending = true
+	s.next = now.Add(rebuildBackoff(s.attempts))
+}
+
+// due reports whether a rebuild should be attempted.
+func (s *rebuildState) due(now time.Time) bool {
+	return s.pending && !now.Before(s.next)
+}
+
+// rebuilt records a rebuild attempt. A failed rebuild stays pending.
+func (s *rebuildState) rebuilt(now time.Time, err error) {
+	s.attempts++
+	s.pending = err != nil
+	s.next = now.Add(rebuildBackoff(s.attempts))
+}
+
+// collected records a successful collection, which resets the backoff.
+func (s *rebuildState) collected() {
+	if !s.pending {
+		s.attempts = 0
+	}
+}
+
+func rebuildBackoff(attempts int) time.Duration {
+	if attempts == 0 {
+		return 0
+	}
+
+	backoff := rebuildMinBackoff
+
+	for range attempts - 1 {
+		backoff *= 2
+
+		if backoff >= rebuildMaxBackoff {
+			return rebuildMaxBackoff
+		}
+	}
+
+	return backoff
+}
+
+// rebuildTracker tracks the rebuild state of each collector.
+// It is shared between all collections derived via [Collection.WithCollectors].
+type rebuildTracker struct {
+	mu     sync.Mutex
+	states map[string]*rebuildState
+}
+
+func newRebuildTracker() *rebuildTracker {
+	return &rebuildTracker{
+		states: make(map[string]*rebuildState),
+	}
+}
+
+func (t *rebuildTracker) state(name string) *rebuildState {
+	state, ok := t.states[name]
+	if !ok {
+		state = &rebuildState{}
+		t.states[name] = state
+	}
+
+	return state
+}
+
+func (t *rebuildTracker) due(name string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.state(name).due(now)
+}
+
+func (t *rebuildTracker) rebuilt(name string, now time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state(name).rebuilt(now, err)
+}
+
+// observe updates the state of the collector with the result of a collection.
+func (t *rebuildTracker) observe(name string, now time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case needsRebuild(err):
+		t.state(name).stale(now)
+	case err == nil || errors.Is(err, pdh.ErrNoData):
+		t.state(name).collected()
+	}
+}
+
+// rebuildCollector closes and builds the collector again, if its performance counters became stale.
+// It must only be called while a collection of the collector is in flight, to not race with other collections.
+// The collector is built with the logger of the collection, since it outlives the scrape logged by logger.
+func (c *Collection) rebuildCollector(logger *slog.Logger, name string, collector Collector) error {
+	now := time.Now()
+
+	if !c.rebuilds.due(name, now) {
+		return nil
+	}
+
+	c.collectorRebuilds.WithLabelValues(name).Inc()
+
+	if err := collector.Close(); err != nil {
+		logger.LogAttrs(context.Background(), slog.LevelDebug, fmt.Sprintf("failed to close collector %s before rebuild", name),
+			slog.Any("err", err),
+		)
+	}
+
+	err := collector.Build(c.logger, c.miSession)
+
+	c.rebuilds.rebuilt(name, now, err)
+
+	if err != nil {
+		// The failure is logged as failed collection.
+		return fmt.Errorf("failed to rebuild collector: %w", err)
+	}
+
+	logger.LogAttrs(context.Background(), slog.LevelInfo, fmt.Sprintf("rebuilt collector %s, since its performance counters became stale", name))
+
+	return nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus-community/windows_exporter/internal/mi"
+	"github.com/prometheus-community/windows_exporter/internal/pdh"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebuildBackoff(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, time.Duration(0), rebuildBackoff(0))
+	assert.Equal(t, 15*time.Second, rebuildBackoff(1))
+	assert.Equal(t, 30*time.Second, rebuildBackoff(2))
+	assert.Equal(t, 4*time.Minute, rebuildBackoff(5))
+	assert.Equal(t, 8*time.Minute, rebuildBackoff(6))
+	assert.Equal(t, rebuildMaxBackoff, rebuildBackoff(7))
+	assert.Equal(t, rebuildMaxBackoff, rebuildBackoff(100))
+}
+
+func TestRebuildState(t *testing.T) {
+	t.Parallel()
+
+	errStale := fmt.Errorf("failed to collect: %w", pdh.ErrStaleQuery)
+	errBuild := errors.New("object not found")
+
+	type step struct {
+		// at is the offset of the event from the start.
+		at time.Duration
+		// event is one of collected, failed, stale, rebuilt and rebuildFailed.
+		event string
+
+		expectedDue bool
+	}
+
+	for _, tc := range []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "healthy",
+			steps: []step{
+				{at: 0, event: "collected"},
+				{at: time.Second, event: "failed"},
+			},
+		},
+		{
+			name: "rebuild is due immediately",
+			steps: []step{
+				{at: 0, event: "stale", expectedDue: true},
+				{at: 0, event: "rebuilt"},
+				{at: time.Second, event: "collected"},
+				// A successful collection resets the backoff.
+				{at: 2 * time.Second, event: "stale", expectedDue: true},
+			},
+		},
+		{
+			name: "failed rebuilds back off",
+			steps: []step{
+				{at: 0, event: "stale", expectedDue: true},
+				{at: 0, event: "rebuildFailed"},
+				{at: 14 * time.Second, event: "failed"},
+				{at: 15 * time.Second, event: "failed", expectedDue: true},
+				{at: 15 * time.Second, event: "rebuildFailed"},
+				{at: 44 * time.Second, event: "failed"},
+				{at: 45 * time.Second, event: "failed", expectedDue: true},
+				{at: 45 * time.Second, event: "rebuilt"},
+				{at: 46 * time.Second, event: "collected"},
+			},
+		},
+		{
+			name: "stale again after rebuild",
+			steps: []step{
+				{at: 0, event: "stale", expectedDue: true},
+				{at: 0, event: "rebuilt"},
+				// The rebuild did not recover the collector, so the next one is delayed.
+				{at: time.Second, event: "stale"},
+				{at: 15 * time.Second, event: "stale"},
+				{at: 16 * time.Second, event: "stale", expectedDue: true},
+				{at: 16 * time.Second, event: "rebuilt"},
+				{at: 17 * time.Second, event: "stale"},
+				{at: 47 * time.Second, event: "stale", expectedDue: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := time.Now()
+			tracker := newRebuildTracker()
+
+			for i, step := range tc.steps {
+				now := start.Add(step.at)
+
+				switch step.event {
+				case "collected":
+					tracker.observe("test", now, nil)
+				case "failed":
+					tracker.observe("test", now, errBuild)
+				case "stale":
+					tracker.observe("test", now, errStale)
+				case "rebuilt":
+					tracker.rebuilt("test", now, nil)
+				case "rebuildFailed":
+					tracker.rebuilt("test", now, errBuild)
+				}
+
+				assert.Equal(t, step.expectedDue, tracker.due("test", now), "step %d: %s at %s", i, step.event, step.at)
+			}
+		})
+	}
+}
+
+// staleCollector is a collector whose performance counters are stale until it was rebuilt.
+type staleCollector struct {
+	mu       sync.Mutex
+	builds   int
+	closes   int
+	buildErr error
+	stale    bool
+	desc     *prometheus.Desc
+	// logger is the logger of the last build.
+	logger *slog.Logger
+}
+
+func (c *staleCollector) GetName() string { return "stale" }
+
+func (c *staleCollector) Build(logger *slog.Logger, _ *mi.Session) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.builds++
+	c.logger = logger
+
+	if c.buildErr != nil {
+		return c.buildErr
+	}
+
+	c.stale = false
+
+	return nil
+}
+
+func (c *staleCollector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closes++
+
+	return nil
+}
+
+func (c *staleCollector) Collect(ch chan<- prometheus.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stale {
+		return fmt.Errorf("failed to collect Web Service metrics: %w", pdh.ErrStaleQuery)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1)
+
+	return nil
+}
+
+func TestCollectorRebuild(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		buildErr error
+
+		expectedBuilds   int
+		expectedRebuilds float64
+		expectedErrors   float64
+	}{
+		{
+			name:             "rebuild recovers",
+			expectedBuilds:   1,
+			expectedRebuilds: 1,
+			expectedErrors:   1,
+		},
+		{
+			// The second rebuild is delayed by the backoff.
+			name:             "rebuild fails",
+			buildErr:         pdh.NewPdhError(pdh.CstatusNoObject),
+			expectedBuilds:   1,
+			expectedRebuilds: 1,
+			expectedErrors:   3,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			collector := &staleCollector{
+				stale:    true,
+				buildErr: tc.buildErr,
+				desc:     prometheus.NewDesc("windows_fake_stale", "Fake metric.", nil, nil),
+			}
+
+			collection := New(Map{"stale": collector})
+			collection.logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			for range 3 {
+				_, err := gatherCollection(t, collection, time.Second).Gather()
+				require.NoError(t, err)
+
+				waitIdle(t, collection)
+			}
+
+			collector.mu.Lock()
+			defer collector.mu.Unlock()
+
+			assert.Equal(t, tc.expectedBuilds, collector.builds)
+			assert.Equal(t, tc.expectedBuilds, collector.closes)
+			// The collector must not keep the logger of the scrape, which triggered the rebuild.
+			assert.Same(t, collection.logger, collector.logger)
+			assert.InDelta(t, tc.expectedRebuilds, testutil.ToFloat64(collection.collectorRebuilds.WithLabelValues("stale")), 0)
+			assert.InDelta(t, tc.expectedErrors, testutil.ToFloat64(collection.collectorErrors.WithLabelValues("stale")), 0)
+		})
+	}
+}
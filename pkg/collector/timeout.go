@@ -0,0 +1,162 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TimeoutOptions controls how the scrape timeout is allocated to the collectors.
+type TimeoutOptions struct {
+	// Collectors overrides the timeout of single collectors. The scrape timeout is always an upper bound.
+	Collectors map[string]time.Duration
+	// Budget enables the budget mode, if greater than 0. In budget mode, collectors whose previous
+	// collection took at least Budget times the scrape timeout are considered slow and cut off after
+	// that share of the scrape timeout. This leaves headroom to expose the results of fast
+	// collectors, while slow collectors report partial results.
+	Budget float64
+}
+
+// collectorStats tracks the duration of the last collection and the in-flight collections of each collector.
+// It is shared between all collections derived via [Collection.WithCollectors].
+type collectorStats struct {
+	mu            sync.Mutex
+	idle          *sync.Cond
+	lastDurations map[string]time.Duration
+	inflight      map[string]bool
+}
+
+func newCollectorStats() *collectorStats {
+	s := &collectorStats{
+		lastDurations: make(map[string]time.Duration),
+		inflight:      make(map[string]bool),
+	}
+
+	s.idle = sync.NewCond(&s.mu)
+
+	return s
+}
+
+// tryStart marks a collection of the collector as in flight.
+// It returns false, if a collection is already in flight.
+func (s *collectorStats) tryStart(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inflight[name] {
+		return false
+	}
+
+	s.inflight[name] = true
+
+	return true
+}
+
+// finish marks the collection of the collector as done.
+func (s *collectorStats) finish(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.inflight, name)
+
+	if len(s.inflight) == 0 {
+		s.idle.Broadcast()
+	}
+}
+
+// waitIdle blocks until no collection is in flight.
+func (s *collectorStats) waitIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.inflight) > 0 {
+		s.idle.Wait()
+	}
+}
+
+func (s *collectorStats) isInflight(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.inflight[name]
+}
+
+func (s *collectorStats) lastDuration(name string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastDurations[name]
+}
+
+func (s *collectorStats) setLastDuration(name string, duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastDurations[name] = duration
+}
+
+// WaitInflight blocks until all collections, which are still running after a timeout, finished.
+// It must be called before the resources used by the collectors, e.g. the MI session, are released.
+func (c *Collection) WaitInflight() {
+	c.stats.waitIdle()
+}
+
+// SetTimeoutOptions configures the per-collector timeouts and the budget mode.
+func (c *Collection) SetTimeoutOptions(options TimeoutOptions) error {
+	var errs []error
+
+	for name, timeout := range options.Collectors {
+		if _, ok := c.collectors[name]; !ok {
+			errs = append(errs, fmt.Errorf("timeout of unknown collector %s", name))
+		}
+
+		if timeout < 0 {
+			errs = append(errs, fmt.Errorf("timeout of collector %s must not be negative", name))
+		}
+	}
+
+	if options.Budget < 0 || options.Budget > 1 {
+		errs = append(errs, errors.New("timeout budget must be between 0 and 1"))
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	c.timeoutOptions = options
+
+	return nil
+}
+
+// collectorTimeout returns the timeout of the collector for a scrape with the given timeout.
+func (c *Collection) collectorTimeout(name string, maxScrapeDuration time.Duration) time.Duration {
+	timeout := maxScrapeDuration
+
+	if override := c.timeoutOptions.Collectors[name]; override > 0 && override < timeout {
+		timeout = override
+	}
+
+	if c.timeoutOptions.Budget > 0 {
+		budget := time.Duration(float64(maxScrapeDuration) * c.timeoutOptions.Budget)
+
+		if budget < timeout && c.stats.lastDuration(name) >= budget {
+			timeout = budget
+		}
+	}
+
+	return timeout
+}
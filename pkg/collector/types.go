@@ -11,11 +11,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build windows
-
 package collector
 
 import (
+	"context"
 	"log/slog"
 	"time"
 
@@ -27,7 +26,9 @@ import (
 const DefaultCollectors = "cpu,cs,memory,logical_disk,physical_disk,net,os,service,system"
 
 type Collection struct {
-	collectors    Map
+	collectors Map
+	// logger is passed to the collectors on Build and on rebuilds.
+	logger        *slog.Logger
 	miSession     *mi.Session
 	startTime     time.Time
 	concurrencyCh chan struct{}
@@ -36,6 +37,20 @@ type Collection struct {
 	collectorScrapeDurationDesc *prometheus.Desc
 	collectorScrapeSuccessDesc  *prometheus.Desc
 	collectorScrapeTimeoutDesc  *prometheus.Desc
+	collectorTimeoutDesc        *prometheus.Desc
+	collectorPartialDesc        *prometheus.Desc
+	collectorInflightDesc       *prometheus.Desc
+
+	timeoutOptions TimeoutOptions
+	stats          *collectorStats
+	rebuilds       *rebuildTracker
+
+	// The following metrics are tracked across scrapes.
+	scrapeLatency     prometheus.Histogram
+	collectorLatency  *prometheus.HistogramVec
+	collectorErrors   *prometheus.CounterVec
+	collectorTimeouts *prometheus.CounterVec
+	collectorRebuilds *prometheus.CounterVec
 }
 
 type (
@@ -54,3 +69,30 @@ type Collector interface {
 	// Close closes the collector
 	Close() error
 }
+
+// ContextCollector is a [Collector] which supports the cancellation of a collection.
+// The context is canceled once the collector timed out.
+type ContextCollector interface {
+	Collector
+	// CollectContext Get new metrics and expose them via prometheus registry. It should return once ctx is done.
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric) (err error)
+}
+
+// contextCollectorAdapter adapts a [Collector] which does not support cancellation to a [ContextCollector].
+type contextCollectorAdapter struct {
+	Collector
+}
+
+func (a contextCollectorAdapter) CollectContext(_ context.Context, ch chan<- prometheus.Metric) error {
+	return a.Collect(ch)
+}
+
+// NewContextCollector returns the collector as [ContextCollector]. Collectors which do not implement
+// [ContextCollector] ignore the context and may continue to run after a timeout.
+func NewContextCollector(collector Collector) ContextCollector {
+	if contextCollector, ok := collector.(ContextCollector); ok {
+		return contextCollector
+	}
+
+	return contextCollectorAdapter{collector}
+}